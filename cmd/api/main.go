@@ -2,10 +2,15 @@ package main
 
 import (
 	"log"
+	"time"
 
+	"convo/internal/chat"
 	"convo/internal/server"
 	"convo/internal/config"
 	"convo/internal/database"
+	"convo/internal/search"
+	"convo/internal/storage"
+	"convo/internal/unfurl"
 )
 
 func main() {
@@ -21,6 +26,39 @@ func main() {
 		log.Fatalf("migrations error: %v", err)
 	}
 
+	chat.SendLimiter = chat.NewRateLimiter(cfg.SendRateLimit, time.Duration(cfg.SendRateWindowSecs)*time.Second)
+	chat.EditWindow = time.Duration(cfg.EditWindowMins) * time.Minute
+	chat.MaxPins = cfg.MaxPinnedMessages
+	chat.DedupeWindow = time.Duration(cfg.DedupeWindowMins) * time.Minute
+	if cfg.LinkPreviews != "off" {
+		chat.Unfurler = unfurl.NewFetcher(time.Duration(cfg.LinkPreviewTimeoutSecs)*time.Second, int64(cfg.LinkPreviewMaxKB)<<10)
+	}
+
+	switch cfg.StorageBackend {
+	case "s3":
+		chat.Blobs = &storage.S3Store{Endpoint: cfg.S3Endpoint, Region: cfg.S3Region, Bucket: cfg.S3Bucket,
+			AccessKey: cfg.S3AccessKey, SecretKey: cfg.S3SecretKey, PathStyle: cfg.S3PathStyle}
+	case "none":
+	default:
+		chat.Blobs = &storage.LocalStore{Root: cfg.StorageLocalDir}
+	}
+	chat.MaxAttachmentSize = int64(cfg.MaxAttachmentMB) << 20
+	chat.AttachmentURLSecret = []byte(cfg.AttachmentURLSecret)
+
+	switch cfg.SearchBackend {
+	case "memory":
+		idx := search.NewMemoryIndex()
+		if err := search.Backfill(database.GetDB(), idx); err != nil {
+			log.Fatalf("search backfill error: %v", err)
+		}
+		chat.SearchIndex = idx
+	default:
+		chat.SearchIndex = &search.MySQLIndex{DB: database.GetDB()}
+	}
+
+	go chat.RunScheduler(database.GetDB(), time.Duration(cfg.SchedulerIntervalSecs)*time.Second)
+	go chat.RunReaper(database.GetDB(), time.Duration(cfg.ReaperIntervalSecs)*time.Second)
+
 	// Start server
 	srv := server.NewServer(":8080", database.GetDB(), cfg.JWTSecret, cfg.JWTTTLHrs)
 	if err := srv.Run(); err != nil {
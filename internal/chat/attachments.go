@@ -0,0 +1,373 @@
+package chat
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"convo/internal/models"
+	"convo/internal/storage"
+)
+
+var (
+	// Blobs stores attachment contents; nil disables attachments. It is
+	// set from config at startup, as are MaxAttachmentSize and
+	// AttachmentURLSecret.
+	Blobs storage.Store
+	// MaxAttachmentSize is the largest file that can be uploaded, in bytes.
+	MaxAttachmentSize int64 = 25 << 20
+	// AttachmentURLSecret signs download URLs.
+	AttachmentURLSecret []byte
+)
+
+const (
+	// maxAttachments is how many files one message can carry.
+	maxAttachments = 10
+	// attachmentURLTTL is how long a download URL works.
+	attachmentURLTTL = 15 * time.Minute
+	// unsentAttachmentAge is how long an upload can wait to be sent
+	// before the reaper deletes it.
+	unsentAttachmentAge = 24 * time.Hour
+)
+
+var (
+	// ErrAttachmentsDisabled is returned when no blob store is configured.
+	ErrAttachmentsDisabled = errors.New("attachments are disabled")
+	// ErrAttachmentTooLarge is returned for uploads over MaxAttachmentSize.
+	ErrAttachmentTooLarge = errors.New("attachment too large")
+	// ErrAttachmentNotFound is returned when an attachment does not exist
+	// or the user may not see it.
+	ErrAttachmentNotFound = errors.New("attachment not found")
+	// ErrInvalidAttachment is returned when attachment_ids name anything
+	// but the sender's own unsent uploads to the room.
+	ErrInvalidAttachment = errors.New("attachment_ids must be your unsent uploads to this room")
+	// ErrInvalidDownloadURL is returned for a download URL that is
+	// forged or has expired.
+	ErrInvalidDownloadURL = errors.New("download link is invalid or expired")
+)
+
+// UploadAttachment stores a file of size bytes read from r for userID in
+// roomID. It stays unsent until a message lists it in AttachmentIDs. The
+// content type is sniffed from the data rather than trusted from the client.
+func UploadAttachment(db *sql.DB, roomID, userID int64, filename string, r io.Reader, size int64) (*models.Attachment, error) {
+	if Blobs == nil {
+		return nil, ErrAttachmentsDisabled
+	}
+	if size > MaxAttachmentSize {
+		return nil, ErrAttachmentTooLarge
+	}
+	if _, err := GetRole(db, roomID, userID); err != nil {
+		return nil, err
+	}
+
+	br := bufio.NewReaderSize(r, 512)
+	head, _ := br.Peek(512)
+	contentType := http.DetectContentType(head)
+	hash := sha256.New()
+	// size may not be known, so stop one byte past the limit to notice
+	counted := &countingReader{r: io.TeeReader(io.LimitReader(br, MaxAttachmentSize+1), hash)}
+
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	key := fmt.Sprintf("attachments/%d/%s", roomID, hex.EncodeToString(nonce))
+	ctx := context.Background()
+	if err := Blobs.Put(ctx, key, counted, size, contentType); err != nil {
+		return nil, err
+	}
+	if counted.n > MaxAttachmentSize {
+		removeBlobs([]string{key})
+		return nil, ErrAttachmentTooLarge
+	}
+
+	a := &models.Attachment{RoomID: roomID, UploaderID: userID, Filename: cleanFilename(filename),
+		ContentType: contentType, Size: counted.n, SHA256: hex.EncodeToString(hash.Sum(nil))}
+	res, err := db.Exec(`INSERT INTO attachments (room_id, uploader_id, storage_key, filename, content_type, size, sha256)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`, a.RoomID, a.UploaderID, key, a.Filename, a.ContentType, a.Size, a.SHA256)
+	if err != nil {
+		removeBlobs([]string{key})
+		return nil, err
+	}
+	a.ID, _ = res.LastInsertId()
+	a.CreatedAt = time.Now()
+	signAttachment(a)
+	return a, nil
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// cleanFilename keeps the base name of a client-supplied file name without
+// control characters or quotes, so it is safe in a Content-Disposition.
+func cleanFilename(name string) string {
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == '"' || r == utf8.RuneError {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+	for len(name) > 255 {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	if name == "" || name == "." || name == "/" {
+		return "file"
+	}
+	return name
+}
+
+// GetAttachment returns attachment id of roomID with a fresh download URL.
+// Members see attachments of sent messages; unsent uploads only their
+// uploader.
+func GetAttachment(db *sql.DB, roomID, id, viewerID int64) (*models.Attachment, error) {
+	if _, err := GetRole(db, roomID, viewerID); err != nil {
+		return nil, err
+	}
+	rows, err := db.Query(`SELECT `+attachmentColumns+` FROM attachments a
+		WHERE a.id = ? AND a.room_id = ? AND (a.message_id IS NOT NULL OR a.uploader_id = ?)`, id, roomID, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, ErrAttachmentNotFound
+	}
+	a, _, err := scanAttachment(rows)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+const attachmentColumns = `a.id, a.room_id, a.uploader_id, a.message_id, a.filename, a.content_type, a.size, a.sha256, a.created_at, a.storage_key`
+
+func scanAttachment(rows *sql.Rows) (models.Attachment, string, error) {
+	var a models.Attachment
+	var messageID sql.NullInt64
+	var key string
+	if err := rows.Scan(&a.ID, &a.RoomID, &a.UploaderID, &messageID, &a.Filename, &a.ContentType, &a.Size, &a.SHA256, &a.CreatedAt, &key); err != nil {
+		return a, "", err
+	}
+	a.MessageID = nullID(messageID)
+	signAttachment(&a)
+	return a, key, nil
+}
+
+// signAttachment sets a's download URL, valid for attachmentURLTTL.
+func signAttachment(a *models.Attachment) {
+	a.URLExpiresAt = time.Now().Add(attachmentURLTTL).Truncate(time.Second)
+	expires := a.URLExpiresAt.Unix()
+	a.URL = fmt.Sprintf("/attachments/%d?expires=%d&sig=%s", a.ID, expires, downloadSignature(a.ID, expires))
+}
+
+func downloadSignature(id, expires int64) string {
+	mac := hmac.New(sha256.New, AttachmentURLSecret)
+	mac.Write([]byte(strconv.FormatInt(id, 10) + ":" + strconv.FormatInt(expires, 10)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// OpenAttachment checks a download URL's expiry and signature and opens
+// the attachment it names. The caller closes the reader.
+func OpenAttachment(db *sql.DB, id, expires int64, sig string) (*models.Attachment, io.ReadCloser, error) {
+	if Blobs == nil {
+		return nil, nil, ErrAttachmentsDisabled
+	}
+	if time.Now().Unix() > expires || !hmac.Equal([]byte(sig), []byte(downloadSignature(id, expires))) {
+		return nil, nil, ErrInvalidDownloadURL
+	}
+	rows, err := db.Query(`SELECT `+attachmentColumns+` FROM attachments a WHERE a.id = ?`, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, ErrAttachmentNotFound
+	}
+	a, key, err := scanAttachment(rows)
+	if err != nil {
+		return nil, nil, err
+	}
+	rows.Close()
+	body, err := Blobs.Get(context.Background(), key)
+	if err == storage.ErrNotFound {
+		return nil, nil, ErrAttachmentNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return &a, body, nil
+}
+
+// linkAttachments attaches the uploads ids to m, which must be the
+// sender's own unsent uploads to m's room, and flags m as having
+// attachments.
+func linkAttachments(tx *sql.Tx, m *models.Message, ids []int64) error {
+	seen := make(map[int64]bool)
+	args := []interface{}{m.ID, m.RoomID, m.SenderID, int(unsentAttachmentAge / time.Second)}
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			args = append(args, id)
+		}
+	}
+	if len(seen) > maxAttachments {
+		return ErrInvalidAttachment
+	}
+	res, err := tx.Exec(`UPDATE attachments SET message_id = ?
+		WHERE room_id = ? AND uploader_id = ? AND message_id IS NULL AND created_at > NOW() - INTERVAL ? SECOND
+		AND id IN (`+placeholders(len(seen))+`)`, args...)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n != int64(len(seen)) {
+		return ErrInvalidAttachment
+	}
+	if _, err := tx.Exec("UPDATE messages SET has_attachment = TRUE WHERE id = ?", m.ID); err != nil {
+		return err
+	}
+	atts, err := loadAttachments(tx, []int64{m.ID})
+	if err != nil {
+		return err
+	}
+	m.Attachments = atts[m.ID]
+	return nil
+}
+
+// loadAttachments returns the attachments of the messages with ids, with
+// fresh download URLs.
+func loadAttachments(q rowsQuerier, ids []int64) (map[int64][]models.Attachment, error) {
+	atts := make(map[int64][]models.Attachment)
+	if len(ids) == 0 {
+		return atts, nil
+	}
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	rows, err := q.Query(`SELECT `+attachmentColumns+` FROM attachments a
+		WHERE a.message_id IN (`+placeholders(len(ids))+`) ORDER BY a.id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		a, _, err := scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		atts[*a.MessageID] = append(atts[*a.MessageID], a)
+	}
+	return atts, rows.Err()
+}
+
+// deleteAttachments removes the attachment rows of the messages with ids
+// in tx and returns their storage keys, for removeBlobs once tx commits.
+func deleteAttachments(tx *sql.Tx, ids []int64) ([]string, error) {
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	in := placeholders(len(ids))
+	rows, err := tx.Query(`SELECT storage_key FROM attachments WHERE message_id IN (`+in+`)`, args...)
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	rows.Close()
+	if len(keys) == 0 {
+		return nil, rows.Err()
+	}
+	if _, err := tx.Exec(`DELETE FROM attachments WHERE message_id IN (`+in+`)`, args...); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// removeBlobs deletes blobs whose rows are gone. Failures only leak
+// storage, so they are logged.
+func removeBlobs(keys []string) {
+	if Blobs == nil {
+		return
+	}
+	for _, key := range keys {
+		if err := Blobs.Delete(context.Background(), key); err != nil {
+			log.Printf("delete blob %s: %v", key, err)
+		}
+	}
+}
+
+// pruneAttachments deletes uploads that were never sent within
+// unsentAttachmentAge, and those left behind by purged messages.
+func pruneAttachments(db *sql.DB) error {
+	if Blobs == nil {
+		return nil
+	}
+	rows, err := db.Query(`SELECT id, storage_key FROM attachments
+		WHERE message_id IS NULL AND created_at < NOW() - INTERVAL ? SECOND LIMIT ?`,
+		int(unsentAttachmentAge/time.Second), reapBatch)
+	if err != nil {
+		return err
+	}
+	var ids []interface{}
+	var keys []string
+	for rows.Next() {
+		var id int64
+		var key string
+		if err := rows.Scan(&id, &key); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+		keys = append(keys, key)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil || len(ids) == 0 {
+		return err
+	}
+	if _, err := db.Exec(`DELETE FROM attachments WHERE id IN (`+placeholders(len(ids))+`)`, ids...); err != nil {
+		return err
+	}
+	removeBlobs(keys)
+	return nil
+}
@@ -0,0 +1,82 @@
+package chat
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+	"unicode"
+
+	"convo/internal/models"
+)
+
+// DedupeWindow is how long a client_msg_id is remembered. It is replaced
+// from config at startup.
+var DedupeWindow = 24 * time.Hour
+
+// ErrInvalidClientMsgID is returned for a client_msg_id that is too long or
+// contains spaces or control characters.
+var ErrInvalidClientMsgID = errors.New("client_msg_id must be at most 64 printable characters")
+
+// errDuplicateSend tells SendMessage that the client_msg_id was already
+// used, so the original message should be returned instead.
+var errDuplicateSend = errors.New("duplicate client_msg_id")
+
+func validClientMsgID(id string) bool {
+	if len(id) > 64 {
+		return false
+	}
+	for _, r := range id {
+		if unicode.IsSpace(r) || !unicode.IsPrint(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// claimClientMsgID records that m was sent for clientID, or returns
+// errDuplicateSend if the sender already used clientID in m's room within
+// DedupeWindow.
+func claimClientMsgID(tx *sql.Tx, m *models.Message, clientID string) error {
+	// a stale row from before the window does not count
+	if _, err := tx.Exec(`DELETE FROM message_client_ids WHERE sender_id = ? AND room_id = ? AND client_msg_id = ?
+		AND created_at < NOW() - INTERVAL ? SECOND`, m.SenderID, m.RoomID, clientID, int64(DedupeWindow/time.Second)); err != nil {
+		return err
+	}
+	res, err := tx.Exec("INSERT IGNORE INTO message_client_ids (sender_id, room_id, client_msg_id, message_id) VALUES (?, ?, ?, ?)",
+		m.SenderID, m.RoomID, clientID, m.ID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errDuplicateSend
+	}
+	return nil
+}
+
+// sentMessage returns the message senderID already sent to roomID with
+// clientID within DedupeWindow, or nil.
+func sentMessage(db *sql.DB, roomID, senderID int64, clientID string) (*models.Message, error) {
+	var messageID int64
+	err := db.QueryRow(`SELECT message_id FROM message_client_ids
+		WHERE sender_id = ? AND room_id = ? AND client_msg_id = ? AND created_at >= NOW() - INTERVAL ? SECOND`,
+		senderID, roomID, clientID, int64(DedupeWindow/time.Second)).Scan(&messageID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	m, err := GetMessage(db, senderID, roomID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	m.ClientMsgID = clientID
+	return m, nil
+}
+
+// pruneClientMsgIDs forgets client_msg_ids older than DedupeWindow.
+func pruneClientMsgIDs(db *sql.DB) error {
+	_, err := db.Exec("DELETE FROM message_client_ids WHERE created_at < NOW() - INTERVAL ? SECOND LIMIT 10000",
+		int64(DedupeWindow/time.Second))
+	return err
+}
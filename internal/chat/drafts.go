@@ -0,0 +1,94 @@
+package chat
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+
+	"convo/internal/models"
+	"convo/internal/ws"
+)
+
+// maxDraftBytes matches the capacity of the TEXT column.
+const maxDraftBytes = 65535
+
+// ErrDraftTooLong is returned for a draft that does not fit.
+var ErrDraftTooLong = errors.New("draft is too long")
+
+// GetDraft returns userID's draft in roomID, or nil if there is none.
+func GetDraft(db *sql.DB, roomID, userID int64) (*models.Draft, error) {
+	if _, err := GetRole(db, roomID, userID); err != nil {
+		return nil, err
+	}
+	d := &models.Draft{RoomID: roomID}
+	err := db.QueryRow("SELECT content, updated_at FROM room_drafts WHERE room_id = ? AND user_id = ?", roomID, userID).
+		Scan(&d.Content, &d.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// SaveDraft stores userID's draft in roomID, replacing any earlier one, and
+// pushes it to the user's live connections as "draft_updated". Saving an
+// empty draft deletes it.
+func SaveDraft(db *sql.DB, roomID, userID int64, content string) (*models.Draft, error) {
+	if content == "" {
+		return nil, DeleteDraft(db, roomID, userID)
+	}
+	if len(content) > maxDraftBytes {
+		return nil, ErrDraftTooLong
+	}
+	if _, err := GetRole(db, roomID, userID); err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(`INSERT INTO room_drafts (room_id, user_id, content) VALUES (?, ?, ?)
+		ON DUPLICATE KEY UPDATE content = VALUES(content), updated_at = CURRENT_TIMESTAMP`, roomID, userID, content); err != nil {
+		return nil, err
+	}
+	d, err := GetDraft(db, roomID, userID)
+	if err != nil || d == nil {
+		return d, err
+	}
+	b, _ := json.Marshal(map[string]interface{}{
+		"type":       "draft_updated",
+		"room_id":    roomID,
+		"content":    d.Content,
+		"updated_at": d.UpdatedAt,
+	})
+	ws.SendToUser(userID, 0, b)
+	return d, nil
+}
+
+// DeleteDraft removes userID's draft in roomID and, if there was one,
+// pushes "draft_deleted" to the user's live connections.
+func DeleteDraft(db *sql.DB, roomID, userID int64) error {
+	if _, err := GetRole(db, roomID, userID); err != nil {
+		return err
+	}
+	return clearDraft(db, roomID, userID)
+}
+
+// clearDraft deletes the draft without checking membership.
+func clearDraft(db *sql.DB, roomID, userID int64) error {
+	res, err := db.Exec("DELETE FROM room_drafts WHERE room_id = ? AND user_id = ?", roomID, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		b, _ := json.Marshal(map[string]interface{}{"type": "draft_deleted", "room_id": roomID})
+		ws.SendToUser(userID, 0, b)
+	}
+	return nil
+}
+
+// draftSent clears the sender's draft once their message is posted.
+func draftSent(db *sql.DB, m *models.Message) {
+	if err := clearDraft(db, m.RoomID, m.SenderID); err != nil {
+		log.Printf("clear draft room %d user %d: %v", m.RoomID, m.SenderID, err)
+	}
+}
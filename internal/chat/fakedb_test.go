@@ -0,0 +1,128 @@
+package chat
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeResult is what a fakeDB handler answers a statement with: rows for a
+// query, affected rows for an exec.
+type fakeResult struct {
+	rows     [][]driver.Value
+	affected int64
+	lastID   int64
+}
+
+// fakeDB is a database/sql driver that answers each statement from handle,
+// for testing the Go around the SQL. It records every statement it is
+// sent; statements handle does not know get no rows.
+type fakeDB struct {
+	handle func(query string, args []driver.Value) (fakeResult, error)
+
+	mu      sync.Mutex
+	queries []string
+}
+
+// openFake returns a *sql.DB backed by a fakeDB answering with handle.
+func openFake(t *testing.T, handle func(query string, args []driver.Value) (fakeResult, error)) (*sql.DB, *fakeDB) {
+	t.Helper()
+	f := &fakeDB{handle: handle}
+	db := sql.OpenDB(f)
+	t.Cleanup(func() { db.Close() })
+	return db, f
+}
+
+// ran reports whether a statement containing s was sent.
+func (f *fakeDB) ran(s string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, q := range f.queries {
+		if strings.Contains(q, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *fakeDB) run(query string, args []driver.NamedValue) (fakeResult, error) {
+	f.mu.Lock()
+	f.queries = append(f.queries, query)
+	f.mu.Unlock()
+	values := make([]driver.Value, len(args))
+	for i, a := range args {
+		values[i] = a.Value
+	}
+	return f.handle(query, values)
+}
+
+func (f *fakeDB) Connect(context.Context) (driver.Conn, error) { return fakeConn{f}, nil }
+func (f *fakeDB) Driver() driver.Driver                        { return nil }
+
+type fakeConn struct{ db *fakeDB }
+
+func (c fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("fakedb: prepared statements are not supported")
+}
+func (c fakeConn) Close() error              { return nil }
+func (c fakeConn) Begin() (driver.Tx, error) { return fakeTx{}, nil }
+
+func (c fakeConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	res, err := c.db.run(query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &fakeRows{rows: res.rows}, nil
+}
+
+func (c fakeConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	res, err := c.db.run(query, args)
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(res.affected), nil
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeRows struct{ rows [][]driver.Value }
+
+func (r *fakeRows) Columns() []string {
+	if len(r.rows) == 0 {
+		return nil
+	}
+	return make([]string, len(r.rows[0]))
+}
+
+func (r *fakeRows) Close() error { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+// messageRow is a messageColumns row for a plain text message.
+func messageRow(id, roomID, senderID int64, content string, expiresAt *time.Time, extra ...driver.Value) []driver.Value {
+	var expires driver.Value
+	if expiresAt != nil {
+		expires = *expiresAt
+	}
+	row := []driver.Value{id, roomID, senderID, content, FormatPlain, nil, KindText, time.Now(), nil,
+		nil, nil, nil, int64(0), nil,
+		nil, nil, nil, nil, nil,
+		nil, expires}
+	return append(row, extra...)
+}
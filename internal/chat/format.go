@@ -0,0 +1,139 @@
+package chat
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"convo/internal/markdown"
+)
+
+// Message formats, stored in messages.format.
+const (
+	FormatPlain    = "plain"
+	FormatMarkdown = "markdown"
+)
+
+// maxMarkdownBytes caps the content of markdown messages, which are parsed
+// while the send or edit holds its transaction.
+const maxMarkdownBytes = 16 << 10
+
+var (
+	// ErrInvalidFormat is returned for a format other than plain or markdown.
+	ErrInvalidFormat = errors.New("format must be plain or markdown")
+	// ErrContentTooLong is returned for markdown content over
+	// maxMarkdownBytes.
+	ErrContentTooLong = errors.New("markdown messages are limited to 16 KiB")
+)
+
+// normalizeFormat validates format, defaulting an empty one to plain.
+func normalizeFormat(format string) (string, error) {
+	switch format {
+	case "":
+		return FormatPlain, nil
+	case FormatPlain, FormatMarkdown:
+		return format, nil
+	}
+	return "", ErrInvalidFormat
+}
+
+// checkLength rejects content too long to be parsed as format.
+func checkLength(format, content string) error {
+	if format == FormatMarkdown && len(content) > maxMarkdownBytes {
+		return ErrContentTooLong
+	}
+	return nil
+}
+
+// rowsQuerier is satisfied by *sql.DB and *sql.Tx.
+type rowsQuerier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// renderRich parses content of a markdown message in roomID into the JSON
+// stored in messages.rich, resolving @mentions against the room's members.
+// Plain messages have no tree and get nil.
+func renderRich(q rowsQuerier, roomID int64, format, content string) ([]byte, error) {
+	if format != FormatMarkdown {
+		return nil, nil
+	}
+	if err := checkLength(format, content); err != nil {
+		return nil, err
+	}
+	var match markdown.MentionFunc
+	if strings.Contains(content, "@") {
+		var err error
+		if match, err = mentionMatcher(q, roomID); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(markdown.Parse(content, match))
+}
+
+// nullJSON stores an empty tree as NULL.
+func nullJSON(b []byte) interface{} {
+	if b == nil {
+		return nil
+	}
+	return string(b)
+}
+
+// mentionMatcher recognises @here, @room and the names of roomID's members
+// the same way parseMentions does, longest name first.
+func mentionMatcher(q rowsQuerier, roomID int64) (markdown.MentionFunc, error) {
+	rows, err := q.Query(`SELECT rm.user_id, u.name FROM room_members rm
+		JOIN users u ON u.id = rm.user_id WHERE rm.room_id = ?`, roomID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	byName := make(map[string]int64)
+	for rows.Next() {
+		var id int64
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, err
+		}
+		name = strings.TrimSpace(name)
+		if _, ok := byName[strings.ToLower(name)]; !ok && name != "" {
+			byName[strings.ToLower(name)] = id
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return func(s string) (int, int64) {
+		for _, word := range []string{"here", "room"} {
+			if n := foldPrefix(s, word); n > 0 {
+				return n, 0
+			}
+		}
+		best, bestID := 0, int64(0)
+		for name, id := range byName {
+			if n := foldPrefix(s, name); n > best {
+				best, bestID = n, id
+			}
+		}
+		return best, bestID
+	}, nil
+}
+
+// foldPrefix returns the length in bytes of the prefix of s that equals
+// word ignoring case and ends at a word boundary, or 0.
+func foldPrefix(s, word string) int {
+	n := 0
+	for k := utf8.RuneCountInString(word); k > 0 && n < len(s); k-- {
+		_, size := utf8.DecodeRuneInString(s[n:])
+		n += size
+	}
+	if n == 0 || !strings.EqualFold(s[:n], word) {
+		return 0
+	}
+	if next, _ := utf8.DecodeRuneInString(s[n:]); n < len(s) && isWordRune(next) {
+		return 0
+	}
+	return n
+}
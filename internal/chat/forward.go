@@ -0,0 +1,74 @@
+package chat
+
+import (
+	"database/sql"
+
+	"convo/internal/models"
+)
+
+// snippetLen caps the quoted text stored with an inline reply.
+const snippetLen = 200
+
+// quote snapshots messageID of roomID for an inline reply.
+func quote(tx *sql.Tx, roomID, messageID int64) (*models.ReplyRef, error) {
+	var senderID int64
+	var content string
+	var deletedAt sql.NullTime
+	err := tx.QueryRow("SELECT sender_id, content, deleted_at FROM messages WHERE id = ? AND room_id = ?", messageID, roomID).
+		Scan(&senderID, &content, &deletedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrMessageNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if deletedAt.Valid {
+		return nil, ErrMessageDeleted
+	}
+	if r := []rune(content); len(r) > snippetLen {
+		content = string(r[:snippetLen]) + "…"
+	}
+	return &models.ReplyRef{MessageID: &messageID, SenderID: &senderID, Snippet: content}, nil
+}
+
+// nullInt converts an optional id to a nullable column value.
+func nullInt(v *int64) sql.NullInt64 {
+	if v == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: *v, Valid: true}
+}
+
+// ForwardMessage posts a copy of messageID from roomID into targetRoomID on
+// behalf of userID, who must belong to both rooms. The copy is attributed
+// to the original author, even across several forwards, and is marked
+// forwarded in message_meta. Sending follows the usual rules of
+// SendMessage for the target room.
+func ForwardMessage(db *sql.DB, roomID, messageID, userID, targetRoomID int64) (*models.Message, error) {
+	if _, err := GetRole(db, roomID, userID); err != nil {
+		return nil, err
+	}
+
+	var senderID int64
+	var content, format string
+	var deletedAt sql.NullTime
+	var fwdMessage, fwdUser sql.NullInt64
+	err := db.QueryRow(`SELECT sender_id, content, format, deleted_at, forwarded_from_message_id, forwarded_from_user_id
+		FROM messages WHERE id = ? AND room_id = ?`, messageID, roomID).
+		Scan(&senderID, &content, &format, &deletedAt, &fwdMessage, &fwdUser)
+	if err == sql.ErrNoRows {
+		return nil, ErrMessageNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if deletedAt.Valid {
+		return nil, ErrMessageDeleted
+	}
+
+	origin := &models.ForwardRef{UserID: &senderID, MessageID: &messageID}
+	if fwdMessage.Valid || fwdUser.Valid {
+		origin = &models.ForwardRef{UserID: nullID(fwdUser), MessageID: nullID(fwdMessage)}
+	}
+	return SendMessage(db, SendRequest{RoomID: targetRoomID, SenderID: userID, Content: content, Format: format, Forward: origin})
+}
@@ -0,0 +1,220 @@
+package chat
+
+import (
+	"database/sql"
+
+	"convo/internal/models"
+)
+
+// HistoryQuery selects one page of a room's history, newest first.
+type HistoryQuery struct {
+	RoomID int64
+	// ThreadID selects the replies of that thread root; 0 selects the
+	// top-level room history, which leaves thread replies out.
+	ThreadID int64
+	// BeforeID pages backwards from (and excluding) that message id; 0
+	// starts from the newest message.
+	BeforeID int64
+	Limit    int
+}
+
+// messageColumns is the column list scanMessage expects, for a query over
+// messages aliased as m.
+const messageColumns = `m.id, m.room_id, m.sender_id, m.content, m.format, m.rich, m.kind, m.sent_at, m.edited_at,
+	m.deleted_at, m.deleted_by, m.thread_id, m.reply_count, m.last_reply_at,
+	m.reply_to_id, m.reply_sender_id, m.reply_snippet, m.forwarded_from_message_id, m.forwarded_from_user_id,
+	m.ttl_seconds, m.expires_at`
+
+// scanMessage reads one row selected with messageColumns, followed by any
+// extra columns into extra.
+func scanMessage(rows *sql.Rows, extra ...interface{}) (models.Message, error) {
+	var m models.Message
+	var editedAt, deletedAt, lastReplyAt sql.NullTime
+	var deletedBy, threadID sql.NullInt64
+	var replyTo, replySender, fwdMessage, fwdUser sql.NullInt64
+	var replySnippet sql.NullString
+	var ttl sql.NullInt64
+	var expiresAt sql.NullTime
+	var rich []byte
+	dest := []interface{}{&m.ID, &m.RoomID, &m.SenderID, &m.Content, &m.Format, &rich, &m.Kind, &m.SentAt, &editedAt,
+		&deletedAt, &deletedBy, &threadID, &m.ReplyCount, &lastReplyAt,
+		&replyTo, &replySender, &replySnippet, &fwdMessage, &fwdUser,
+		&ttl, &expiresAt}
+	if err := rows.Scan(append(dest, extra...)...); err != nil {
+		return m, err
+	}
+	if len(rich) > 0 {
+		m.Rich = rich
+	}
+	if editedAt.Valid {
+		m.Edited = true
+		m.EditedAt = &editedAt.Time
+	}
+	if deletedAt.Valid {
+		m.Deleted = true
+		m.DeletedAt = &deletedAt.Time
+		m.DeletedBy = &deletedBy.Int64
+	}
+	if threadID.Valid {
+		m.ThreadID = &threadID.Int64
+	}
+	if lastReplyAt.Valid {
+		m.LastReplyAt = &lastReplyAt.Time
+	}
+	// a reply has a sender snapshot even once the original is gone
+	if replySender.Valid || replyTo.Valid {
+		m.ReplyTo = &models.ReplyRef{
+			MessageID: nullID(replyTo),
+			SenderID:  nullID(replySender),
+			Snippet:   replySnippet.String,
+			Deleted:   !replySnippet.Valid,
+		}
+	}
+	if fwdMessage.Valid || fwdUser.Valid {
+		m.ForwardedFrom = &models.ForwardRef{MessageID: nullID(fwdMessage), UserID: nullID(fwdUser)}
+	}
+	if ttl.Valid {
+		secs := int(ttl.Int64)
+		m.TTLSeconds = &secs
+	}
+	if expiresAt.Valid {
+		m.ExpiresAt = &expiresAt.Time
+	}
+	return m, nil
+}
+
+// nullID converts a nullable id column to a pointer.
+func nullID(v sql.NullInt64) *int64 {
+	if !v.Valid {
+		return nil
+	}
+	return &v.Int64
+}
+
+// History returns a page of messages as seen by viewerID: messages the
+// viewer deleted for themselves are left out and the result is enriched
+// (see Enrich).
+func History(db *sql.DB, viewerID int64, q HistoryQuery) ([]models.Message, error) {
+	cond, args := historyScope(viewerID, q)
+	if q.BeforeID != 0 {
+		cond += ` AND m.id < ?`
+		args = append(args, q.BeforeID)
+	}
+	messages, err := queryHistory(db, cond, args, "DESC", q.Limit)
+	if err != nil {
+		return nil, err
+	}
+	if err := Enrich(db, viewerID, messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// notExpired is the condition, over messages aliased as m, that leaves out
+// disappearing messages past expires_at.
+const notExpired = `(m.expires_at IS NULL OR m.expires_at > NOW())`
+
+// historyScope returns the conditions (over messages aliased as m) that
+// select what q may show viewerID, regardless of position.
+func historyScope(viewerID int64, q HistoryQuery) (string, []interface{}) {
+	cond := `m.room_id = ?`
+	args := []interface{}{q.RoomID}
+	if q.ThreadID != 0 {
+		cond += ` AND m.thread_id = ?`
+		args = append(args, q.ThreadID)
+	} else {
+		cond += ` AND m.thread_id IS NULL`
+	}
+	cond += ` AND NOT EXISTS (SELECT 1 FROM message_hidden hd WHERE hd.message_id = m.id AND hd.user_id = ?)`
+	args = append(args, viewerID)
+	// expired messages wait for the reaper but are already gone to readers
+	cond += ` AND ` + notExpired
+	return cond, args
+}
+
+// queryHistory selects up to limit messages matching cond in id order
+// ("ASC" or "DESC").
+func queryHistory(db *sql.DB, cond string, args []interface{}, order string, limit int) ([]models.Message, error) {
+	rows, err := db.Query(`SELECT `+messageColumns+` FROM messages m WHERE `+cond+`
+		ORDER BY m.id `+order+` LIMIT ?`, append(args, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := []models.Message{}
+	for rows.Next() {
+		m, err := scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+	return messages, rows.Err()
+}
+
+// GetMessage loads a single message of roomID as seen by viewerID.
+func GetMessage(db *sql.DB, viewerID, roomID, messageID int64) (*models.Message, error) {
+	rows, err := db.Query(`SELECT `+messageColumns+` FROM messages m WHERE m.id = ? AND m.room_id = ? AND `+notExpired, messageID, roomID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, ErrMessageNotFound
+	}
+	m, err := scanMessage(rows)
+	if err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	msgs := []models.Message{m}
+	if err := Enrich(db, viewerID, msgs); err != nil {
+		return nil, err
+	}
+	return &msgs[0], nil
+}
+
+// Enrich attaches everything history responses show next to the message
+// rows themselves, as seen by viewerID: the reaction summaries, poll
+// tallies, link previews and attachments with fresh download URLs.
+func Enrich(db *sql.DB, viewerID int64, msgs []models.Message) error {
+	ids := make([]int64, 0, len(msgs))
+	for _, m := range msgs {
+		ids = append(ids, m.ID)
+	}
+
+	reactions, err := loadReactions(db, viewerID, ids)
+	if err != nil {
+		return err
+	}
+	var pollIDs []int64
+	for _, m := range msgs {
+		if m.Kind == KindPoll {
+			pollIDs = append(pollIDs, m.ID)
+		}
+	}
+	polls, err := loadPolls(db, viewerID, pollIDs)
+	if err != nil {
+		return err
+	}
+	previews, err := loadPreviews(db, ids)
+	if err != nil {
+		return err
+	}
+	attachments, err := loadAttachments(db, ids)
+	if err != nil {
+		return err
+	}
+	for i := range msgs {
+		msgs[i].Reactions = reactions[msgs[i].ID]
+		msgs[i].Poll = polls[msgs[i].ID]
+		msgs[i].Previews = previews[msgs[i].ID]
+		msgs[i].Attachments = attachments[msgs[i].ID]
+	}
+	return nil
+}
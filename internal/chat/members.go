@@ -0,0 +1,54 @@
+package chat
+
+import (
+	"database/sql"
+	"time"
+
+	"convo/internal/models"
+)
+
+// Notification levels stored in room_members.notify_level.
+const (
+	NotifyAll      = "all"
+	NotifyMentions = "mentions"
+	NotifyNone     = "none"
+)
+
+// MutedForever is stored in muted_until when a member mutes a room without
+// an end time.
+var MutedForever = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+
+// ValidNotifyLevel reports whether level is one of the supported levels.
+func ValidNotifyLevel(level string) bool {
+	return level == NotifyAll || level == NotifyMentions || level == NotifyNone
+}
+
+// IsMember reports whether userID belongs to roomID.
+func IsMember(db *sql.DB, roomID, userID int64) (bool, error) {
+	var found int
+	err := db.QueryRow("SELECT 1 FROM room_members WHERE room_id = ? AND user_id = ?", roomID, userID).Scan(&found)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// GetMember loads the membership row of userID in roomID. It returns
+// sql.ErrNoRows when the user is not a member.
+func GetMember(db *sql.DB, roomID, userID int64) (*models.RoomMember, error) {
+	m := &models.RoomMember{RoomID: roomID, UserID: userID}
+	var mutedUntil sql.NullTime
+	err := db.QueryRow(`SELECT joined_at, role, muted_until, notify_level, pinned, last_read_message_id, last_delivered_message_id
+		FROM room_members WHERE room_id = ? AND user_id = ?`, roomID, userID).
+		Scan(&m.JoinedAt, &m.Role, &mutedUntil, &m.NotifyLevel, &m.Pinned, &m.LastReadMessageID, &m.LastDeliveredID)
+	if err != nil {
+		return nil, err
+	}
+	if mutedUntil.Valid {
+		m.MutedUntil = &mutedUntil.Time
+	}
+	return m, nil
+}
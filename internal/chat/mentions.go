@@ -0,0 +1,166 @@
+package chat
+
+import (
+	"database/sql"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"convo/internal/ws"
+)
+
+// Mention kinds, stored in message_mentions.kind.
+const (
+	MentionUser = "user" // @name
+	MentionHere = "here" // @here: members online right now
+	MentionRoom = "room" // @room: every member
+)
+
+// mentionSpec is what a message's text asks for, before it is resolved
+// against the room's members.
+type mentionSpec struct {
+	names []string // lower-cased member names after an '@'
+	here  bool
+	room  bool
+}
+
+// parseMentions finds the @mentions in content. A mention starts with '@' at
+// the start of the text or after a character that cannot be part of a word
+// (so e-mail addresses do not count), and must end at a word boundary.
+// Member names may contain spaces, so candidate names are matched against
+// the text rather than split from it; the longest name wins.
+func parseMentions(content string, names []string) mentionSpec {
+	var spec mentionSpec
+	lower := strings.ToLower(content)
+	for i := 0; i < len(lower); i++ {
+		if lower[i] != '@' {
+			continue
+		}
+		if i > 0 {
+			prev, _ := utf8.DecodeLastRuneInString(lower[:i])
+			if isWordRune(prev) {
+				continue
+			}
+		}
+		rest := lower[i+1:]
+		switch {
+		case wordPrefix(rest, "here"):
+			spec.here = true
+			continue
+		case wordPrefix(rest, "room"):
+			spec.room = true
+			continue
+		}
+		best := ""
+		for _, name := range names {
+			if len(name) > len(best) && wordPrefix(rest, name) {
+				best = name
+			}
+		}
+		if best != "" {
+			spec.names = append(spec.names, best)
+			i += len(best)
+		}
+	}
+	return spec
+}
+
+// wordPrefix reports whether s starts with word followed by a word boundary.
+func wordPrefix(s, word string) bool {
+	if word == "" || !strings.HasPrefix(s, word) {
+		return false
+	}
+	next, _ := utf8.DecodeRuneInString(s[len(word):])
+	return next == utf8.RuneError || !isWordRune(next)
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || r == '.' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// resolveMentions maps the mentions in content to members of roomID other
+// than senderID, with the kind each was mentioned by. An explicit @name
+// takes precedence over @here, which takes precedence over @room.
+func resolveMentions(tx *sql.Tx, roomID, senderID int64, content string) (map[int64]string, error) {
+	if !strings.Contains(content, "@") {
+		return nil, nil
+	}
+	rows, err := tx.Query(`SELECT rm.user_id, u.name FROM room_members rm
+		JOIN users u ON u.id = rm.user_id
+		WHERE rm.room_id = ? AND rm.user_id <> ?`, roomID, senderID)
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string][]int64)
+	var names []string
+	var members []int64
+	for rows.Next() {
+		var id int64
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := byName[name]; !ok && name != "" {
+			names = append(names, name)
+		}
+		byName[name] = append(byName[name], id)
+		members = append(members, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	spec := parseMentions(content, names)
+	mentioned := make(map[int64]string)
+	if spec.room {
+		for _, id := range members {
+			mentioned[id] = MentionRoom
+		}
+	}
+	if spec.here {
+		for id := range ws.Online(members) {
+			mentioned[id] = MentionHere
+		}
+	}
+	for _, name := range spec.names {
+		for _, id := range byName[name] {
+			mentioned[id] = MentionUser
+		}
+	}
+	return mentioned, nil
+}
+
+// storeMentions records the mentions of m and bumps the mentioned members'
+// unread_mentions badges. Members mentioned by name in a thread reply start
+// following the thread so they hear about the rest of it.
+func storeMentions(tx *sql.Tx, roomID, messageID int64, threadID *int64, mentioned map[int64]string) error {
+	for userID, kind := range mentioned {
+		if _, err := tx.Exec("INSERT IGNORE INTO message_mentions (message_id, user_id, room_id, kind) VALUES (?, ?, ?, ?)",
+			messageID, userID, roomID, kind); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("UPDATE room_members SET unread_mentions = unread_mentions + 1 WHERE room_id = ? AND user_id = ?",
+			roomID, userID); err != nil {
+			return err
+		}
+		if threadID != nil && kind == MentionUser {
+			if _, err := tx.Exec("INSERT IGNORE INTO thread_followers (message_id, user_id) VALUES (?, ?)", *threadID, userID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// recountMentions recomputes unread_mentions in roomID from the stored
+// mentions past each member's read cursor; userID 0 recounts every member.
+func recountMentions(e execer, roomID, userID int64) error {
+	_, err := e.Exec(`UPDATE room_members rm SET rm.unread_mentions = (
+		SELECT COUNT(*) FROM message_mentions mm
+		WHERE mm.room_id = rm.room_id AND mm.user_id = rm.user_id AND mm.message_id > rm.last_read_message_id)
+		WHERE rm.room_id = ? AND (? = 0 OR rm.user_id = ?)`, roomID, userID, userID)
+	return err
+}
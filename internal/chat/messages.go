@@ -0,0 +1,242 @@
+package chat
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"convo/internal/models"
+	"convo/internal/ws"
+)
+
+var (
+	// ErrMessageNotFound is returned when a message does not exist in the
+	// given room.
+	ErrMessageNotFound = errors.New("message not found")
+	// ErrForbidden is returned when the user may not act on a message.
+	ErrForbidden = errors.New("not allowed")
+	// ErrEditWindowClosed is returned when a message is too old to edit.
+	ErrEditWindowClosed = errors.New("edit window has closed")
+	// ErrEmptyContent is returned when a message would have no content.
+	ErrEmptyContent = errors.New("content required")
+	// ErrMessageDeleted is returned when acting on a deleted message.
+	ErrMessageDeleted = errors.New("message was deleted")
+	// ErrInvalidScope is returned for an unknown deletion scope.
+	ErrInvalidScope = errors.New("scope must be me or everyone")
+)
+
+// Deletion scopes accepted by DeleteMessage.
+const (
+	DeleteForMe       = "me"
+	DeleteForEveryone = "everyone"
+)
+
+// EditWindow is how long after sending a message its sender may edit it.
+// Zero allows editing forever. It is replaced from config at startup.
+var EditWindow = 15 * time.Minute
+
+// Broadcast sends event to every connection in the room hub.
+func Broadcast(roomID int64, event interface{}) {
+	b, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	ws.GetRoomHub(roomID).Broadcast <- b
+}
+
+// EditMessage replaces the content of messageID on behalf of its sender,
+// keeping the previous version in message_edits, and broadcasts a
+// "message_edited" event to the room.
+func EditMessage(db *sql.DB, roomID, messageID, userID int64, content string) (*models.Message, error) {
+	if content == "" {
+		return nil, ErrEmptyContent
+	}
+	if _, err := GetRole(db, roomID, userID); err != nil {
+		return nil, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	m := &models.Message{ID: messageID, RoomID: roomID}
+	var age int64
+	var editedAt, deletedAt sql.NullTime
+	err = tx.QueryRow(`SELECT sender_id, content, format, kind, sent_at, edited_at, deleted_at, TIMESTAMPDIFF(SECOND, sent_at, NOW())
+		FROM messages WHERE id = ? AND room_id = ? FOR UPDATE`, messageID, roomID).
+		Scan(&m.SenderID, &m.Content, &m.Format, &m.Kind, &m.SentAt, &editedAt, &deletedAt, &age)
+	if err == sql.ErrNoRows {
+		return nil, ErrMessageNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	// system messages are attributed to whoever triggered them but are
+	// not theirs to reword, and rewording a poll would change what was
+	// voted on
+	if m.SenderID != userID || m.Kind != KindText {
+		return nil, ErrForbidden
+	}
+	if deletedAt.Valid {
+		return nil, ErrMessageDeleted
+	}
+	if EditWindow > 0 && time.Duration(age)*time.Second > EditWindow {
+		return nil, ErrEditWindowClosed
+	}
+	if m.Content == content {
+		// nothing changed, don't record a version
+		if editedAt.Valid {
+			m.Edited = true
+			m.EditedAt = &editedAt.Time
+		}
+		return m, nil
+	}
+
+	if _, err := tx.Exec("INSERT INTO message_edits (message_id, editor_id, previous_content) VALUES (?, ?, ?)", messageID, userID, m.Content); err != nil {
+		return nil, err
+	}
+	rich, err := renderRich(tx, roomID, m.Format, content)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if _, err := tx.Exec("UPDATE messages SET content = ?, rich = ?, edited_at = ? WHERE id = ?", content, nullJSON(rich), now, messageID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	m.Content = content
+	m.Rich = rich
+	m.Edited = true
+	m.EditedAt = &now
+	// the index keeps whether the message has attachments
+	if atts, err := loadAttachments(db, []int64{messageID}); err == nil {
+		m.Attachments = atts[messageID]
+	}
+	indexMessage(m)
+	event := map[string]interface{}{
+		"type":       "message_edited",
+		"room_id":    roomID,
+		"message_id": messageID,
+		"content":    content,
+		"format":     m.Format,
+		"edited_at":  now,
+	}
+	if rich != nil {
+		event["rich"] = m.Rich
+	}
+	Broadcast(roomID, event)
+	go unfurlMessage(db, m, true)
+	return m, nil
+}
+
+// DeleteMessage removes messageID. With DeleteForEveryone the content is
+// replaced by a tombstone for all members, which only the sender or a room
+// moderator may do, and "message_deleted" is broadcast to the room. With
+// DeleteForMe the message is only hidden from userID's own history and
+// their other connections are told via "message_hidden".
+func DeleteMessage(db *sql.DB, roomID, messageID, userID int64, scope string) error {
+	role, err := GetRole(db, roomID, userID)
+	if err != nil {
+		return err
+	}
+
+	if scope == DeleteForMe {
+		var found int
+		err := db.QueryRow("SELECT 1 FROM messages WHERE id = ? AND room_id = ?", messageID, roomID).Scan(&found)
+		if err == sql.ErrNoRows {
+			return ErrMessageNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := db.Exec("INSERT IGNORE INTO message_hidden (message_id, user_id) VALUES (?, ?)", messageID, userID); err != nil {
+			return err
+		}
+		b, _ := json.Marshal(map[string]interface{}{"type": "message_hidden", "room_id": roomID, "message_id": messageID})
+		ws.SendToUser(userID, 0, b)
+		return nil
+	}
+	if scope != DeleteForEveryone {
+		return ErrInvalidScope
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var senderID int64
+	var deletedAt sql.NullTime
+	err = tx.QueryRow("SELECT sender_id, deleted_at FROM messages WHERE id = ? AND room_id = ? FOR UPDATE", messageID, roomID).
+		Scan(&senderID, &deletedAt)
+	if err == sql.ErrNoRows {
+		return ErrMessageNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if senderID != userID && !IsModerator(role) {
+		return ErrForbidden
+	}
+	if deletedAt.Valid {
+		return nil
+	}
+
+	now := time.Now()
+	if _, err := tx.Exec("UPDATE messages SET content = '', rich = NULL, deleted_at = ?, deleted_by = ? WHERE id = ?", now, userID, messageID); err != nil {
+		return err
+	}
+	// earlier versions would leak the deleted text
+	if _, err := tx.Exec("DELETE FROM message_edits WHERE message_id = ?", messageID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM message_reactions WHERE message_id = ?", messageID); err != nil {
+		return err
+	}
+	// replies quoting it must not keep showing the text either
+	if _, err := tx.Exec("UPDATE messages SET reply_snippet = NULL WHERE reply_to_id = ?", messageID); err != nil {
+		return err
+	}
+	// a deleted message cannot stay pinned
+	if _, err := tx.Exec("DELETE FROM room_pins WHERE message_id = ?", messageID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM message_link_previews WHERE message_id = ?", messageID); err != nil {
+		return err
+	}
+	blobs, err := deleteAttachments(tx, []int64{messageID})
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE messages SET has_attachment = FALSE WHERE id = ?", messageID); err != nil {
+		return err
+	}
+	// nor should anyone still be badged for a mention in it
+	if _, err := tx.Exec("DELETE FROM message_mentions WHERE message_id = ?", messageID); err != nil {
+		return err
+	}
+	if err := recountMentions(tx, roomID, 0); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	removeBlobs(blobs)
+
+	Broadcast(roomID, map[string]interface{}{
+		"type":       "message_deleted",
+		"room_id":    roomID,
+		"message_id": messageID,
+		"deleted_by": userID,
+		"deleted_at": now,
+	})
+	unindexMessage(messageID)
+	return nil
+}
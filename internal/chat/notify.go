@@ -0,0 +1,114 @@
+package chat
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"time"
+
+	"convo/internal/ws"
+)
+
+// previewLen caps the message text carried in a notification event.
+const previewLen = 120
+
+// Notification describes a new message that members may need to hear about.
+type Notification struct {
+	RoomID    int64
+	MessageID int64
+	SenderID  int64
+	Content   string
+	// Mentioned holds the members explicitly mentioned by the message; they
+	// are notified even when their level is "mentions".
+	Mentioned map[int64]bool
+	// MentionsOnly limits the recipients to Mentioned members, for thread
+	// replies whose other readers hear about them through NotifyThread.
+	MentionsOnly bool
+}
+
+// Recipients returns the members of n.RoomID that should be notified about
+// n, honouring each member's mute and notification level settings.
+func Recipients(db *sql.DB, n Notification) ([]int64, error) {
+	rows, err := db.Query(`SELECT user_id, muted_until, notify_level FROM room_members
+		WHERE room_id = ? AND user_id <> ?`, n.RoomID, n.SenderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	now := time.Now()
+	var ids []int64
+	for rows.Next() {
+		var userID int64
+		var mutedUntil sql.NullTime
+		var level string
+		if err := rows.Scan(&userID, &mutedUntil, &level); err != nil {
+			return nil, err
+		}
+		if mutedUntil.Valid && mutedUntil.Time.After(now) {
+			continue
+		}
+		if n.MentionsOnly && !n.Mentioned[userID] {
+			continue
+		}
+		switch level {
+		case NotifyNone:
+			continue
+		case NotifyMentions:
+			if !n.Mentioned[userID] {
+				continue
+			}
+		}
+		ids = append(ids, userID)
+	}
+	return ids, rows.Err()
+}
+
+// Notify pushes a "notification" event to the live connections that
+// recipients have open in other rooms; connections in the message's own room
+// already receive the message itself through the room hub.
+func Notify(db *sql.DB, n Notification) {
+	ids, err := Recipients(db, n)
+	if err != nil {
+		log.Printf("notify room %d message %d: %v", n.RoomID, n.MessageID, err)
+		return
+	}
+	preview := n.Content
+	if r := []rune(preview); len(r) > previewLen {
+		preview = string(r[:previewLen]) + "…"
+	}
+	for _, userID := range ids {
+		b, _ := json.Marshal(map[string]interface{}{
+			"type":       "notification",
+			"room_id":    n.RoomID,
+			"message_id": n.MessageID,
+			"sender_id":  n.SenderID,
+			"preview":    preview,
+			"mentioned":  n.Mentioned[userID],
+		})
+		ws.SendToUser(userID, n.RoomID, b)
+	}
+}
+
+// MarkRead moves the read (and delivery) cursor of userID in roomID forward
+// to messageID, clears the mentions it passed and starts the clock of
+// disappearing messages it passed. Cursors never move backwards.
+func MarkRead(db *sql.DB, roomID, userID, messageID int64) error {
+	var from int64
+	if err := db.QueryRow("SELECT last_read_message_id FROM room_members WHERE room_id = ? AND user_id = ?", roomID, userID).Scan(&from); err != nil {
+		return err
+	}
+	if _, err := db.Exec(`UPDATE room_members SET
+		last_read_message_id = GREATEST(last_read_message_id, ?),
+		last_delivered_message_id = GREATEST(last_delivered_message_id, ?)
+		WHERE room_id = ? AND user_id = ?`, messageID, messageID, roomID, userID); err != nil {
+		return err
+	}
+	if err := recountMentions(db, roomID, userID); err != nil {
+		return err
+	}
+	if messageID <= from {
+		return nil
+	}
+	return startExpiry(db, roomID, userID, from, messageID)
+}
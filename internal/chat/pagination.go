@@ -0,0 +1,179 @@
+package chat
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+
+	"convo/internal/models"
+)
+
+// ErrInvalidCursor is returned for a cursor this server did not hand out.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// PageQuery positions a page of history. At most one of BeforeID, AfterID
+// and AroundID is set; with none the page ends at the newest message.
+type PageQuery struct {
+	HistoryQuery
+	// AfterID pages forwards from (and excluding) that message id.
+	AfterID int64
+	// AroundID centres the page on that message, which is included. A
+	// thread reply asked for in the top-level history centres on its
+	// thread's root instead.
+	AroundID int64
+}
+
+// HistoryPage is one page of history, newest first, with whether more
+// messages exist on either side and cursors to fetch them.
+type HistoryPage struct {
+	Messages     []models.Message `json:"messages"`
+	HasMoreOlder bool             `json:"has_more_before"`
+	HasMoreNewer bool             `json:"has_more_after"`
+	OlderCursor  string           `json:"before_cursor,omitempty"`
+	NewerCursor  string           `json:"after_cursor,omitempty"`
+}
+
+// Cursor directions, as decoded by DecodeCursor.
+const (
+	CursorBefore = "b"
+	CursorAfter  = "a"
+)
+
+// EncodeCursor returns an opaque cursor for paging in dir from messageID.
+func EncodeCursor(dir string, messageID int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(dir + ":" + strconv.FormatInt(messageID, 10)))
+}
+
+// DecodeCursor reverses EncodeCursor.
+func DecodeCursor(cursor string) (dir string, messageID int64, err error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", 0, ErrInvalidCursor
+	}
+	dir, id, ok := strings.Cut(string(b), ":")
+	if !ok || (dir != CursorBefore && dir != CursorAfter) {
+		return "", 0, ErrInvalidCursor
+	}
+	messageID, err = strconv.ParseInt(id, 10, 64)
+	if err != nil || messageID <= 0 {
+		return "", 0, ErrInvalidCursor
+	}
+	return dir, messageID, nil
+}
+
+// Page returns the page of history q selects as seen by viewerID (see
+// History).
+func Page(db *sql.DB, viewerID int64, q PageQuery) (*HistoryPage, error) {
+	scope, args := historyScope(viewerID, q.HistoryQuery)
+	with := func(cond string, v interface{}) (string, []interface{}) {
+		a := append(append([]interface{}{}, args...), v)
+		return scope + cond, a
+	}
+
+	var messages []models.Message
+	var err error
+	// lo and hi bound the positions looked at, for the has_more checks
+	var lo, hi int64
+	switch {
+	case q.AroundID != 0:
+		anchor, err := pageAnchor(db, q)
+		if err != nil {
+			return nil, err
+		}
+		older := q.Limit / 2
+		cond, a := with(` AND m.id >= ?`, anchor)
+		newer, err := queryHistory(db, cond, a, "ASC", q.Limit-older)
+		if err != nil {
+			return nil, err
+		}
+		cond, a = with(` AND m.id < ?`, anchor)
+		messages, err = queryHistory(db, cond, a, "DESC", older)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(reversed(newer), messages...)
+		lo, hi = anchor, anchor-1
+	case q.AfterID != 0:
+		cond, a := with(` AND m.id > ?`, q.AfterID)
+		messages, err = queryHistory(db, cond, a, "ASC", q.Limit)
+		if err != nil {
+			return nil, err
+		}
+		messages = reversed(messages)
+		lo, hi = q.AfterID+1, q.AfterID
+	case q.BeforeID != 0:
+		cond, a := with(` AND m.id < ?`, q.BeforeID)
+		messages, err = queryHistory(db, cond, a, "DESC", q.Limit)
+		if err != nil {
+			return nil, err
+		}
+		lo, hi = q.BeforeID, q.BeforeID-1
+	default:
+		messages, err = queryHistory(db, scope, args, "DESC", q.Limit)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := Enrich(db, viewerID, messages); err != nil {
+		return nil, err
+	}
+
+	p := &HistoryPage{Messages: messages}
+	if len(messages) > 0 {
+		hi, lo = messages[0].ID, messages[len(messages)-1].ID
+	} else if lo == 0 {
+		// the room is empty
+		return p, nil
+	}
+	if p.HasMoreOlder, err = historyExists(db, scope, args, ` AND m.id < ?`, lo); err != nil {
+		return nil, err
+	}
+	if p.HasMoreNewer, err = historyExists(db, scope, args, ` AND m.id > ?`, hi); err != nil {
+		return nil, err
+	}
+	if p.HasMoreOlder {
+		p.OlderCursor = EncodeCursor(CursorBefore, lo)
+	}
+	if p.HasMoreNewer {
+		p.NewerCursor = EncodeCursor(CursorAfter, hi)
+	}
+	return p, nil
+}
+
+// pageAnchor resolves q.AroundID to the message id the page centres on.
+func pageAnchor(db *sql.DB, q PageQuery) (int64, error) {
+	var threadID sql.NullInt64
+	err := db.QueryRow("SELECT thread_id FROM messages WHERE id = ? AND room_id = ?", q.AroundID, q.RoomID).Scan(&threadID)
+	if err == sql.ErrNoRows {
+		return 0, ErrMessageNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	if q.ThreadID == 0 && threadID.Valid {
+		return threadID.Int64, nil
+	}
+	if q.ThreadID != 0 && q.AroundID != q.ThreadID && threadID.Int64 != q.ThreadID {
+		return 0, ErrMessageNotFound
+	}
+	return q.AroundID, nil
+}
+
+// historyExists reports whether any message in scope matches cond with id.
+func historyExists(db *sql.DB, scope string, args []interface{}, cond string, id int64) (bool, error) {
+	var found int
+	err := db.QueryRow(`SELECT 1 FROM messages m WHERE `+scope+cond+` LIMIT 1`, append(append([]interface{}{}, args...), id)...).Scan(&found)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func reversed(msgs []models.Message) []models.Message {
+	for i, j := 0, len(msgs)-1; i < j; i, j = i+1, j-1 {
+		msgs[i], msgs[j] = msgs[j], msgs[i]
+	}
+	return msgs
+}
@@ -0,0 +1,207 @@
+package chat
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"convo/internal/models"
+)
+
+// Message kinds, stored in messages.kind.
+const (
+	KindText   = "text"
+	KindSystem = "system"
+	KindPoll   = "poll"
+)
+
+// MaxPins caps the pinned messages per room; 0 means no limit. It is
+// replaced from config at startup.
+var MaxPins = 50
+
+// ErrTooManyPins is returned when a room already has MaxPins pins.
+var ErrTooManyPins = errors.New("too many pinned messages in this room")
+
+// Pin is a pinned message with who pinned it and when.
+type Pin struct {
+	Message  models.Message `json:"message"`
+	PinnedBy int64          `json:"pinned_by"`
+	PinnedAt time.Time      `json:"pinned_at"`
+}
+
+// PinMessage pins messageID in roomID on behalf of moderator userID. It
+// broadcasts "message_pinned" and posts a system message quoting the pinned
+// message. Pinning a pinned message again is a no-op.
+func PinMessage(db *sql.DB, roomID, messageID, userID int64) error {
+	if err := pinTarget(db, roomID, userID); err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// lock the room so concurrent pins cannot both slip under MaxPins
+	var n int
+	if err := tx.QueryRow("SELECT id FROM rooms WHERE id = ? FOR UPDATE", roomID).Scan(&n); err != nil {
+		return err
+	}
+	var kind string
+	var deletedAt sql.NullTime
+	err = tx.QueryRow("SELECT kind, deleted_at FROM messages WHERE id = ? AND room_id = ?", messageID, roomID).Scan(&kind, &deletedAt)
+	if err == sql.ErrNoRows || kind == KindSystem {
+		return ErrMessageNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if deletedAt.Valid {
+		return ErrMessageDeleted
+	}
+	if err := tx.QueryRow("SELECT COUNT(*) FROM room_pins WHERE room_id = ?", roomID).Scan(&n); err != nil {
+		return err
+	}
+	if MaxPins > 0 && n >= MaxPins {
+		return ErrTooManyPins
+	}
+	res, err := tx.Exec("INSERT IGNORE INTO room_pins (room_id, message_id, pinned_by) VALUES (?, ?, ?)", roomID, messageID, userID)
+	if err != nil {
+		return err
+	}
+	if added, _ := res.RowsAffected(); added == 0 {
+		return nil
+	}
+	sys, err := insertSystemMessage(tx, roomID, userID, "pinned a message", messageID)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	Broadcast(roomID, map[string]interface{}{
+		"type":       "message_pinned",
+		"room_id":    roomID,
+		"message_id": messageID,
+		"pinned_by":  userID,
+	})
+	afterSend(db, sys, nil)
+	return nil
+}
+
+// UnpinMessage unpins messageID in roomID on behalf of moderator userID,
+// broadcasting "message_unpinned" and posting a system message. Unpinning
+// a message that is not pinned is a no-op.
+func UnpinMessage(db *sql.DB, roomID, messageID, userID int64) error {
+	if err := pinTarget(db, roomID, userID); err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("DELETE FROM room_pins WHERE room_id = ? AND message_id = ?", roomID, messageID)
+	if err != nil {
+		return err
+	}
+	if removed, _ := res.RowsAffected(); removed == 0 {
+		return nil
+	}
+	sys, err := insertSystemMessage(tx, roomID, userID, "unpinned a message", messageID)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	Broadcast(roomID, map[string]interface{}{
+		"type":        "message_unpinned",
+		"room_id":     roomID,
+		"message_id":  messageID,
+		"unpinned_by": userID,
+	})
+	afterSend(db, sys, nil)
+	return nil
+}
+
+// pinTarget checks that userID moderates roomID.
+func pinTarget(db *sql.DB, roomID, userID int64) error {
+	role, err := GetRole(db, roomID, userID)
+	if err != nil {
+		return err
+	}
+	if !IsModerator(role) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// insertSystemMessage stores a system notice in roomID attributed to
+// actorID, quoting refID like an inline reply. The caller commits tx and
+// then passes the message to afterSend.
+func insertSystemMessage(tx *sql.Tx, roomID, actorID int64, content string, refID int64) (*models.Message, error) {
+	m := &models.Message{RoomID: roomID, SenderID: actorID, Content: content, Kind: KindSystem}
+	ref, err := quote(tx, roomID, refID)
+	if err != nil {
+		return nil, err
+	}
+	m.ReplyTo = ref
+	result, err := tx.Exec(`INSERT INTO messages (room_id, sender_id, content, kind, reply_to_id, reply_sender_id, reply_snippet)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`, roomID, actorID, content, KindSystem, refID, *ref.SenderID, ref.Snippet)
+	if err != nil {
+		return nil, err
+	}
+	m.ID, _ = result.LastInsertId()
+	if err := tx.QueryRow("SELECT sent_at FROM messages WHERE id = ?", m.ID).Scan(&m.SentAt); err != nil {
+		return nil, err
+	}
+	if err := insertMeta(tx, m, false); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Pins lists the pinned messages of roomID, most recently pinned first, as
+// seen by viewerID: messages the viewer hid and expired messages are left
+// out.
+func Pins(db *sql.DB, viewerID, roomID int64) ([]Pin, error) {
+	rows, err := db.Query(`SELECT `+messageColumns+`, p.pinned_by, p.pinned_at FROM room_pins p
+		JOIN messages m ON m.id = p.message_id
+		WHERE p.room_id = ? AND `+notExpired+`
+			AND NOT EXISTS (SELECT 1 FROM message_hidden h WHERE h.message_id = m.id AND h.user_id = ?)
+		ORDER BY p.pinned_at DESC, m.id DESC`, roomID, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pins := []Pin{}
+	for rows.Next() {
+		var p Pin
+		if p.Message, err = scanMessage(rows, &p.PinnedBy, &p.PinnedAt); err != nil {
+			return nil, err
+		}
+		pins = append(pins, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	msgs := make([]models.Message, len(pins))
+	for i := range pins {
+		msgs[i] = pins[i].Message
+	}
+	if err := Enrich(db, viewerID, msgs); err != nil {
+		return nil, err
+	}
+	for i := range pins {
+		pins[i].Message = msgs[i]
+	}
+	return pins, nil
+}
@@ -0,0 +1,75 @@
+package chat
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+)
+
+// pinnedRoom answers the Pins query for room 1, where message 3 is hidden
+// by user 7 and message 2 has expired. It applies the hidden and expiry
+// filters only when the query asks for them, binding the viewer from the
+// query's arguments.
+func pinnedRoom(query string, args []driver.Value) (fakeResult, error) {
+	if !strings.Contains(query, "FROM room_pins p") {
+		return fakeResult{}, nil
+	}
+	past, future := time.Now().Add(-time.Minute), time.Now().Add(time.Hour)
+	pins := []struct {
+		id       int64
+		hiddenBy int64
+		expires  *time.Time
+	}{
+		{4, 0, &future},
+		{3, 7, nil},
+		{2, 0, &past},
+		{1, 0, nil},
+	}
+	filterExpired := strings.Contains(query, notExpired)
+	hidden := "NOT EXISTS (SELECT 1 FROM message_hidden h WHERE h.message_id = m.id AND h.user_id = ?)"
+	filterHidden := strings.Contains(query, hidden) && len(args) == 2
+
+	var res fakeResult
+	for _, p := range pins {
+		if filterExpired && p.expires != nil && p.expires.Before(time.Now()) {
+			continue
+		}
+		if filterHidden && args[1] == p.hiddenBy {
+			continue
+		}
+		res.rows = append(res.rows, messageRow(p.id, 1, 5, "pinned", p.expires, int64(5), time.Now()))
+	}
+	return res, nil
+}
+
+func TestPinsLeavesOutHiddenAndExpired(t *testing.T) {
+	db, _ := openFake(t, pinnedRoom)
+	tests := []struct {
+		viewer int64
+		want   []int64
+	}{
+		{7, []int64{4, 1}},
+		{8, []int64{4, 3, 1}},
+	}
+	for _, tt := range tests {
+		pins, err := Pins(db, tt.viewer, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make([]int64, len(pins))
+		for i, p := range pins {
+			got[i] = p.Message.ID
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("viewer %d: got pins %v, want %v", tt.viewer, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("viewer %d: got pins %v, want %v", tt.viewer, got, tt.want)
+				break
+			}
+		}
+	}
+}
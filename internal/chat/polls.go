@@ -0,0 +1,309 @@
+package chat
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"convo/internal/models"
+)
+
+// Poll limits.
+const (
+	MinPollOptions   = 2
+	MaxPollOptions   = 10
+	maxPollOptionLen = 100
+	maxQuestionLen   = 300
+)
+
+var (
+	// ErrInvalidPoll is returned for a poll with a bad question, too few or
+	// too many options, or a close time in the past.
+	ErrInvalidPoll = errors.New("a poll needs a question, 2-10 distinct options of up to 100 characters and a future close time")
+	// ErrPollClosed is returned when voting on a closed poll.
+	ErrPollClosed = errors.New("poll is closed")
+	// ErrInvalidVote is returned for options not on the poll, or more than
+	// one option on a single-choice poll.
+	ErrInvalidVote = errors.New("invalid poll options")
+)
+
+// PollSpec describes a poll to create.
+type PollSpec struct {
+	Options     []string
+	MultiChoice bool
+	Anonymous   bool
+	ClosesAt    *time.Time
+}
+
+// CreatePoll posts a poll asking req.Content. It is sent like any other
+// message (see SendMessage) and broadcast with its empty tally.
+func CreatePoll(db *sql.DB, req SendRequest, spec PollSpec) (*models.Message, error) {
+	req.Content = strings.TrimSpace(req.Content)
+	if req.Content == "" || len([]rune(req.Content)) > maxQuestionLen ||
+		len(spec.Options) < MinPollOptions || len(spec.Options) > MaxPollOptions ||
+		(spec.ClosesAt != nil && !spec.ClosesAt.After(time.Now())) {
+		return nil, ErrInvalidPoll
+	}
+	seen := make(map[string]bool)
+	for i, o := range spec.Options {
+		o = strings.TrimSpace(o)
+		if o == "" || len([]rune(o)) > maxPollOptionLen || seen[strings.ToLower(o)] {
+			return nil, ErrInvalidPoll
+		}
+		seen[strings.ToLower(o)] = true
+		spec.Options[i] = o
+	}
+	if !ValidTTL(req.TTLSeconds) {
+		return nil, ErrInvalidTTL
+	}
+	if err := CheckSend(db, req.RoomID, req.SenderID); err != nil {
+		return nil, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	req.kind = KindPoll
+	m, mentioned, err := storeMessage(tx, req)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("INSERT INTO polls (message_id, multi_choice, anonymous, closes_at) VALUES (?, ?, ?, ?)",
+		m.ID, spec.MultiChoice, spec.Anonymous, spec.ClosesAt); err != nil {
+		return nil, err
+	}
+	for i, o := range spec.Options {
+		if _, err := tx.Exec("INSERT INTO poll_options (message_id, position, text) VALUES (?, ?, ?)", m.ID, i, o); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	polls, err := loadPolls(db, 0, []int64{m.ID})
+	if err != nil {
+		return nil, err
+	}
+	m.Poll = polls[m.ID]
+	afterSend(db, m, mentioned)
+	draftSent(db, m)
+	return m, nil
+}
+
+// pollTarget checks that messageID is a poll in roomID; it returns whether
+// the poll is closed, who created it and whether it is multiple choice.
+func pollTarget(q querier, roomID, messageID int64, lock bool) (closed bool, senderID int64, multi bool, err error) {
+	query := `SELECT m.sender_id, p.multi_choice,
+		p.closed_at IS NOT NULL OR (p.closes_at IS NOT NULL AND p.closes_at <= NOW())
+		FROM polls p JOIN messages m ON m.id = p.message_id
+		WHERE p.message_id = ? AND m.room_id = ? AND m.deleted_at IS NULL`
+	if lock {
+		query += ` FOR UPDATE`
+	}
+	err = q.QueryRow(query, messageID, roomID).Scan(&senderID, &multi, &closed)
+	if err == sql.ErrNoRows {
+		return false, 0, false, ErrMessageNotFound
+	}
+	return closed, senderID, multi, err
+}
+
+// Vote sets userID's votes on the poll messageID to optionIDs, replacing
+// any earlier votes; no options withdraws them. The new tally is
+// broadcast as "poll_updated".
+func Vote(db *sql.DB, roomID, messageID, userID int64, optionIDs []int64) (*models.Poll, error) {
+	if _, err := GetRole(db, roomID, userID); err != nil {
+		return nil, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	closed, _, multi, err := pollTarget(tx, roomID, messageID, true)
+	if err != nil {
+		return nil, err
+	}
+	if closed {
+		return nil, ErrPollClosed
+	}
+	uniq := make(map[int64]bool)
+	for _, id := range optionIDs {
+		uniq[id] = true
+	}
+	if len(uniq) > 1 && !multi {
+		return nil, ErrInvalidVote
+	}
+	if len(uniq) > 0 {
+		args := []interface{}{messageID}
+		for id := range uniq {
+			args = append(args, id)
+		}
+		var n int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM poll_options WHERE message_id = ? AND id IN (`+placeholders(len(uniq))+`)`, args...).Scan(&n); err != nil {
+			return nil, err
+		}
+		if n != len(uniq) {
+			return nil, ErrInvalidVote
+		}
+	}
+
+	if _, err := tx.Exec("DELETE FROM poll_votes WHERE message_id = ? AND user_id = ?", messageID, userID); err != nil {
+		return nil, err
+	}
+	for id := range uniq {
+		if _, err := tx.Exec("INSERT INTO poll_votes (option_id, user_id, message_id) VALUES (?, ?, ?)", id, userID, messageID); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	broadcastPoll(db, roomID, messageID, "poll_updated")
+	polls, err := loadPolls(db, userID, []int64{messageID})
+	if err != nil {
+		return nil, err
+	}
+	return polls[messageID], nil
+}
+
+// ClosePoll closes the poll messageID early; only its creator or a room
+// moderator may. The final tally is broadcast as "poll_closed".
+func ClosePoll(db *sql.DB, roomID, messageID, userID int64) (*models.Poll, error) {
+	role, err := GetRole(db, roomID, userID)
+	if err != nil {
+		return nil, err
+	}
+	closed, senderID, _, err := pollTarget(db, roomID, messageID, false)
+	if err != nil {
+		return nil, err
+	}
+	if senderID != userID && !IsModerator(role) {
+		return nil, ErrForbidden
+	}
+	if !closed {
+		if _, err := db.Exec("UPDATE polls SET closed_at = NOW() WHERE message_id = ? AND closed_at IS NULL", messageID); err != nil {
+			return nil, err
+		}
+		broadcastPoll(db, roomID, messageID, "poll_closed")
+	}
+	polls, err := loadPolls(db, userID, []int64{messageID})
+	if err != nil {
+		return nil, err
+	}
+	return polls[messageID], nil
+}
+
+// broadcastPoll sends the current tally of messageID to the room.
+func broadcastPoll(db *sql.DB, roomID, messageID int64, eventType string) {
+	polls, err := loadPolls(db, 0, []int64{messageID})
+	if err != nil || polls[messageID] == nil {
+		return
+	}
+	Broadcast(roomID, map[string]interface{}{
+		"type":       eventType,
+		"room_id":    roomID,
+		"message_id": messageID,
+		"poll":       polls[messageID],
+	})
+}
+
+// loadPolls returns the polls among ids with their tallies, keyed by
+// message id, marking the options viewerID voted for.
+func loadPolls(db *sql.DB, viewerID int64, ids []int64) (map[int64]*models.Poll, error) {
+	polls := make(map[int64]*models.Poll)
+	if len(ids) == 0 {
+		return polls, nil
+	}
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	in := placeholders(len(ids))
+
+	rows, err := db.Query(`SELECT message_id, multi_choice, anonymous, closes_at,
+		closed_at IS NOT NULL OR (closes_at IS NOT NULL AND closes_at <= NOW())
+		FROM polls WHERE message_id IN (`+in+`)`, args...)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var id int64
+		var closesAt sql.NullTime
+		p := &models.Poll{Options: []models.PollOption{}}
+		if err := rows.Scan(&id, &p.MultiChoice, &p.Anonymous, &closesAt, &p.Closed); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if closesAt.Valid {
+			p.ClosesAt = &closesAt.Time
+		}
+		polls[id] = p
+	}
+	rows.Close()
+	if len(polls) == 0 {
+		return polls, nil
+	}
+
+	rows, err = db.Query(`SELECT id, message_id, text FROM poll_options WHERE message_id IN (`+in+`) ORDER BY message_id, position`, args...)
+	if err != nil {
+		return nil, err
+	}
+	index := make(map[int64]*models.PollOption)
+	for rows.Next() {
+		var o models.PollOption
+		var messageID int64
+		if err := rows.Scan(&o.ID, &messageID, &o.Text); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if p := polls[messageID]; p != nil {
+			p.Options = append(p.Options, o)
+		}
+	}
+	rows.Close()
+	for _, p := range polls {
+		for i := range p.Options {
+			index[p.Options[i].ID] = &p.Options[i]
+		}
+	}
+
+	rows, err = db.Query(`SELECT option_id, message_id, user_id FROM poll_votes WHERE message_id IN (`+in+`) ORDER BY created_at, user_id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	voters := make(map[int64]map[int64]bool)
+	for rows.Next() {
+		var optionID, messageID, userID int64
+		if err := rows.Scan(&optionID, &messageID, &userID); err != nil {
+			return nil, err
+		}
+		o, p := index[optionID], polls[messageID]
+		if o == nil || p == nil {
+			continue
+		}
+		o.Votes++
+		if userID == viewerID {
+			o.Me = true
+		}
+		if !p.Anonymous {
+			o.Voters = append(o.Voters, userID)
+		}
+		if voters[messageID] == nil {
+			voters[messageID] = make(map[int64]bool)
+		}
+		voters[messageID][userID] = true
+	}
+	for id, p := range polls {
+		p.TotalVoters = len(voters[id])
+	}
+	return polls, rows.Err()
+}
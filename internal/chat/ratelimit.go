@@ -0,0 +1,76 @@
+package chat
+
+import (
+	"sync"
+	"time"
+)
+
+// RateLimiter is an in-memory token bucket per user. Each user may burst up
+// to Limit actions, refilled evenly over Window.
+type RateLimiter struct {
+	Limit  int
+	Window time.Duration
+
+	mu      sync.Mutex
+	buckets map[int64]*bucket
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter returns a limiter allowing limit actions per window. A
+// non-positive limit disables limiting.
+func NewRateLimiter(limit int, window time.Duration) *RateLimiter {
+	return &RateLimiter{Limit: limit, Window: window, buckets: make(map[int64]*bucket)}
+}
+
+// SendLimiter throttles message sends per user across all rooms. It is
+// replaced from config at startup.
+var SendLimiter = NewRateLimiter(20, 10*time.Second)
+
+// Allow consumes a token for userID. When none is left it returns false and
+// the time at which the next token becomes available.
+func (l *RateLimiter) Allow(userID int64) (bool, time.Time) {
+	now := time.Now()
+	if l == nil || l.Limit <= 0 || l.Window <= 0 {
+		return true, now
+	}
+	rate := float64(l.Limit) / float64(l.Window)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[userID]
+	if !ok {
+		l.sweep(now)
+		b = &bucket{tokens: float64(l.Limit), last: now}
+		l.buckets[userID] = b
+	}
+	b.tokens += float64(now.Sub(b.last)) * rate
+	if b.tokens > float64(l.Limit) {
+		b.tokens = float64(l.Limit)
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / rate)
+		return false, now.Add(wait)
+	}
+	b.tokens--
+	return true, now
+}
+
+// sweep drops buckets that have refilled completely so idle users do not
+// keep memory forever. Called with l.mu held.
+func (l *RateLimiter) sweep(now time.Time) {
+	if len(l.buckets) < 1024 {
+		return
+	}
+	for id, b := range l.buckets {
+		if now.Sub(b.last) >= l.Window {
+			delete(l.buckets, id)
+		}
+	}
+}
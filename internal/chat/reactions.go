@@ -0,0 +1,164 @@
+package chat
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"unicode"
+
+	"convo/internal/models"
+)
+
+// maxReactionsPerUser caps the distinct emojis one user can put on a
+// message.
+const maxReactionsPerUser = 20
+
+var (
+	// ErrInvalidEmoji is returned for an empty, too long or blank emoji.
+	ErrInvalidEmoji = errors.New("invalid emoji")
+	// ErrTooManyReactions is returned when a user hits maxReactionsPerUser.
+	ErrTooManyReactions = errors.New("too many reactions on this message")
+)
+
+// validEmoji accepts short strings without whitespace or control
+// characters; it deliberately does not try to police what counts as an
+// emoji so custom shortcodes like ":shipit:" work too.
+func validEmoji(emoji string) bool {
+	if emoji == "" || len(emoji) > 64 {
+		return false
+	}
+	for _, r := range emoji {
+		if unicode.IsSpace(r) || unicode.IsControl(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// reactionTarget checks that userID may react to messageID in roomID.
+func reactionTarget(db *sql.DB, roomID, messageID, userID int64, emoji string) error {
+	if !validEmoji(emoji) {
+		return ErrInvalidEmoji
+	}
+	if _, err := GetRole(db, roomID, userID); err != nil {
+		return err
+	}
+	var deletedAt sql.NullTime
+	err := db.QueryRow("SELECT deleted_at FROM messages WHERE id = ? AND room_id = ?", messageID, roomID).Scan(&deletedAt)
+	if err == sql.ErrNoRows {
+		return ErrMessageNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if deletedAt.Valid {
+		return ErrMessageDeleted
+	}
+	return nil
+}
+
+// AddReaction adds emoji from userID to messageID and broadcasts
+// "reaction_added" with the new count. Adding the same reaction twice is a
+// no-op.
+func AddReaction(db *sql.DB, roomID, messageID, userID int64, emoji string) error {
+	emoji = strings.TrimSpace(emoji)
+	if err := reactionTarget(db, roomID, messageID, userID, emoji); err != nil {
+		return err
+	}
+
+	var mine int
+	if err := db.QueryRow("SELECT COUNT(*) FROM message_reactions WHERE message_id = ? AND user_id = ?", messageID, userID).Scan(&mine); err != nil {
+		return err
+	}
+	if mine >= maxReactionsPerUser {
+		return ErrTooManyReactions
+	}
+
+	res, err := db.Exec("INSERT IGNORE INTO message_reactions (message_id, user_id, emoji) VALUES (?, ?, ?)", messageID, userID, emoji)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil
+	}
+	return reactionChanged(db, roomID, messageID, userID, emoji, "reaction_added")
+}
+
+// RemoveReaction removes emoji from userID on messageID and broadcasts
+// "reaction_removed" with the new count.
+func RemoveReaction(db *sql.DB, roomID, messageID, userID int64, emoji string) error {
+	emoji = strings.TrimSpace(emoji)
+	if err := reactionTarget(db, roomID, messageID, userID, emoji); err != nil {
+		return err
+	}
+
+	res, err := db.Exec("DELETE FROM message_reactions WHERE message_id = ? AND user_id = ? AND emoji = ?", messageID, userID, emoji)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil
+	}
+	return reactionChanged(db, roomID, messageID, userID, emoji, "reaction_removed")
+}
+
+// reactionChanged mirrors userID's reactions into message_meta.reactions
+// and broadcasts the change with the emoji's new total.
+func reactionChanged(db *sql.DB, roomID, messageID, userID int64, emoji, eventType string) error {
+	if _, err := db.Exec(`INSERT INTO message_meta (message_id, user_id, reactions)
+		VALUES (?, ?, (SELECT JSON_ARRAYAGG(emoji) FROM message_reactions WHERE message_id = ? AND user_id = ?))
+		ON DUPLICATE KEY UPDATE reactions = VALUES(reactions)`, messageID, userID, messageID, userID); err != nil {
+		return err
+	}
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM message_reactions WHERE message_id = ? AND emoji = ?", messageID, emoji).Scan(&count); err != nil {
+		return err
+	}
+	Broadcast(roomID, map[string]interface{}{
+		"type":       eventType,
+		"room_id":    roomID,
+		"message_id": messageID,
+		"user_id":    userID,
+		"emoji":      emoji,
+		"count":      count,
+	})
+	return nil
+}
+
+// loadReactions returns the reaction summaries of messageIDs as seen by
+// viewerID, in the order each emoji was first used.
+func loadReactions(db *sql.DB, viewerID int64, messageIDs []int64) (map[int64][]models.ReactionSummary, error) {
+	out := make(map[int64][]models.ReactionSummary)
+	if len(messageIDs) == 0 {
+		return out, nil
+	}
+	args := []interface{}{viewerID}
+	for _, id := range messageIDs {
+		args = append(args, id)
+	}
+	rows, err := db.Query(`SELECT message_id, emoji, COUNT(*), MAX(user_id = ?) FROM message_reactions
+		WHERE message_id IN (`+placeholders(len(messageIDs))+`)
+		GROUP BY message_id, emoji ORDER BY message_id, MIN(created_at), emoji`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		var r models.ReactionSummary
+		if err := rows.Scan(&id, &r.Emoji, &r.Count, &r.Me); err != nil {
+			return nil, err
+		}
+		out[id] = append(out[id], r)
+	}
+	return out, rows.Err()
+}
+
+// placeholders returns "?, ?, ..." with n placeholders.
+func placeholders(n int) string {
+	if n <= 0 {
+		return ""
+	}
+	return strings.Repeat("?, ", n-1) + "?"
+}
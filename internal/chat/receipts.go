@@ -0,0 +1,158 @@
+package chat
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"convo/internal/ws"
+)
+
+// Receipt is one member's delivery state for a message.
+type Receipt struct {
+	UserID      int64      `json:"user_id"`
+	Name        string     `json:"name"`
+	Status      string     `json:"status"`
+	DeliveredAt *time.Time `json:"delivered_at,omitempty"`
+	ReadAt      *time.Time `json:"read_at,omitempty"`
+}
+
+// Ack records that userID has received (StatusDelivered) or read
+// (StatusRead) every message in roomID up to and including messageID. It
+// advances the member's cursors, moves the message_meta rows of the
+// messages in between forward, and tells the room and the senders of those
+// messages so they can show ticks. Acks never move cursors backwards.
+func Ack(db *sql.DB, roomID, userID, messageID int64, status string) error {
+	if !ValidStatus(status) {
+		return ErrInvalidStatus
+	}
+	if _, err := GetRole(db, roomID, userID); err != nil {
+		return err
+	}
+	var found int
+	err := db.QueryRow("SELECT 1 FROM messages WHERE id = ? AND room_id = ?", messageID, roomID).Scan(&found)
+	if err == sql.ErrNoRows {
+		return ErrMessageNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var lastRead, lastDelivered int64
+	if err := tx.QueryRow(`SELECT last_read_message_id, last_delivered_message_id FROM room_members
+		WHERE room_id = ? AND user_id = ? FOR UPDATE`, roomID, userID).Scan(&lastRead, &lastDelivered); err != nil {
+		return err
+	}
+	from := lastDelivered
+	if status == StatusRead {
+		from = lastRead
+	}
+	if messageID <= from {
+		return nil
+	}
+
+	if _, err := tx.Exec(`UPDATE room_members SET
+		last_delivered_message_id = GREATEST(last_delivered_message_id, ?),
+		last_read_message_id = IF(? = 'read', GREATEST(last_read_message_id, ?), last_read_message_id)
+		WHERE room_id = ? AND user_id = ?`, messageID, status, messageID, roomID, userID); err != nil {
+		return err
+	}
+	if status == StatusRead {
+		if err := recountMentions(tx, roomID, userID); err != nil {
+			return err
+		}
+		if err := startExpiry(tx, roomID, userID, from, messageID); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(`UPDATE message_meta mm JOIN messages m ON m.id = mm.message_id SET
+		mm.delivered_at = COALESCE(mm.delivered_at, NOW()),
+		mm.read_at = IF(? = 'read', COALESCE(mm.read_at, NOW()), mm.read_at),
+		mm.status = IF(FIELD(?, 'sent', 'delivered', 'read') > FIELD(mm.status, 'sent', 'delivered', 'read'), ?, mm.status)
+		WHERE m.room_id = ? AND m.id > ? AND m.id <= ? AND mm.user_id = ?`,
+		status, status, status, roomID, from, messageID, userID); err != nil {
+		return err
+	}
+
+	rows, err := tx.Query(`SELECT DISTINCT sender_id FROM messages
+		WHERE room_id = ? AND id > ? AND id <= ? AND sender_id <> ?`, roomID, from, messageID, userID)
+	if err != nil {
+		return err
+	}
+	var senders []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err == nil {
+			senders = append(senders, id)
+		}
+	}
+	rows.Close()
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	event := map[string]interface{}{
+		"type":       "message_" + status,
+		"room_id":    roomID,
+		"user_id":    userID,
+		"message_id": messageID,
+	}
+	Broadcast(roomID, event)
+	// senders who are not looking at the room still get their ticks
+	b, _ := json.Marshal(event)
+	for _, id := range senders {
+		ws.SendToUser(id, roomID, b)
+	}
+	return nil
+}
+
+// Receipts lists the delivery state of messageID for every other member of
+// roomID. Members without a message_meta row (e.g. who joined later) are
+// derived from their cursors.
+func Receipts(db *sql.DB, roomID, messageID int64) ([]Receipt, error) {
+	var senderID int64
+	err := db.QueryRow("SELECT sender_id FROM messages WHERE id = ? AND room_id = ?", messageID, roomID).Scan(&senderID)
+	if err == sql.ErrNoRows {
+		return nil, ErrMessageNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(`SELECT rm.user_id, u.name,
+		COALESCE(mm.status, IF(rm.last_read_message_id >= ?, 'read', IF(rm.last_delivered_message_id >= ?, 'delivered', 'sent'))),
+		mm.delivered_at, mm.read_at
+		FROM room_members rm
+		JOIN users u ON u.id = rm.user_id
+		LEFT JOIN message_meta mm ON mm.message_id = ? AND mm.user_id = rm.user_id
+		WHERE rm.room_id = ? AND rm.user_id <> ?
+		ORDER BY rm.user_id`, messageID, messageID, messageID, roomID, senderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	receipts := []Receipt{}
+	for rows.Next() {
+		var rc Receipt
+		var deliveredAt, readAt sql.NullTime
+		if err := rows.Scan(&rc.UserID, &rc.Name, &rc.Status, &deliveredAt, &readAt); err != nil {
+			return nil, err
+		}
+		if deliveredAt.Valid {
+			rc.DeliveredAt = &deliveredAt.Time
+		}
+		if readAt.Valid {
+			rc.ReadAt = &readAt.Time
+		}
+		receipts = append(receipts, rc)
+	}
+	return receipts, rows.Err()
+}
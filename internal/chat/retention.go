@@ -0,0 +1,217 @@
+package chat
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+)
+
+// Limits for room retention and message time-to-live, in seconds.
+const (
+	MinRetentionSeconds = 60 * 60
+	MaxRetentionSeconds = 10 * 365 * 24 * 60 * 60
+	MinTTLSeconds       = 5
+	MaxTTLSeconds       = 30 * 24 * 60 * 60
+)
+
+// reapBatch caps how many messages one reaper query collects.
+const reapBatch = 500
+
+// ErrInvalidTTL is returned for a ttl_seconds outside the allowed range.
+var ErrInvalidTTL = errors.New("ttl_seconds must be between 5 seconds and 30 days")
+
+// ValidTTL reports whether seconds is an allowed message TTL; 0 means none.
+func ValidTTL(seconds int) bool {
+	return seconds == 0 || (seconds >= MinTTLSeconds && seconds <= MaxTTLSeconds)
+}
+
+// ValidRetention reports whether seconds is an allowed room retention; 0
+// keeps messages forever.
+func ValidRetention(seconds int) bool {
+	return seconds == 0 || (seconds >= MinRetentionSeconds && seconds <= MaxRetentionSeconds)
+}
+
+// startExpiry starts the clock of disappearing messages in roomID with ids
+// in (fromID, toID] that readerID has now read. Only a reader other than
+// the sender starts it.
+func startExpiry(e execer, roomID, readerID, fromID, toID int64) error {
+	_, err := e.Exec(`UPDATE messages SET expires_at = NOW() + INTERVAL ttl_seconds SECOND
+		WHERE room_id = ? AND id > ? AND id <= ? AND ttl_seconds IS NOT NULL AND expires_at IS NULL AND sender_id <> ?`,
+		roomID, fromID, toID, readerID)
+	return err
+}
+
+// RunReaper purges expired messages every interval, forever: messages past
+// their room's retention and disappearing messages past expires_at. Purged
+// messages are deleted outright, together with their thread replies, and
+// the room is sent a "message_expired" event listing them. It also forgets
+// client_msg_ids older than DedupeWindow and deletes unsent uploads and
+// abandoned resumable uploads.
+func RunReaper(db *sql.DB, interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		for {
+			n, err := reap(db)
+			if err != nil {
+				log.Printf("reaper: %v", err)
+				break
+			}
+			if n < reapBatch {
+				break
+			}
+		}
+		if err := pruneClientMsgIDs(db); err != nil {
+			log.Printf("reaper: client msg ids: %v", err)
+		}
+		if err := pruneUploads(db); err != nil {
+			log.Printf("reaper: uploads: %v", err)
+		}
+		if err := pruneAttachments(db); err != nil {
+			log.Printf("reaper: attachments: %v", err)
+		}
+		<-t.C
+	}
+}
+
+// reap purges one batch of expired messages and returns how many it found.
+func reap(db *sql.DB) (int, error) {
+	byRoom := make(map[int64][]int64)
+	found := 0
+	for _, q := range []string{
+		`SELECT m.id, m.room_id FROM messages m JOIN rooms r ON r.id = m.room_id
+			WHERE r.retention_seconds > 0 AND m.sent_at < NOW() - INTERVAL r.retention_seconds SECOND LIMIT ?`,
+		`SELECT id, room_id FROM messages WHERE expires_at <= NOW() LIMIT ?`,
+	} {
+		n, err := collectIDs(db, byRoom, q, reapBatch)
+		if err != nil {
+			return 0, err
+		}
+		if n > found {
+			found = n
+		}
+	}
+	if len(byRoom) == 0 {
+		return 0, nil
+	}
+	for roomID, ids := range byRoom {
+		if err := purgeMessages(db, roomID, ids); err != nil {
+			return 0, err
+		}
+	}
+	return found, nil
+}
+
+// collectIDs adds the (id, room_id) rows of query to byRoom, without
+// duplicates, and returns how many rows it read.
+func collectIDs(db *sql.DB, byRoom map[int64][]int64, query string, args ...interface{}) (int, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	n := 0
+	for rows.Next() {
+		var id, roomID int64
+		if err := rows.Scan(&id, &roomID); err != nil {
+			return n, err
+		}
+		n++
+		dup := false
+		for _, have := range byRoom[roomID] {
+			if have == id {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			byRoom[roomID] = append(byRoom[roomID], id)
+		}
+	}
+	return n, rows.Err()
+}
+
+// purgeMessages deletes ids from roomID along with their thread replies,
+// fixes up what referred to them and tells the room.
+func purgeMessages(db *sql.DB, roomID int64, ids []int64) error {
+	// replies go with their root; list them so clients hear about them
+	args := make([]interface{}, 0, len(ids)+1)
+	args = append(args, roomID)
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	byRoom := map[int64][]int64{roomID: ids}
+	if _, err := collectIDs(db, byRoom, `SELECT id, room_id FROM messages WHERE room_id = ? AND thread_id IN (`+placeholders(len(ids))+`)`, args...); err != nil {
+		return err
+	}
+	ids = byRoom[roomID]
+	args = args[:1]
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	in := placeholders(len(ids))
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// thread roots that survive need their reply counts fixed afterwards
+	rows, err := tx.Query(`SELECT DISTINCT thread_id FROM messages WHERE room_id = ? AND id IN (`+in+`) AND thread_id IS NOT NULL`, args...)
+	if err != nil {
+		return err
+	}
+	var roots []interface{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err == nil {
+			roots = append(roots, id)
+		}
+	}
+	rows.Close()
+
+	// quotes of purged messages must not keep their text; this has to run
+	// before the delete nulls reply_to_id
+	if _, err := tx.Exec(`UPDATE messages SET reply_snippet = NULL WHERE reply_to_id IN (`+in+`)`, args[1:]...); err != nil {
+		return err
+	}
+	blobs, err := deleteAttachments(tx, ids)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM messages WHERE room_id = ? AND id IN (`+in+`)`, args...); err != nil {
+		return err
+	}
+	if len(roots) > 0 {
+		if _, err := tx.Exec(`UPDATE messages r
+			LEFT JOIN (SELECT thread_id, COUNT(*) AS n, MAX(sent_at) AS last FROM messages
+				WHERE thread_id IN (`+placeholders(len(roots))+`) GROUP BY thread_id) x ON x.thread_id = r.id
+			SET r.reply_count = COALESCE(x.n, 0), r.last_reply_at = x.last
+			WHERE r.id IN (`+placeholders(len(roots))+`)`, append(roots, roots...)...); err != nil {
+			return err
+		}
+	}
+	if err := recountMentions(tx, roomID, 0); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	removeBlobs(blobs)
+
+	Broadcast(roomID, map[string]interface{}{
+		"type":        "message_expired",
+		"room_id":     roomID,
+		"message_ids": ids,
+	})
+	for _, id := range ids {
+		unindexMessage(id)
+	}
+	return nil
+}
@@ -0,0 +1,304 @@
+package chat
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"convo/internal/models"
+	"convo/internal/ws"
+)
+
+// Scheduled message states, stored in scheduled_messages.status.
+const (
+	ScheduledPending   = "pending"
+	ScheduledSent      = "sent"
+	ScheduledCancelled = "cancelled"
+	ScheduledFailed    = "failed"
+)
+
+// MaxScheduleAhead is how far in the future a message may be scheduled.
+const MaxScheduleAhead = 365 * 24 * time.Hour
+
+var (
+	// ErrInvalidSendTime is returned for a send time that is not in the
+	// future or too far ahead.
+	ErrInvalidSendTime = errors.New("send_at must be in the future and within a year")
+	// ErrScheduledNotFound is returned for an unknown scheduled message or
+	// one belonging to somebody else.
+	ErrScheduledNotFound = errors.New("scheduled message not found")
+	// ErrNotPending is returned when changing a scheduled message that was
+	// already sent, cancelled or failed.
+	ErrNotPending = errors.New("scheduled message is no longer pending")
+)
+
+const scheduledColumns = `id, room_id, sender_id, content, format, thread_id, reply_to_id, send_at, status, message_id, error, created_at`
+
+func scanScheduled(row interface{ Scan(...interface{}) error }) (*models.ScheduledMessage, error) {
+	var s models.ScheduledMessage
+	var threadID, replyTo, messageID sql.NullInt64
+	var errText sql.NullString
+	if err := row.Scan(&s.ID, &s.RoomID, &s.SenderID, &s.Content, &s.Format, &threadID, &replyTo, &s.SendAt, &s.Status, &messageID, &errText, &s.CreatedAt); err != nil {
+		return nil, err
+	}
+	s.ThreadID = nullID(threadID)
+	s.ReplyTo = nullID(replyTo)
+	s.MessageID = nullID(messageID)
+	s.Error = errText.String
+	return &s, nil
+}
+
+func validSendAt(t time.Time) bool {
+	now := time.Now()
+	return t.After(now) && t.Before(now.Add(MaxScheduleAhead))
+}
+
+// ScheduleMessage stores req to be posted at sendAt. The sender must be a
+// member now; the usual send checks run again when it is due.
+func ScheduleMessage(db *sql.DB, req SendRequest, sendAt time.Time) (*models.ScheduledMessage, error) {
+	if req.Content == "" {
+		return nil, ErrEmptyContent
+	}
+	format, err := normalizeFormat(req.Format)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkLength(format, req.Content); err != nil {
+		return nil, err
+	}
+	if !validSendAt(sendAt) {
+		return nil, ErrInvalidSendTime
+	}
+	if _, err := GetRole(db, req.RoomID, req.SenderID); err != nil {
+		return nil, err
+	}
+	var threadID, replyTo sql.NullInt64
+	if req.ThreadID != 0 {
+		threadID = sql.NullInt64{Int64: req.ThreadID, Valid: true}
+	}
+	if req.ReplyToID != 0 {
+		replyTo = sql.NullInt64{Int64: req.ReplyToID, Valid: true}
+	}
+	res, err := db.Exec(`INSERT INTO scheduled_messages (room_id, sender_id, content, format, thread_id, reply_to_id, send_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`, req.RoomID, req.SenderID, req.Content, format, threadID, replyTo, sendAt.UTC())
+	if err != nil {
+		return nil, err
+	}
+	id, _ := res.LastInsertId()
+	return GetScheduled(db, req.RoomID, id, req.SenderID)
+}
+
+// GetScheduled loads scheduled message id of userID in roomID.
+func GetScheduled(db *sql.DB, roomID, id, userID int64) (*models.ScheduledMessage, error) {
+	s, err := scanScheduled(db.QueryRow(`SELECT `+scheduledColumns+` FROM scheduled_messages
+		WHERE id = ? AND room_id = ? AND sender_id = ?`, id, roomID, userID))
+	if err == sql.ErrNoRows {
+		return nil, ErrScheduledNotFound
+	}
+	return s, err
+}
+
+// ListScheduled returns the pending scheduled messages of userID in roomID,
+// soonest first.
+func ListScheduled(db *sql.DB, roomID, userID int64) ([]models.ScheduledMessage, error) {
+	rows, err := db.Query(`SELECT `+scheduledColumns+` FROM scheduled_messages
+		WHERE room_id = ? AND sender_id = ? AND status = 'pending' ORDER BY send_at, id`, roomID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []models.ScheduledMessage{}
+	for rows.Next() {
+		s, err := scanScheduled(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *s)
+	}
+	return list, rows.Err()
+}
+
+// UpdateScheduled changes the content and/or send time of a pending
+// scheduled message; nil leaves a field as it is.
+func UpdateScheduled(db *sql.DB, roomID, id, userID int64, content *string, sendAt *time.Time) (*models.ScheduledMessage, error) {
+	if content != nil && *content == "" {
+		return nil, ErrEmptyContent
+	}
+	if sendAt != nil && !validSendAt(*sendAt) {
+		return nil, ErrInvalidSendTime
+	}
+	s, err := GetScheduled(db, roomID, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if s.Status != ScheduledPending {
+		return nil, ErrNotPending
+	}
+	if content != nil {
+		if err := checkLength(s.Format, *content); err != nil {
+			return nil, err
+		}
+		s.Content = *content
+	}
+	if sendAt != nil {
+		s.SendAt = sendAt.UTC()
+	}
+	// the status check guards against the dispatcher having taken it
+	res, err := db.Exec(`UPDATE scheduled_messages SET content = ?, send_at = ?
+		WHERE id = ? AND status = 'pending'`, s.Content, s.SendAt, id)
+	if err != nil {
+		return nil, err
+	}
+	s, err = GetScheduled(db, roomID, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	// no rows changed means either the values were the same or the
+	// dispatcher took it after we read it; the re-read tells them apart
+	if n, _ := res.RowsAffected(); n == 0 && s.Status != ScheduledPending {
+		return nil, ErrNotPending
+	}
+	return s, nil
+}
+
+// CancelScheduled cancels a pending scheduled message.
+func CancelScheduled(db *sql.DB, roomID, id, userID int64) error {
+	s, err := GetScheduled(db, roomID, id, userID)
+	if err != nil {
+		return err
+	}
+	if s.Status != ScheduledPending {
+		return ErrNotPending
+	}
+	res, err := db.Exec("UPDATE scheduled_messages SET status = 'cancelled' WHERE id = ? AND status = 'pending'", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotPending
+	}
+	return nil
+}
+
+// dispatchBatch caps how many due messages one dispatcher pass sends.
+const dispatchBatch = 100
+
+// RunScheduler sends due scheduled messages every interval, forever. Due
+// rows are claimed with FOR UPDATE SKIP LOCKED and sent in the same
+// transaction that marks them sent, so several instances can run it at
+// once without sending anything twice, and messages that fell due while
+// no instance was running go out on the next pass.
+func RunScheduler(db *sql.DB, interval time.Duration) {
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		for i := 0; i < dispatchBatch; i++ {
+			more, err := dispatchOne(db)
+			if err != nil {
+				log.Printf("scheduled messages: %v", err)
+				break
+			}
+			if !more {
+				break
+			}
+		}
+		<-t.C
+	}
+}
+
+// dispatchOne sends the oldest due scheduled message not claimed by
+// another instance. It reports whether there was one.
+func dispatchOne(db *sql.DB) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	s, err := scanScheduled(tx.QueryRow(`SELECT ` + scheduledColumns + ` FROM scheduled_messages
+		WHERE status = 'pending' AND send_at <= NOW()
+		ORDER BY send_at, id LIMIT 1 FOR UPDATE SKIP LOCKED`))
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	m, mentioned, sendErr := sendScheduled(tx, s)
+	var wait *WaitError
+	switch {
+	case sendErr == nil, sendErr == ErrNotMember, sendErr == ErrMessageNotFound, sendErr == ErrMessageDeleted:
+	case errors.As(sendErr, &wait):
+	default:
+		// a DB problem; leave it pending for the next pass
+		return false, sendErr
+	}
+	switch {
+	case wait != nil:
+		// timed out: try again once the timeout is over
+		_, err = tx.Exec("UPDATE scheduled_messages SET send_at = ? WHERE id = ?", wait.Until.UTC(), s.ID)
+	case sendErr != nil:
+		_, err = tx.Exec("UPDATE scheduled_messages SET status = 'failed', error = ? WHERE id = ?", sendErr.Error(), s.ID)
+	default:
+		_, err = tx.Exec("UPDATE scheduled_messages SET status = 'sent', message_id = ? WHERE id = ?", m.ID, s.ID)
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+
+	event := map[string]interface{}{"type": "scheduled_message_sent", "room_id": s.RoomID, "scheduled_id": s.ID}
+	switch {
+	case wait != nil:
+		return true, nil
+	case sendErr != nil:
+		log.Printf("scheduled message %d failed: %v", s.ID, sendErr)
+		event["type"] = "scheduled_message_failed"
+		event["error"] = sendErr.Error()
+	default:
+		event["message_id"] = m.ID
+		afterSend(db, m, mentioned)
+	}
+	b, _ := json.Marshal(event)
+	ws.SendToUser(s.SenderID, 0, b)
+	return true, nil
+}
+
+// sendScheduled stores s as a message in tx. The sender must still be a
+// member and not timed out; slow mode and the rate limit do not apply to a
+// message composed earlier.
+func sendScheduled(tx *sql.Tx, s *models.ScheduledMessage) (*models.Message, map[int64]string, error) {
+	var role string
+	err := tx.QueryRow("SELECT role FROM room_members WHERE room_id = ? AND user_id = ?", s.RoomID, s.SenderID).Scan(&role)
+	if err == sql.ErrNoRows {
+		return nil, nil, ErrNotMember
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	var until time.Time
+	err = tx.QueryRow("SELECT expires_at FROM room_timeouts WHERE room_id = ? AND user_id = ? AND expires_at > NOW()", s.RoomID, s.SenderID).Scan(&until)
+	if err == nil {
+		return nil, nil, &WaitError{Reason: "sender is timed out", Until: until}
+	}
+	if err != sql.ErrNoRows {
+		return nil, nil, err
+	}
+
+	req := SendRequest{RoomID: s.RoomID, SenderID: s.SenderID, Content: s.Content, Format: s.Format}
+	if s.ThreadID != nil {
+		req.ThreadID = *s.ThreadID
+	}
+	if s.ReplyTo != nil {
+		req.ReplyToID = *s.ReplyTo
+	}
+	return storeMessage(tx, req)
+}
@@ -0,0 +1,92 @@
+package chat
+
+import (
+	"database/sql"
+	"log"
+
+	"convo/internal/models"
+	"convo/internal/search"
+)
+
+// SearchIndex receives every stored, edited and deleted message and answers
+// Search. It is set at startup; nil disables indexing and search.
+var SearchIndex search.Index
+
+// indexMessage adds or updates m in SearchIndex. Indexing failures are
+// logged rather than failing the send.
+func indexMessage(m *models.Message) {
+	if SearchIndex == nil || m.Kind == KindSystem {
+		return
+	}
+	doc := search.Document{MessageID: m.ID, RoomID: m.RoomID, SenderID: m.SenderID, Content: m.Content, SentAt: m.SentAt,
+		HasAttachment: len(m.Attachments) > 0}
+	if err := SearchIndex.Index(doc); err != nil {
+		log.Printf("index message %d: %v", m.ID, err)
+	}
+}
+
+// unindexMessage drops messageID from SearchIndex.
+func unindexMessage(messageID int64) {
+	if SearchIndex == nil {
+		return
+	}
+	if err := SearchIndex.Remove(messageID); err != nil {
+		log.Printf("unindex message %d: %v", messageID, err)
+	}
+}
+
+// Search runs q over the rooms viewerID belongs to, or only over roomID if
+// it is not 0. Messages the viewer deleted for themselves are left out.
+func Search(db *sql.DB, viewerID, roomID int64, q search.Query) ([]search.Hit, error) {
+	if SearchIndex == nil {
+		return []search.Hit{}, nil
+	}
+	if roomID != 0 {
+		if _, err := GetRole(db, roomID, viewerID); err != nil {
+			return nil, err
+		}
+		q.RoomIDs = []int64{roomID}
+	} else {
+		rows, err := db.Query("SELECT room_id FROM room_members WHERE user_id = ?", viewerID)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			q.RoomIDs = append(q.RoomIDs, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	q.HiddenBy = viewerID
+	if _, ok := SearchIndex.(*search.MySQLIndex); ok {
+		return SearchIndex.Search(q)
+	}
+
+	// other indexes cannot join message_hidden, so hand them the ids
+	rows, err := db.Query("SELECT message_id FROM message_hidden WHERE user_id = ?", viewerID)
+	if err != nil {
+		return nil, err
+	}
+	q.Exclude = make(map[int64]bool)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		q.Exclude[id] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return SearchIndex.Search(q)
+}
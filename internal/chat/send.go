@@ -0,0 +1,289 @@
+package chat
+
+import (
+	"database/sql"
+	"log"
+
+	"convo/internal/models"
+)
+
+// SendRequest is a message a member wants to post to a room.
+type SendRequest struct {
+	RoomID   int64
+	SenderID int64
+	Content  string
+	// Format is FormatPlain or FormatMarkdown; empty means plain.
+	Format string
+	// ThreadID makes the message a reply in the thread of that message.
+	// Replying to a reply joins the reply's thread.
+	ThreadID int64
+	// ReplyToID quotes that message of the same room inline.
+	ReplyToID int64
+	// Forward is set when the message is a forward of another message;
+	// see ForwardMessage.
+	Forward *models.ForwardRef
+	// TTLSeconds makes the message disappear that long after another
+	// member reads it; 0 uses the room's disappearing_seconds.
+	TTLSeconds int
+	// ClientMsgID is an id the client generated for this send. Sending
+	// again with the same id within DedupeWindow returns the original
+	// message instead of posting a duplicate.
+	ClientMsgID string
+	// AttachmentIDs are uploads (see UploadAttachment) to send with the
+	// message. A message with attachments may have no text.
+	AttachmentIDs []int64
+
+	kind string // KindText unless set by CreatePoll
+}
+
+// SendMessage posts req on behalf of req.SenderID. It checks membership,
+// moderation and rate limits (see CheckSend), stores the message along with
+// a message_meta row per current member, broadcasts it to the room hub and
+// fans out notifications. The sender's draft in the room is cleared. Both
+// the HTTP and websocket send paths use it.
+func SendMessage(db *sql.DB, req SendRequest) (*models.Message, error) {
+	if req.Content == "" && len(req.AttachmentIDs) == 0 {
+		return nil, ErrEmptyContent
+	}
+	format, err := normalizeFormat(req.Format)
+	if err != nil {
+		return nil, err
+	}
+	req.Format = format
+	if err := checkLength(format, req.Content); err != nil {
+		return nil, err
+	}
+	if !ValidTTL(req.TTLSeconds) {
+		return nil, ErrInvalidTTL
+	}
+	if !validClientMsgID(req.ClientMsgID) {
+		return nil, ErrInvalidClientMsgID
+	}
+	role, err := checkMember(db, req.RoomID, req.SenderID)
+	if err != nil {
+		return nil, err
+	}
+	// a retry is answered once the sender is known to still be allowed in
+	// the room, but before slow mode and the rate limit, which it must not
+	// use up
+	if req.ClientMsgID != "" {
+		if m, err := sentMessage(db, req.RoomID, req.SenderID, req.ClientMsgID); m != nil || err != nil {
+			return m, err
+		}
+	}
+	if err := claimSend(db, req.RoomID, req.SenderID, role); err != nil {
+		return nil, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	m, mentioned, err := storeMessage(tx, req)
+	if err == errDuplicateSend {
+		// a concurrent retry won the race
+		tx.Rollback()
+		return sentMessage(db, req.RoomID, req.SenderID, req.ClientMsgID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	afterSend(db, m, mentioned)
+	// a forward was not typed in the target room, so keep its draft
+	if req.Forward == nil {
+		draftSent(db, m)
+	}
+	return m, nil
+}
+
+// storeMessage inserts req in tx with its message_meta rows, thread
+// bookkeeping and mentions, without any of SendMessage's checks. Once tx
+// commits the caller passes the result to afterSend.
+func storeMessage(tx *sql.Tx, req SendRequest) (*models.Message, map[int64]string, error) {
+	m := &models.Message{RoomID: req.RoomID, SenderID: req.SenderID, Content: req.Content, Format: FormatPlain, Kind: KindText}
+	if req.kind != "" {
+		m.Kind = req.kind
+	}
+	if req.Format != "" {
+		m.Format = req.Format
+	}
+	rich, err := renderRich(tx, req.RoomID, m.Format, m.Content)
+	if err != nil {
+		return nil, nil, err
+	}
+	m.Rich = rich
+	var threadID sql.NullInt64
+	if req.ThreadID != 0 {
+		root, err := threadRoot(tx, req.RoomID, req.ThreadID)
+		if err != nil {
+			return nil, nil, err
+		}
+		threadID = sql.NullInt64{Int64: root, Valid: true}
+		m.ThreadID = &root
+	}
+
+	var replyTo, replySender sql.NullInt64
+	var replySnippet sql.NullString
+	if req.ReplyToID != 0 {
+		ref, err := quote(tx, req.RoomID, req.ReplyToID)
+		if err != nil {
+			return nil, nil, err
+		}
+		m.ReplyTo = ref
+		replyTo = sql.NullInt64{Int64: req.ReplyToID, Valid: true}
+		replySender = sql.NullInt64{Int64: *ref.SenderID, Valid: true}
+		replySnippet = sql.NullString{String: ref.Snippet, Valid: true}
+	}
+	var fwdMessage, fwdUser sql.NullInt64
+	if req.Forward != nil {
+		m.ForwardedFrom = req.Forward
+		fwdMessage = nullInt(req.Forward.MessageID)
+		fwdUser = nullInt(req.Forward.UserID)
+	}
+
+	ttl := req.TTLSeconds
+	if ttl == 0 {
+		if err := tx.QueryRow("SELECT disappearing_seconds FROM rooms WHERE id = ?", req.RoomID).Scan(&ttl); err != nil {
+			return nil, nil, err
+		}
+	}
+	var ttlSeconds sql.NullInt64
+	if ttl > 0 {
+		ttlSeconds = sql.NullInt64{Int64: int64(ttl), Valid: true}
+		m.TTLSeconds = &ttl
+	}
+
+	result, err := tx.Exec(`INSERT INTO messages (room_id, sender_id, content, format, rich, kind, thread_id,
+		reply_to_id, reply_sender_id, reply_snippet, forwarded_from_message_id, forwarded_from_user_id, ttl_seconds)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		m.RoomID, m.SenderID, m.Content, m.Format, nullJSON(rich), m.Kind, threadID, replyTo, replySender, replySnippet, fwdMessage, fwdUser, ttlSeconds)
+	if err != nil {
+		return nil, nil, err
+	}
+	m.ID, _ = result.LastInsertId()
+	// sent_at comes from the DB clock so it compares cleanly with NOW()
+	if err := tx.QueryRow("SELECT sent_at FROM messages WHERE id = ?", m.ID).Scan(&m.SentAt); err != nil {
+		return nil, nil, err
+	}
+
+	if err := insertMeta(tx, m, req.Forward != nil); err != nil {
+		return nil, nil, err
+	}
+	if m.ThreadID != nil {
+		if err := addThreadReply(tx, m); err != nil {
+			return nil, nil, err
+		}
+	}
+	if len(req.AttachmentIDs) > 0 {
+		if err := linkAttachments(tx, m, req.AttachmentIDs); err != nil {
+			return nil, nil, err
+		}
+	}
+	if req.ClientMsgID != "" {
+		if err := claimClientMsgID(tx, m, req.ClientMsgID); err != nil {
+			return nil, nil, err
+		}
+		m.ClientMsgID = req.ClientMsgID
+	}
+	mentioned, err := resolveMentions(tx, m.RoomID, m.SenderID, m.Content)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := storeMentions(tx, m.RoomID, m.ID, m.ThreadID, mentioned); err != nil {
+		return nil, nil, err
+	}
+	return m, mentioned, nil
+}
+
+// insertMeta adds one message_meta row per current member of m's room; the
+// sender has read their own message.
+func insertMeta(tx *sql.Tx, m *models.Message, forwarded bool) error {
+	_, err := tx.Exec(`INSERT INTO message_meta (message_id, user_id, status, read_at, delivered_at, forwarded)
+		SELECT ?, rm.user_id, IF(rm.user_id = ?, 'read', 'sent'), IF(rm.user_id = ?, NOW(), NULL), IF(rm.user_id = ?, NOW(), NULL), ?
+		FROM room_members rm WHERE rm.room_id = ?
+		ON DUPLICATE KEY UPDATE message_id = message_id`, m.ID, m.SenderID, m.SenderID, m.SenderID, forwarded, m.RoomID)
+	return err
+}
+
+// afterSend runs the side effects of a stored message: the sender's read
+// cursor, the room broadcast and notifications. mentioned maps the members
+// the message mentions to how they were mentioned.
+func afterSend(db *sql.DB, m *models.Message, mentioned map[int64]string) {
+	// the sender has obviously seen everything up to their own message
+	if err := MarkRead(db, m.RoomID, m.SenderID, m.ID); err != nil {
+		log.Printf("mark read room %d user %d: %v", m.RoomID, m.SenderID, err)
+	}
+	event := map[string]interface{}{
+		"type":      "message",
+		"room_id":   m.RoomID,
+		"id":        m.ID,
+		"sender_id": m.SenderID,
+		"content":   m.Content,
+		"format":    m.Format,
+		"kind":      m.Kind,
+		"sent_at":   m.SentAt,
+	}
+	if m.Rich != nil {
+		event["rich"] = m.Rich
+	}
+	if m.ThreadID != nil {
+		event["thread_id"] = *m.ThreadID
+	}
+	if m.ReplyTo != nil {
+		event["reply_to"] = m.ReplyTo
+	}
+	if m.ForwardedFrom != nil {
+		event["forwarded_from"] = m.ForwardedFrom
+	}
+	if m.TTLSeconds != nil {
+		event["ttl_seconds"] = *m.TTLSeconds
+	}
+	if m.Poll != nil {
+		event["poll"] = m.Poll
+	}
+	if len(m.Attachments) > 0 {
+		event["attachments"] = m.Attachments
+	}
+	if m.ClientMsgID != "" {
+		event["client_msg_id"] = m.ClientMsgID
+	}
+	if len(mentioned) > 0 {
+		ids := make([]int64, 0, len(mentioned))
+		for id := range mentioned {
+			ids = append(ids, id)
+		}
+		event["mentions"] = ids
+	}
+	Broadcast(m.RoomID, event)
+	indexMessage(m)
+	go unfurlMessage(db, m, false)
+
+	// system messages are only history, nobody needs a ping for them
+	if m.Kind == KindSystem {
+		return
+	}
+	ids := make(map[int64]bool, len(mentioned))
+	for id := range mentioned {
+		ids[id] = true
+	}
+	n := Notification{RoomID: m.RoomID, MessageID: m.ID, SenderID: m.SenderID, Content: m.Content, Mentioned: ids}
+	// a thread reply pings the members it mentions like any message, but
+	// otherwise only concerns the thread's followers
+	if m.ThreadID != nil {
+		n.MentionsOnly = true
+		go func() {
+			if len(ids) > 0 {
+				Notify(db, n)
+			}
+			NotifyThread(db, *m.ThreadID, m, ids)
+		}()
+		return
+	}
+	go Notify(db, n)
+}
@@ -0,0 +1,72 @@
+package chat
+
+import (
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// slowModeRoom answers as a room whose slow mode window is still open for
+// the sender, who already sent "first" with client_msg_id c1 as message 42.
+func slowModeRoom(query string, args []driver.Value) (fakeResult, error) {
+	switch {
+	case strings.Contains(query, "SELECT role FROM room_members"):
+		return fakeResult{rows: [][]driver.Value{{RoleMember}}}, nil
+	case strings.Contains(query, "FROM message_client_ids"):
+		if args[2] == "c1" {
+			return fakeResult{rows: [][]driver.Value{{int64(42)}}}, nil
+		}
+	case strings.Contains(query, "FROM messages m WHERE m.id = ?"):
+		return fakeResult{rows: [][]driver.Value{messageRow(42, 1, 7, "first", nil)}}, nil
+	case strings.Contains(query, "TIMESTAMPDIFF"):
+		return fakeResult{rows: [][]driver.Value{{int64(30)}}}, nil
+	}
+	return fakeResult{}, nil
+}
+
+func TestSendMessageRetryInSlowMode(t *testing.T) {
+	limiter := SendLimiter
+	SendLimiter = NewRateLimiter(1, time.Hour)
+	t.Cleanup(func() { SendLimiter = limiter })
+	db, fake := openFake(t, slowModeRoom)
+
+	for range 3 {
+		m, err := SendMessage(db, SendRequest{RoomID: 1, SenderID: 7, Content: "first", ClientMsgID: "c1"})
+		if err != nil {
+			t.Fatalf("retry: %v", err)
+		}
+		if m.ID != 42 || m.Content != "first" || m.ClientMsgID != "c1" {
+			t.Fatalf("retry got %+v, want message 42", m)
+		}
+	}
+	if fake.ran("UPDATE room_members") {
+		t.Error("a retry claimed the slow mode slot")
+	}
+	if ok, _ := SendLimiter.Allow(7); !ok {
+		t.Error("a retry used up the rate limit")
+	}
+
+	// a new message is still held back by slow mode
+	_, err := SendMessage(db, SendRequest{RoomID: 1, SenderID: 7, Content: "second", ClientMsgID: "c2"})
+	var wait *WaitError
+	if !errors.As(err, &wait) {
+		t.Fatalf("new message: err = %v, want *WaitError", err)
+	}
+}
+
+func TestSendMessageRetryNotMember(t *testing.T) {
+	db, fake := openFake(t, func(query string, args []driver.Value) (fakeResult, error) {
+		if strings.Contains(query, "SELECT role FROM room_members") {
+			return fakeResult{}, nil
+		}
+		return slowModeRoom(query, args)
+	})
+	if _, err := SendMessage(db, SendRequest{RoomID: 1, SenderID: 7, Content: "first", ClientMsgID: "c1"}); err != ErrNotMember {
+		t.Fatalf("err = %v, want ErrNotMember", err)
+	}
+	if fake.ran("message_client_ids") {
+		t.Error("a former member's retry was looked up")
+	}
+}
@@ -0,0 +1,99 @@
+package chat
+
+import (
+	"database/sql"
+	"errors"
+
+	"convo/internal/models"
+)
+
+// Delivery statuses stored in message_meta.status, in the only order a
+// message may move through them.
+const (
+	StatusSent      = "sent"
+	StatusDelivered = "delivered"
+	StatusRead      = "read"
+)
+
+// ErrInvalidStatus is returned for an unknown delivery status.
+var ErrInvalidStatus = errors.New("status must be delivered or read")
+
+// ValidStatus reports whether status is a status clients may report.
+func ValidStatus(status string) bool {
+	return status == StatusDelivered || status == StatusRead
+}
+
+// execer is satisfied by *sql.DB and *sql.Tx.
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// statusUpsert moves a single message_meta row forward to a new status and
+// never backwards; read implies delivered. Rows missing for messages that
+// predate message_meta are created on the fly. The timestamp columns are
+// assigned before status because MySQL evaluates assignments in order.
+const statusUpsert = `INSERT INTO message_meta (message_id, user_id, status, delivered_at, read_at)
+	VALUES (?, ?, ?, NOW(), IF(? = 'read', NOW(), NULL))
+	ON DUPLICATE KEY UPDATE
+		delivered_at = COALESCE(delivered_at, VALUES(delivered_at)),
+		read_at = COALESCE(read_at, VALUES(read_at)),
+		status = IF(FIELD(VALUES(status), 'sent', 'delivered', 'read') > FIELD(status, 'sent', 'delivered', 'read'), VALUES(status), status)`
+
+// SetStatus advances userID's delivery status for messageID.
+func SetStatus(e execer, messageID, userID int64, status string) error {
+	if !ValidStatus(status) {
+		return ErrInvalidStatus
+	}
+	if _, err := e.Exec(statusUpsert, messageID, userID, status, status); err != nil {
+		return err
+	}
+	if status != StatusRead {
+		return nil
+	}
+	// reading a disappearing message starts its clock
+	_, err := e.Exec(`UPDATE messages SET expires_at = NOW() + INTERVAL ttl_seconds SECOND
+		WHERE id = ? AND ttl_seconds IS NOT NULL AND expires_at IS NULL AND sender_id <> ?`, messageID, userID)
+	return err
+}
+
+// SetStarred stars or unstars messageID for userID.
+func SetStarred(e execer, messageID, userID int64, starred bool) error {
+	_, err := e.Exec(`INSERT INTO message_meta (message_id, user_id, starred) VALUES (?, ?, ?)
+		ON DUPLICATE KEY UPDATE starred = VALUES(starred)`, messageID, userID, starred)
+	return err
+}
+
+// GetMeta returns userID's state for messageID in roomID. Messages without
+// a row yet report the default "sent" state.
+func GetMeta(db *sql.DB, roomID, messageID, userID int64) (*models.MessageMeta, error) {
+	var found int
+	err := db.QueryRow("SELECT 1 FROM messages WHERE id = ? AND room_id = ?", messageID, roomID).Scan(&found)
+	if err == sql.ErrNoRows {
+		return nil, ErrMessageNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	m := &models.MessageMeta{MessageID: messageID, UserID: userID, Status: StatusSent}
+	var reactions, extra []byte
+	var deliveredAt, readAt sql.NullTime
+	err = db.QueryRow(`SELECT status, forwarded, starred, reactions, extra, delivered_at, read_at
+		FROM message_meta WHERE message_id = ? AND user_id = ?`, messageID, userID).
+		Scan(&m.Status, &m.Forwarded, &m.Starred, &reactions, &extra, &deliveredAt, &readAt)
+	if err == sql.ErrNoRows {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	m.Reactions = reactions
+	m.Extra = extra
+	if deliveredAt.Valid {
+		m.DeliveredAt = &deliveredAt.Time
+	}
+	if readAt.Valid {
+		m.ReadAt = &readAt.Time
+	}
+	return m, nil
+}
@@ -0,0 +1,121 @@
+package chat
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"time"
+
+	"convo/internal/models"
+	"convo/internal/ws"
+)
+
+// threadRoot resolves the root of the thread a reply to messageID belongs
+// in: messageID itself, or its own root when it is already a reply.
+func threadRoot(tx *sql.Tx, roomID, messageID int64) (int64, error) {
+	var threadID sql.NullInt64
+	var deletedAt sql.NullTime
+	err := tx.QueryRow("SELECT thread_id, deleted_at FROM messages WHERE id = ? AND room_id = ?", messageID, roomID).
+		Scan(&threadID, &deletedAt)
+	if err == sql.ErrNoRows {
+		return 0, ErrMessageNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	if threadID.Valid {
+		return threadID.Int64, nil
+	}
+	if deletedAt.Valid {
+		return 0, ErrMessageDeleted
+	}
+	return messageID, nil
+}
+
+// addThreadReply bumps the root's reply counters for the freshly inserted
+// reply m and makes the replier and the root's author follow the thread.
+func addThreadReply(tx *sql.Tx, m *models.Message) error {
+	root := *m.ThreadID
+	if _, err := tx.Exec("UPDATE messages SET reply_count = reply_count + 1, last_reply_at = ? WHERE id = ?", m.SentAt, root); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`INSERT IGNORE INTO thread_followers (message_id, user_id)
+		SELECT ?, ? UNION SELECT id, sender_id FROM messages WHERE id = ?`, root, m.SenderID, root)
+	return err
+}
+
+// Follow makes userID follow (or unfollow) the thread rooted at messageID.
+func Follow(db *sql.DB, roomID, messageID, userID int64, follow bool) error {
+	if _, err := GetRole(db, roomID, userID); err != nil {
+		return err
+	}
+	var threadID sql.NullInt64
+	err := db.QueryRow("SELECT thread_id FROM messages WHERE id = ? AND room_id = ?", messageID, roomID).Scan(&threadID)
+	if err == sql.ErrNoRows || (err == nil && threadID.Valid) {
+		// only thread roots can be followed
+		return ErrMessageNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if follow {
+		_, err = db.Exec("INSERT IGNORE INTO thread_followers (message_id, user_id) VALUES (?, ?)", messageID, userID)
+	} else {
+		_, err = db.Exec("DELETE FROM thread_followers WHERE message_id = ? AND user_id = ?", messageID, userID)
+	}
+	return err
+}
+
+// IsFollowing reports whether userID follows the thread rooted at messageID.
+func IsFollowing(db *sql.DB, messageID, userID int64) (bool, error) {
+	var found int
+	err := db.QueryRow("SELECT 1 FROM thread_followers WHERE message_id = ? AND user_id = ?", messageID, userID).Scan(&found)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// NotifyThread tells the followers of the thread rooted at rootID about
+// reply, except its sender and followers who muted the room or turned its
+// notifications off. Followers the reply mentions are skipped too, having
+// been pinged by Notify already, so followers on the "mentions" level hear
+// nothing more. Followers get the event on every connection, since being
+// in the room does not mean looking at the thread.
+func NotifyThread(db *sql.DB, rootID int64, reply *models.Message, mentioned map[int64]bool) {
+	rows, err := db.Query(`SELECT f.user_id, rm.muted_until, rm.notify_level FROM thread_followers f
+		JOIN room_members rm ON rm.room_id = ? AND rm.user_id = f.user_id
+		WHERE f.message_id = ? AND f.user_id <> ?`, reply.RoomID, rootID, reply.SenderID)
+	if err != nil {
+		log.Printf("notify thread %d: %v", rootID, err)
+		return
+	}
+	defer rows.Close()
+
+	preview := reply.Content
+	if r := []rune(preview); len(r) > previewLen {
+		preview = string(r[:previewLen]) + "…"
+	}
+	b, _ := json.Marshal(map[string]interface{}{
+		"type":       "thread_reply",
+		"room_id":    reply.RoomID,
+		"thread_id":  rootID,
+		"message_id": reply.ID,
+		"sender_id":  reply.SenderID,
+		"preview":    preview,
+	})
+
+	now := time.Now()
+	for rows.Next() {
+		var userID int64
+		var mutedUntil sql.NullTime
+		var level string
+		if err := rows.Scan(&userID, &mutedUntil, &level); err != nil {
+			continue
+		}
+		if (mutedUntil.Valid && mutedUntil.Time.After(now)) || mentioned[userID] || level != NotifyAll {
+			continue
+		}
+		ws.SendToUser(userID, 0, b)
+	}
+}
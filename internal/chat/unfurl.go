@@ -0,0 +1,186 @@
+package chat
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"log"
+	"time"
+
+	"convo/internal/models"
+	"convo/internal/unfurl"
+)
+
+// Unfurler fetches link previews; nil turns them off. It is set from config
+// at startup.
+var Unfurler *unfurl.Fetcher
+
+const (
+	// maxPreviews is how many links of one message get a preview.
+	maxPreviews = 3
+	// previewTTL is how long a fetched preview is reused, failedPreviewTTL
+	// how long a failed fetch is remembered.
+	previewTTL       = 24 * time.Hour
+	failedPreviewTTL = time.Hour
+	// unfurlTimeout bounds the previews of one message.
+	unfurlTimeout = 30 * time.Second
+)
+
+// unfurlSlots bounds how many messages are unfurled at once.
+var unfurlSlots = make(chan struct{}, 8)
+
+// unfurlMessage attaches previews of the links in m to it, replacing any it
+// had, and sends the room a "message_updated" event with them. Rooms can
+// opt out with rooms.link_previews. It is meant to run in its own
+// goroutine after a send or edit; a preview fetched for content that has
+// since been edited or deleted is dropped.
+func unfurlMessage(db *sql.DB, m *models.Message, edited bool) {
+	if Unfurler == nil || m.Kind != KindText {
+		return
+	}
+	urls := unfurl.ExtractURLs(m.Content, maxPreviews)
+	if len(urls) == 0 && !edited {
+		return
+	}
+	var enabled bool
+	if err := db.QueryRow("SELECT link_previews FROM rooms WHERE id = ?", m.RoomID).Scan(&enabled); err != nil {
+		log.Printf("unfurl message %d: %v", m.ID, err)
+		return
+	}
+	if !enabled {
+		urls = nil
+	}
+
+	unfurlSlots <- struct{}{}
+	defer func() { <-unfurlSlots }()
+	ctx, cancel := context.WithTimeout(context.Background(), unfurlTimeout)
+	defer cancel()
+
+	previews := []models.LinkPreview{}
+	var hashes []string
+	for _, u := range urls {
+		p, hash, err := linkPreview(ctx, db, u)
+		if err != nil {
+			log.Printf("unfurl %s: %v", u, err)
+			continue
+		}
+		if p != nil {
+			previews = append(previews, *p)
+			hashes = append(hashes, hash)
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		log.Printf("unfurl message %d: %v", m.ID, err)
+		return
+	}
+	defer tx.Rollback()
+	var current string
+	err = tx.QueryRow("SELECT content FROM messages WHERE id = ? AND deleted_at IS NULL FOR UPDATE", m.ID).Scan(&current)
+	if err != nil || current != m.Content {
+		return
+	}
+	res, err := tx.Exec("DELETE FROM message_link_previews WHERE message_id = ?", m.ID)
+	if err != nil {
+		log.Printf("unfurl message %d: %v", m.ID, err)
+		return
+	}
+	removed, _ := res.RowsAffected()
+	for i, hash := range hashes {
+		if _, err := tx.Exec("INSERT INTO message_link_previews (message_id, position, url_hash) VALUES (?, ?, ?)", m.ID, i, hash); err != nil {
+			log.Printf("unfurl message %d: %v", m.ID, err)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("unfurl message %d: %v", m.ID, err)
+		return
+	}
+	if len(previews) == 0 && removed == 0 {
+		return
+	}
+	Broadcast(m.RoomID, map[string]interface{}{
+		"type":       "message_updated",
+		"room_id":    m.RoomID,
+		"message_id": m.ID,
+		"previews":   previews,
+	})
+}
+
+// linkPreview returns the preview of rawURL from the cache or by fetching
+// it, along with its cache key. A nil preview means the page has none.
+func linkPreview(ctx context.Context, db *sql.DB, rawURL string) (*models.LinkPreview, string, error) {
+	sum := sha256.Sum256([]byte(rawURL))
+	hash := hex.EncodeToString(sum[:])
+
+	var ok bool
+	var title, description, imageURL, siteName sql.NullString
+	err := db.QueryRow(`SELECT ok, title, description, image_url, site_name FROM link_previews
+		WHERE url_hash = ? AND fetched_at > NOW() - INTERVAL IF(ok, ?, ?) SECOND`,
+		hash, int(previewTTL/time.Second), int(failedPreviewTTL/time.Second)).
+		Scan(&ok, &title, &description, &imageURL, &siteName)
+	switch {
+	case err == nil && !ok:
+		return nil, hash, nil
+	case err == nil:
+		return &models.LinkPreview{URL: rawURL, Title: title.String, Description: description.String,
+			ImageURL: imageURL.String, SiteName: siteName.String}, hash, nil
+	case err != sql.ErrNoRows:
+		return nil, "", err
+	}
+
+	p, fetchErr := Unfurler.Fetch(ctx, rawURL)
+	if fetchErr != nil {
+		// remembered as a failure so it is not refetched for a while
+		p = &unfurl.Preview{}
+	}
+	_, err = db.Exec(`INSERT INTO link_previews (url_hash, url, ok, title, description, image_url, site_name, fetched_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, NOW())
+		ON DUPLICATE KEY UPDATE ok = VALUES(ok), title = VALUES(title), description = VALUES(description),
+			image_url = VALUES(image_url), site_name = VALUES(site_name), fetched_at = VALUES(fetched_at)`,
+		hash, rawURL, fetchErr == nil, nullString(p.Title), nullString(p.Description), nullString(p.ImageURL), nullString(p.SiteName))
+	if err != nil {
+		return nil, "", err
+	}
+	if fetchErr != nil {
+		return nil, hash, nil
+	}
+	return &models.LinkPreview{URL: rawURL, Title: p.Title, Description: p.Description, ImageURL: p.ImageURL, SiteName: p.SiteName}, hash, nil
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// loadPreviews returns the stored previews of the messages with ids.
+func loadPreviews(db *sql.DB, ids []int64) (map[int64][]models.LinkPreview, error) {
+	previews := make(map[int64][]models.LinkPreview)
+	if len(ids) == 0 {
+		return previews, nil
+	}
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	rows, err := db.Query(`SELECT mlp.message_id, lp.url, lp.title, lp.description, lp.image_url, lp.site_name
+		FROM message_link_previews mlp JOIN link_previews lp ON lp.url_hash = mlp.url_hash
+		WHERE mlp.message_id IN (`+placeholders(len(ids))+`) AND lp.ok
+		ORDER BY mlp.message_id, mlp.position`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		var p models.LinkPreview
+		var title, description, imageURL, siteName sql.NullString
+		if err := rows.Scan(&id, &p.URL, &title, &description, &imageURL, &siteName); err != nil {
+			return nil, err
+		}
+		p.Title, p.Description, p.ImageURL, p.SiteName = title.String, description.String, imageURL.String, siteName.String
+		previews[id] = append(previews[id], p)
+	}
+	return previews, rows.Err()
+}
@@ -0,0 +1,329 @@
+package chat
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"time"
+
+	"convo/internal/models"
+)
+
+// UploadExpiry is how long a resumable upload may go without receiving a
+// chunk before it is abandoned and deleted.
+var UploadExpiry = 24 * time.Hour
+
+var (
+	// ErrUploadNotFound is returned for an upload that does not exist,
+	// has expired or belongs to somebody else.
+	ErrUploadNotFound = errors.New("upload not found")
+	// ErrUploadOffset is returned when a chunk does not start where the
+	// upload currently ends.
+	ErrUploadOffset = errors.New("chunk offset does not match upload offset")
+	// ErrChunkTooLarge is returned when a chunk would run past the
+	// upload's declared length.
+	ErrChunkTooLarge = errors.New("chunk exceeds upload length")
+	// ErrChecksumMismatch is returned when a chunk does not match the
+	// checksum sent with it; the chunk is discarded.
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+	// ErrUnsupportedChecksum is returned for an unknown checksum algorithm.
+	ErrUnsupportedChecksum = errors.New("unsupported checksum algorithm")
+)
+
+// ChecksumAlgorithms are the algorithms chunk checksums may use.
+var ChecksumAlgorithms = map[string]func() hash.Hash{
+	"sha256": sha256.New,
+	"sha1":   sha1.New,
+	"md5":    md5.New,
+}
+
+// Checksum is the expected digest of a chunk.
+type Checksum struct {
+	Algorithm string
+	Sum       []byte
+}
+
+// CreateUpload starts a resumable upload of a file of length bytes to
+// roomID for userID. Chunks are sent with WriteChunk.
+func CreateUpload(db *sql.DB, roomID, userID int64, filename string, length int64) (*models.Upload, error) {
+	if Blobs == nil {
+		return nil, ErrAttachmentsDisabled
+	}
+	if length <= 0 || length > MaxAttachmentSize {
+		return nil, ErrAttachmentTooLarge
+	}
+	if _, err := GetRole(db, roomID, userID); err != nil {
+		return nil, err
+	}
+	// the id is the capability to the upload's URL, so it is random
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	id := hex.EncodeToString(nonce)
+	if _, err := db.Exec(`INSERT INTO uploads (id, room_id, user_id, filename, length, expires_at)
+		VALUES (?, ?, ?, ?, ?, NOW() + INTERVAL ? SECOND)`,
+		id, roomID, userID, cleanFilename(filename), length, int(UploadExpiry/time.Second)); err != nil {
+		return nil, err
+	}
+	return GetUpload(db, roomID, id, userID)
+}
+
+// GetUpload loads upload id of userID in roomID.
+func GetUpload(db *sql.DB, roomID int64, id string, userID int64) (*models.Upload, error) {
+	var u models.Upload
+	var attachmentID sql.NullInt64
+	err := db.QueryRow(`SELECT id, room_id, user_id, filename, length, upload_offset, attachment_id, created_at, expires_at
+		FROM uploads WHERE id = ? AND room_id = ? AND user_id = ? AND (attachment_id IS NOT NULL OR expires_at > NOW())`, id, roomID, userID).
+		Scan(&u.ID, &u.RoomID, &u.UserID, &u.Filename, &u.Length, &u.Offset, &attachmentID, &u.CreatedAt, &u.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrUploadNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if attachmentID.Valid {
+		a, err := GetAttachment(db, roomID, attachmentID.Int64, userID)
+		if err != nil && err != ErrAttachmentNotFound {
+			return nil, err
+		}
+		u.Attachment = a
+	}
+	return &u, nil
+}
+
+// WriteChunk appends size bytes read from r to upload id at offset, which
+// must be the upload's current offset, verifying them against sum if it is
+// not nil. The chunk that completes the upload also assembles it into an
+// attachment, returned in the upload's Attachment; a zero-length chunk at
+// the end retries an assembly that failed.
+func WriteChunk(db *sql.DB, roomID int64, id string, userID, offset int64, r io.Reader, size int64, sum *Checksum) (*models.Upload, error) {
+	if Blobs == nil {
+		return nil, ErrAttachmentsDisabled
+	}
+	u, err := GetUpload(db, roomID, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if u.Attachment != nil || offset != u.Offset {
+		return nil, ErrUploadOffset
+	}
+	if size < 0 || offset+size > u.Length {
+		return nil, ErrChunkTooLarge
+	}
+	var h hash.Hash
+	if sum != nil {
+		newHash := ChecksumAlgorithms[sum.Algorithm]
+		if newHash == nil {
+			return nil, ErrUnsupportedChecksum
+		}
+		h = newHash()
+	}
+
+	if size > 0 {
+		body := io.LimitReader(r, size)
+		if h != nil {
+			body = io.TeeReader(body, h)
+		}
+		// concurrent attempts at the same offset each write their own blob,
+		// so the one that loses addChunk cannot clobber or delete the
+		// winner's
+		nonce := make([]byte, 8)
+		if _, err := rand.Read(nonce); err != nil {
+			return nil, err
+		}
+		key := fmt.Sprintf("uploads/%s/%d-%s", id, offset, hex.EncodeToString(nonce))
+		if err := Blobs.Put(context.Background(), key, body, size, "application/octet-stream"); err != nil {
+			removeBlobs([]string{key})
+			return nil, err
+		}
+		if h != nil && !bytes.Equal(h.Sum(nil), sum.Sum) {
+			removeBlobs([]string{key})
+			return nil, ErrChecksumMismatch
+		}
+		if err := addChunk(db, id, offset, size, key); err != nil {
+			removeBlobs([]string{key})
+			return nil, err
+		}
+		u.Offset += size
+	}
+
+	if u.Offset == u.Length {
+		if err := finishUpload(db, u); err != nil {
+			return nil, err
+		}
+	}
+	return GetUpload(db, roomID, id, userID)
+}
+
+// addChunk records a stored chunk and moves the upload's offset past it,
+// unless a concurrent request got there first.
+func addChunk(db *sql.DB, id string, offset, size int64, key string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	res, err := tx.Exec(`UPDATE uploads SET upload_offset = upload_offset + ?, expires_at = NOW() + INTERVAL ? SECOND
+		WHERE id = ? AND upload_offset = ?`, size, int(UploadExpiry/time.Second), id, offset)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrUploadOffset
+	}
+	if _, err := tx.Exec("INSERT INTO upload_chunks (upload_id, chunk_offset, size, storage_key) VALUES (?, ?, ?, ?)",
+		id, offset, size, key); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// finishUpload streams the chunks of a complete upload into a new
+// attachment and drops them. The upload row stays locked meanwhile so the
+// file is only assembled once.
+func finishUpload(db *sql.DB, u *models.Upload) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	var attachmentID sql.NullInt64
+	if err := tx.QueryRow("SELECT attachment_id FROM uploads WHERE id = ? FOR UPDATE", u.ID).Scan(&attachmentID); err != nil {
+		return err
+	}
+	if attachmentID.Valid {
+		return nil
+	}
+	keys, err := chunkKeys(tx, u.ID)
+	if err != nil {
+		return err
+	}
+
+	a, err := UploadAttachment(db, u.RoomID, u.UserID, u.Filename, &chunkReader{keys: keys}, u.Length)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE uploads SET attachment_id = ? WHERE id = ?", a.ID, u.ID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM upload_chunks WHERE upload_id = ?", u.ID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	removeBlobs(keys)
+	return nil
+}
+
+func chunkKeys(q rowsQuerier, id string) ([]string, error) {
+	rows, err := q.Query("SELECT storage_key FROM upload_chunks WHERE upload_id = ? ORDER BY chunk_offset", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// chunkReader reads the blobs keys one after the other, opening each only
+// when it is reached.
+type chunkReader struct {
+	keys []string
+	cur  io.ReadCloser
+}
+
+func (c *chunkReader) Read(p []byte) (int, error) {
+	for {
+		if c.cur == nil {
+			if len(c.keys) == 0 {
+				return 0, io.EOF
+			}
+			body, err := Blobs.Get(context.Background(), c.keys[0])
+			if err != nil {
+				return 0, err
+			}
+			c.cur, c.keys = body, c.keys[1:]
+		}
+		n, err := c.cur.Read(p)
+		if err == io.EOF {
+			c.cur.Close()
+			c.cur = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+// DeleteUpload abandons upload id, discarding what was received. An
+// attachment it already produced is not affected.
+func DeleteUpload(db *sql.DB, roomID int64, id string, userID int64) error {
+	if _, err := GetUpload(db, roomID, id, userID); err != nil {
+		return err
+	}
+	return deleteUploads(db, []string{id})
+}
+
+func deleteUploads(db *sql.DB, ids []string) error {
+	var keys []string
+	for _, id := range ids {
+		k, err := chunkKeys(db, id)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, k...)
+	}
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	if _, err := db.Exec(`DELETE FROM uploads WHERE id IN (`+placeholders(len(ids))+`)`, args...); err != nil {
+		return err
+	}
+	removeBlobs(keys)
+	return nil
+}
+
+// pruneUploads deletes uploads that received nothing for UploadExpiry:
+// abandoned ones with their chunks, and finished ones whose attachment has
+// long been handed out.
+func pruneUploads(db *sql.DB) error {
+	rows, err := db.Query("SELECT id FROM uploads WHERE expires_at <= NOW() LIMIT ?", reapBatch)
+	if err != nil {
+		return err
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil || len(ids) == 0 {
+		return err
+	}
+	return deleteUploads(db, ids)
+}
@@ -0,0 +1,77 @@
+package chat
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// Workspace roles stored in workspace_members.role.
+const (
+	WorkspaceOwner  = "owner"
+	WorkspaceAdmin  = "admin"
+	WorkspaceMember = "member"
+)
+
+// ErrNotWorkspaceMember is returned when the user does not belong to the
+// workspace.
+var ErrNotWorkspaceMember = errors.New("not a member of workspace")
+
+// querier is satisfied by *sql.DB and *sql.Tx.
+type querier interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// WorkspaceRole returns the role of userID in workspaceID, or
+// ErrNotWorkspaceMember.
+func WorkspaceRole(q querier, workspaceID, userID int64) (string, error) {
+	var role string
+	err := q.QueryRow("SELECT role FROM workspace_members WHERE workspace_id = ? AND user_id = ?", workspaceID, userID).Scan(&role)
+	if err == sql.ErrNoRows {
+		return "", ErrNotWorkspaceMember
+	}
+	return role, err
+}
+
+// IsWorkspaceAdmin reports whether role may manage a workspace.
+func IsWorkspaceAdmin(role string) bool {
+	return role == WorkspaceOwner || role == WorkspaceAdmin
+}
+
+// RoomWorkspace returns the workspace owning roomID; the result is invalid
+// for rooms that live outside any workspace. It returns sql.ErrNoRows when
+// the room does not exist.
+func RoomWorkspace(q querier, roomID int64) (sql.NullInt64, error) {
+	var ws sql.NullInt64
+	err := q.QueryRow("SELECT workspace_id FROM rooms WHERE id = ?", roomID).Scan(&ws)
+	return ws, err
+}
+
+// CanJoinRoom reports whether userID may be added to roomID. Rooms in a
+// workspace take only members of that workspace; a room outside any
+// workspace takes only its creator and users who share a workspace with
+// the creator, so it cannot be used to reach people in other workspaces.
+func CanJoinRoom(q querier, roomID, userID int64) (bool, error) {
+	var workspace sql.NullInt64
+	var creator int64
+	if err := q.QueryRow("SELECT workspace_id, created_by FROM rooms WHERE id = ?", roomID).Scan(&workspace, &creator); err != nil {
+		return false, err
+	}
+	if workspace.Valid {
+		_, err := WorkspaceRole(q, workspace.Int64, userID)
+		if err == ErrNotWorkspaceMember {
+			return false, nil
+		}
+		return err == nil, err
+	}
+	if userID == creator {
+		return true, nil
+	}
+	var shared int
+	err := q.QueryRow(`SELECT 1 FROM workspace_members a
+		JOIN workspace_members b ON b.workspace_id = a.workspace_id
+		WHERE a.user_id = ? AND b.user_id = ? LIMIT 1`, creator, userID).Scan(&shared)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -14,6 +14,40 @@ type Config struct {
 	JWTSecret  string
 	JWTTTLHrs  int
 	Env        string
+	// SendRateLimit messages per SendRateWindowSecs, per user, across rooms
+	SendRateLimit      int
+	SendRateWindowSecs int
+	// EditWindowMins is how long senders may edit a message, 0 = forever
+	EditWindowMins int
+	// MaxPinnedMessages per room, 0 = unlimited
+	MaxPinnedMessages int
+	// SearchBackend is "mysql" (FULLTEXT) or "memory" (in-process index)
+	SearchBackend string
+	// SchedulerIntervalSecs is how often due scheduled messages are sent
+	SchedulerIntervalSecs int
+	// ReaperIntervalSecs is how often expired messages are purged
+	ReaperIntervalSecs int
+	// DedupeWindowMins is how long client_msg_ids are remembered
+	DedupeWindowMins int
+	// LinkPreviews turns link unfurling on ("on") or off ("off")
+	LinkPreviews string
+	// LinkPreviewTimeoutSecs and LinkPreviewMaxKB limit each page fetch
+	LinkPreviewTimeoutSecs int
+	LinkPreviewMaxKB       int
+	// StorageBackend is "local", "s3" or "none" (attachments off)
+	StorageBackend  string
+	StorageLocalDir string
+	// S3* configure the s3 backend; S3PathStyle for MinIO and other stand-ins
+	S3Endpoint  string
+	S3Region    string
+	S3Bucket    string
+	S3AccessKey string
+	S3SecretKey string
+	S3PathStyle bool
+	// MaxAttachmentMB is the largest upload
+	MaxAttachmentMB int
+	// AttachmentURLSecret signs download links; defaults to JWTSecret
+	AttachmentURLSecret string
 }
 
 func Load() *Config {
@@ -27,7 +61,28 @@ func Load() *Config {
 		JWTSecret: mustEnv("JWT_SECRET"),
 		JWTTTLHrs: ttl,
 		Env:       getEnv("ENV", "dev"),
+		SendRateLimit:      getEnvInt("SEND_RATE_LIMIT", 20),
+		SendRateWindowSecs: getEnvInt("SEND_RATE_WINDOW_SECONDS", 10),
+		EditWindowMins:     getEnvInt("EDIT_WINDOW_MINUTES", 15),
+		MaxPinnedMessages:  getEnvInt("MAX_PINNED_MESSAGES", 50),
+		SearchBackend:      getEnv("SEARCH_BACKEND", "mysql"),
+		SchedulerIntervalSecs: getEnvInt("SCHEDULER_INTERVAL_SECONDS", 5),
+		ReaperIntervalSecs:    getEnvInt("REAPER_INTERVAL_SECONDS", 60),
+		DedupeWindowMins:      getEnvInt("DEDUPE_WINDOW_MINUTES", 24*60),
+		LinkPreviews:          getEnv("LINK_PREVIEWS", "on"),
+		LinkPreviewTimeoutSecs: getEnvInt("LINK_PREVIEW_TIMEOUT_SECONDS", 5),
+		LinkPreviewMaxKB:       getEnvInt("LINK_PREVIEW_MAX_KB", 512),
+		StorageBackend:  getEnv("STORAGE_BACKEND", "local"),
+		StorageLocalDir: getEnv("STORAGE_LOCAL_DIR", "data/blobs"),
+		S3Endpoint:      getEnv("S3_ENDPOINT", "https://s3.amazonaws.com"),
+		S3Region:        getEnv("S3_REGION", "us-east-1"),
+		S3Bucket:        getEnv("S3_BUCKET", ""),
+		S3AccessKey:     getEnv("S3_ACCESS_KEY", ""),
+		S3SecretKey:     getEnv("S3_SECRET_KEY", ""),
+		S3PathStyle:     getEnv("S3_PATH_STYLE", "false") == "true",
+		MaxAttachmentMB: getEnvInt("MAX_ATTACHMENT_MB", 25),
 	}
+	c.AttachmentURLSecret = getEnv("ATTACHMENT_URL_SECRET", c.JWTSecret)
 	log.Printf("config loaded: env=%s port=%s", c.Env, c.Port)
 	return c
 }
@@ -36,6 +91,11 @@ func getEnv(k, def string) string {
 	if v := os.Getenv(k); v != "" { return v }
 	return def
 }
+func getEnvInt(k string, def int) int {
+	v, err := strconv.Atoi(getEnv(k, strconv.Itoa(def)))
+	if err != nil { return def }
+	return v
+}
 func mustEnv(k string) string {
 	v := os.Getenv(k)
 	if v == "" { log.Fatalf("missing env: %s", k) }
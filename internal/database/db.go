@@ -9,7 +9,8 @@ import (
 	"time"
 	"path/filepath"
 	"fmt"
-	"sort"	
+	"sort"
+	"strings"
 
 )
 
@@ -44,18 +45,108 @@ func RunMigrations(migrationsDir string) error {
     // ensure files run in order: 001 -> 002 -> 003
     sort.Strings(files)
 
+    // migrations that ALTER existing tables are not safe to re-run on every
+    // start, so remember which files have already been applied
+    if _, err := DB.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+        name VARCHAR(255) PRIMARY KEY,
+        applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+    ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`); err != nil {
+        return fmt.Errorf("failed to create schema_migrations: %w", err)
+    }
+
 	for _, file := range files {
+    name := filepath.Base(file)
+    var applied int
+    err := DB.QueryRow("SELECT 1 FROM schema_migrations WHERE name = ?", name).Scan(&applied)
+    if err == nil {
+        continue
+    } else if err != sql.ErrNoRows {
+        return fmt.Errorf("failed to check migration %s: %w", file, err)
+    }
+
     b, err := ioutil.ReadFile(file)
     if err != nil {
         return fmt.Errorf("failed to read migration %s: %w", file, err)
     }
-    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-    defer cancel()
-    if _, err := DB.ExecContext(ctx, string(b)); err != nil {
-        return fmt.Errorf("migration %s failed: %w", file, err)
+    // a multi-statement file can fail halfway through, and MySQL commits
+    // each ALTER on its own, so record every statement as it lands and
+    // pick up after the last one on the next start
+    for i, stmt := range splitStatements(string(b)) {
+        step := fmt.Sprintf("%s#%d", name, i+1)
+        err := DB.QueryRow("SELECT 1 FROM schema_migrations WHERE name = ?", step).Scan(&applied)
+        if err == nil {
+            continue
+        } else if err != sql.ErrNoRows {
+            return fmt.Errorf("failed to check migration %s: %w", step, err)
+        }
+        ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+        _, err = DB.ExecContext(ctx, stmt)
+        cancel()
+        if err != nil {
+            return fmt.Errorf("migration %s failed: %w", step, err)
+        }
+        if _, err := DB.Exec("INSERT INTO schema_migrations (name) VALUES (?)", step); err != nil {
+            return fmt.Errorf("failed to record migration %s: %w", step, err)
+        }
+    }
+    if _, err := DB.Exec("INSERT INTO schema_migrations (name) VALUES (?)", name); err != nil {
+        return fmt.Errorf("failed to record migration %s: %w", file, err)
+    }
+    if _, err := DB.Exec("DELETE FROM schema_migrations WHERE name LIKE ?", name+"#%"); err != nil {
+        return fmt.Errorf("failed to record migration %s: %w", file, err)
     }
     log.Printf("✅ migration applied: %s", file)
 }
 
 return nil
 }
+
+
+// splitStatements cuts a migration file at top-level semicolons, skipping
+// those inside quotes and -- comments, and drops empty statements.
+func splitStatements(src string) []string {
+	var stmts []string
+	var quote byte
+	start := 0
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '-' && strings.HasPrefix(src[i:], "--"):
+			if n := strings.IndexByte(src[i:], '\n'); n >= 0 {
+				i += n
+			} else {
+				i = len(src)
+			}
+		case c == ';':
+			stmts = appendStatement(stmts, src[start:i])
+			start = i + 1
+		}
+	}
+	return appendStatement(stmts, src[start:])
+}
+
+func appendStatement(stmts []string, s string) []string {
+	s = strings.TrimSpace(s)
+	// a chunk holding only comments has nothing to run
+	rest := s
+	for strings.HasPrefix(rest, "--") {
+		n := strings.IndexByte(rest, '\n')
+		if n < 0 {
+			rest = ""
+			break
+		}
+		rest = strings.TrimSpace(rest[n:])
+	}
+	if rest == "" {
+		return stmts
+	}
+	return append(stmts, s)
+}
@@ -0,0 +1,68 @@
+package attachment
+
+import (
+	"database/sql"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"convo/internal/chat"
+	"convo/internal/utils"
+)
+
+// DownloadHandler serves attachment contents. It needs no token: the URL
+// itself, signed and handed out only to room members, is the credential,
+// so it also works for <img src> and plain links.
+type DownloadHandler struct {
+	DB *sql.DB
+}
+
+// ServeHTTP handles GET /attachments/{attId}?expires=&sig=
+func (h *DownloadHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "attId"), 10, 64)
+	if err != nil {
+		utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "invalid attachment id"})
+		return
+	}
+	expires, err := strconv.ParseInt(r.URL.Query().Get("expires"), 10, 64)
+	if err != nil {
+		utils.JSON(w, http.StatusForbidden, utils.APIResponse{Success: false, Message: chat.ErrInvalidDownloadURL.Error()})
+		return
+	}
+
+	a, body, err := chat.OpenAttachment(h.DB, id, expires, r.URL.Query().Get("sig"))
+	switch err {
+	case nil:
+	case chat.ErrInvalidDownloadURL:
+		utils.JSON(w, http.StatusForbidden, utils.APIResponse{Success: false, Message: err.Error()})
+		return
+	case chat.ErrAttachmentNotFound:
+		utils.JSON(w, http.StatusNotFound, utils.APIResponse{Success: false, Message: err.Error()})
+		return
+	default:
+		utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "failed to read attachment", Data: map[string]interface{}{"error": err.Error()}})
+		return
+	}
+	defer body.Close()
+
+	// only media is shown inline; anything else, HTML included, is a
+	// download so it cannot run in our origin
+	disposition := "attachment"
+	for _, prefix := range []string{"image/", "video/", "audio/"} {
+		if strings.HasPrefix(a.ContentType, prefix) {
+			disposition = "inline"
+		}
+	}
+	w.Header().Set("Content-Type", a.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(a.Size, 10))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": a.Filename}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "sandbox")
+	w.Header().Set("Cache-Control", "private, max-age=900")
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, body)
+}
@@ -7,10 +7,12 @@ import (
     "strings"
     "strconv"
     "fmt"
+    "time"
 
     mysql "github.com/go-sql-driver/mysql"
     "github.com/go-chi/chi/v5"
 
+    "convo/internal/chat"
     "convo/internal/middleware"
     "convo/internal/utils"
 )
@@ -52,8 +54,7 @@ func (h *AddMembersHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
     }
 
     // ensure room exists
-    var tmp int
-    if err := h.DB.QueryRow("SELECT 1 FROM rooms WHERE id = ?", roomID).Scan(&tmp); err == sql.ErrNoRows || err != nil {
+    if _, err := chat.RoomWorkspace(h.DB, roomID); err != nil {
         if err == sql.ErrNoRows {
             utils.JSON(w, http.StatusNotFound, utils.APIResponse{Success: false, Message: "room not found"})
             return
@@ -62,6 +63,15 @@ func (h *AddMembersHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
         return
     }
 
+    // only members can add people to a room
+    if isMember, err := chat.IsMember(h.DB, roomID, userID); err != nil {
+        utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "DB error checking membership", Data: map[string]interface{}{"error": err.Error()}})
+        return
+    } else if !isMember {
+        utils.JSON(w, http.StatusForbidden, utils.APIResponse{Success: false, Message: "not a member of room"})
+        return
+    }
+
     tx, err := h.DB.Begin()
     if err != nil {
         utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "Failed to start tx", Data: map[string]interface{}{"error": err.Error()}})
@@ -84,6 +94,15 @@ func (h *AddMembersHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
                 // skip invalid id
                 continue
             }
+            // users the room may not take must not be discoverable
+            if allowed, err := chat.CanJoinRoom(tx, roomID, idVal); err != nil || !allowed {
+                errs = append(errs, fmt.Sprintf("id %d: not found", idVal))
+                continue
+            }
+            if msg := banned(tx, roomID, idVal); msg != "" {
+                errs = append(errs, fmt.Sprintf("id %d: %s", idVal, msg))
+                continue
+            }
             if _, err := tx.Exec("INSERT INTO room_members (room_id, user_id) VALUES (?, ?)", roomID, idVal); err != nil {
                 // if duplicate key, ignore; otherwise record error
                 if me, ok := err.(*mysql.MySQLError); ok {
@@ -102,7 +121,17 @@ func (h *AddMembersHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
                 continue
             }
             var id int64
-            if err := tx.QueryRow("SELECT id FROM users WHERE email = ?", e).Scan(&id); err == nil {
+            err := tx.QueryRow("SELECT id FROM users WHERE email = ?", e).Scan(&id)
+            if err == nil {
+                if allowed, cerr := chat.CanJoinRoom(tx, roomID, id); cerr != nil || !allowed {
+                    err = sql.ErrNoRows
+                }
+            }
+            if err == nil {
+                if msg := banned(tx, roomID, id); msg != "" {
+                    errs = append(errs, fmt.Sprintf("email %s: %s", e, msg))
+                    continue
+                }
                 if _, err := tx.Exec("INSERT INTO room_members (room_id, user_id) VALUES (?, ?)", roomID, id); err != nil {
                     if me, ok := err.(*mysql.MySQLError); ok {
                         if me.Number == 1062 {
@@ -137,3 +166,20 @@ func (h *AddMembersHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 type AddMembersHandler struct{
     DB *sql.DB
 }
+
+// banned returns a reason when userID may not be added to roomID because of
+// an active ban, or "" when adding is allowed. It reads through the
+// caller's tx so the check sees the same snapshot as the insert.
+func banned(tx *sql.Tx, roomID, userID int64) string {
+    ban, err := chat.ActiveBan(tx, roomID, userID)
+    if err != nil {
+        return fmt.Sprintf("ban check failed: %v", err)
+    }
+    if ban == nil {
+        return ""
+    }
+    if ban.ExpiresAt != nil {
+        return fmt.Sprintf("banned until %s", ban.ExpiresAt.UTC().Format(time.RFC3339))
+    }
+    return "banned"
+}
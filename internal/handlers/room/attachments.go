@@ -0,0 +1,103 @@
+package room
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"convo/internal/chat"
+	"convo/internal/middleware"
+	"convo/internal/utils"
+)
+
+// UploadAttachmentHandler stores a file for the caller to send in a room
+type UploadAttachmentHandler struct {
+	DB *sql.DB
+}
+
+// ServeHTTP handles POST /rooms/{id}/attachments with the file in the
+// multipart field "file". The returned id goes in attachment_ids when
+// sending.
+func (h *UploadAttachmentHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int64)
+	if !ok {
+		utils.JSON(w, http.StatusUnauthorized, utils.APIResponse{Success: false, Message: "Unauthorized"})
+		return
+	}
+	roomID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "invalid room id"})
+		return
+	}
+
+	utils.LimitUpload(w, r, chat.MaxAttachmentSize)
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeAttachmentError(w, chat.ErrAttachmentTooLarge)
+			return
+		}
+		utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "file required"})
+		return
+	}
+	defer file.Close()
+
+	a, err := chat.UploadAttachment(h.DB, roomID, userID, header.Filename, file, header.Size)
+	if err != nil {
+		writeAttachmentError(w, err)
+		return
+	}
+	utils.JSON(w, http.StatusCreated, utils.APIResponse{Success: true, Message: "attachment uploaded", Data: a})
+}
+
+// AttachmentHandler returns an attachment with a fresh download URL
+type AttachmentHandler struct {
+	DB *sql.DB
+}
+
+// ServeHTTP handles GET /rooms/{id}/attachments/{attId}
+func (h *AttachmentHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int64)
+	if !ok {
+		utils.JSON(w, http.StatusUnauthorized, utils.APIResponse{Success: false, Message: "Unauthorized"})
+		return
+	}
+	roomID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "invalid room id"})
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "attId"), 10, 64)
+	if err != nil {
+		utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "invalid attachment id"})
+		return
+	}
+
+	a, err := chat.GetAttachment(h.DB, roomID, id, userID)
+	if err != nil {
+		writeAttachmentError(w, err)
+		return
+	}
+	utils.JSON(w, http.StatusOK, utils.APIResponse{Success: true, Message: "attachment fetched", Data: a})
+}
+
+// writeAttachmentError maps an error from the chat attachment functions to
+// an API response.
+func writeAttachmentError(w http.ResponseWriter, err error) {
+	switch err {
+	case chat.ErrNotMember:
+		utils.JSON(w, http.StatusForbidden, utils.APIResponse{Success: false, Message: "not a member of room"})
+	case chat.ErrAttachmentNotFound:
+		utils.JSON(w, http.StatusNotFound, utils.APIResponse{Success: false, Message: err.Error()})
+	case chat.ErrAttachmentTooLarge:
+		utils.JSON(w, http.StatusRequestEntityTooLarge, utils.APIResponse{Success: false, Message: err.Error()})
+	case chat.ErrAttachmentsDisabled:
+		utils.JSON(w, http.StatusServiceUnavailable, utils.APIResponse{Success: false, Message: err.Error()})
+	default:
+		utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "failed to store attachment", Data: map[string]interface{}{"error": err.Error()}})
+	}
+}
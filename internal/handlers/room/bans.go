@@ -0,0 +1,138 @@
+package room
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"convo/internal/models"
+	"convo/internal/utils"
+	"convo/internal/ws"
+)
+
+type BanRequest struct {
+	UserID int64  `json:"user_id"`
+	Reason string `json:"reason,omitempty"`
+	// DurationSeconds limits the ban; 0 bans permanently.
+	DurationSeconds int64 `json:"duration_seconds,omitempty"`
+}
+
+// BanHandler bans a user from a room and removes their membership
+type BanHandler struct {
+	DB *sql.DB
+}
+
+// ServeHTTP handles POST /rooms/{id}/bans
+func (h *BanHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req BanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "Invalid request body"})
+		return
+	}
+	if req.DurationSeconds < 0 {
+		utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "duration_seconds must not be negative"})
+		return
+	}
+	userID, roomID, _, ok := moderationTarget(w, r, h.DB, req.UserID)
+	if !ok {
+		return
+	}
+
+	ban := models.RoomBan{RoomID: roomID, UserID: req.UserID, Reason: req.Reason, BannedBy: userID, CreatedAt: time.Now()}
+	if req.DurationSeconds > 0 {
+		expires := ban.CreatedAt.Add(time.Duration(req.DurationSeconds) * time.Second)
+		ban.ExpiresAt = &expires
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "Failed to start tx", Data: map[string]interface{}{"error": err.Error()}})
+		return
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`INSERT INTO room_bans (room_id, user_id, reason, banned_by, expires_at) VALUES (?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE reason = VALUES(reason), banned_by = VALUES(banned_by), expires_at = VALUES(expires_at), created_at = CURRENT_TIMESTAMP`,
+		roomID, req.UserID, req.Reason, userID, ban.ExpiresAt); err != nil {
+		utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "failed to ban user", Data: map[string]interface{}{"error": err.Error()}})
+		return
+	}
+	if _, err := tx.Exec("DELETE FROM room_members WHERE room_id = ? AND user_id = ?", roomID, req.UserID); err != nil {
+		utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "failed to remove member", Data: map[string]interface{}{"error": err.Error()}})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "Failed to commit tx", Data: map[string]interface{}{"error": err.Error()}})
+		return
+	}
+
+	b, _ := json.Marshal(map[string]interface{}{"type": "banned", "room_id": roomID, "reason": req.Reason, "expires_at": ban.ExpiresAt})
+	ws.Disconnect(roomID, req.UserID, b)
+
+	utils.JSON(w, http.StatusCreated, utils.APIResponse{Success: true, Message: "user banned", Data: ban})
+}
+
+// BanListHandler lists the active bans of a room for its moderators
+type BanListHandler struct {
+	DB *sql.DB
+}
+
+// ServeHTTP handles GET /rooms/{id}/bans
+func (h *BanListHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, roomID, ok := requireModerator(w, r, h.DB)
+	if !ok {
+		return
+	}
+
+	rows, err := h.DB.Query(`SELECT user_id, reason, banned_by, expires_at, created_at FROM room_bans
+		WHERE room_id = ? AND (expires_at IS NULL OR expires_at > NOW()) ORDER BY created_at DESC`, roomID)
+	if err != nil {
+		utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "DB error", Data: map[string]interface{}{"error": err.Error()}})
+		return
+	}
+	defer rows.Close()
+
+	bans := []models.RoomBan{}
+	for rows.Next() {
+		b := models.RoomBan{RoomID: roomID}
+		var expiresAt sql.NullTime
+		if err := rows.Scan(&b.UserID, &b.Reason, &b.BannedBy, &expiresAt, &b.CreatedAt); err != nil {
+			continue
+		}
+		if expiresAt.Valid {
+			b.ExpiresAt = &expiresAt.Time
+		}
+		bans = append(bans, b)
+	}
+	utils.JSON(w, http.StatusOK, utils.APIResponse{Success: true, Message: "bans fetched", Data: bans})
+}
+
+// UnbanHandler lifts a ban; the user still has to be added back to the room
+type UnbanHandler struct {
+	DB *sql.DB
+}
+
+// ServeHTTP handles DELETE /rooms/{id}/bans/{userId}
+func (h *UnbanHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, roomID, ok := requireModerator(w, r, h.DB)
+	if !ok {
+		return
+	}
+	targetID := pathUserID(r)
+	if targetID == 0 {
+		utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "invalid user id"})
+		return
+	}
+
+	res, err := h.DB.Exec("DELETE FROM room_bans WHERE room_id = ? AND user_id = ?", roomID, targetID)
+	if err != nil {
+		utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "failed to lift ban", Data: map[string]interface{}{"error": err.Error()}})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		utils.JSON(w, http.StatusNotFound, utils.APIResponse{Success: false, Message: "ban not found"})
+		return
+	}
+	utils.JSON(w, http.StatusOK, utils.APIResponse{Success: true, Message: "ban lifted", Data: map[string]interface{}{"room_id": roomID, "user_id": targetID}})
+}
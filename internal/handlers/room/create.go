@@ -6,6 +6,7 @@ import (
     "net/http"
     "time"
 
+    "convo/internal/chat"
     "convo/internal/middleware"
     "convo/internal/utils"
 )
@@ -17,12 +18,16 @@ type CreateRoomHandler struct {
 type CreateRoomRequest struct {
     Name string `json:"name"`
     OtherEmail string `json:"other_email,omitempty"`
+    // WorkspaceID places the room in a workspace the caller belongs to;
+    // members can then only be added from that workspace.
+    WorkspaceID *int64 `json:"workspace_id,omitempty"`
 }
 
 type CreateRoomResponse struct {
     ID        int64     `json:"id"`
     Name      string    `json:"name"`
     CreatedBy int64     `json:"created_by"`
+    WorkspaceID *int64  `json:"workspace_id,omitempty"`
     CreatedAt time.Time `json:"created_at"`
 }
 
@@ -50,7 +55,19 @@ func (h *CreateRoomHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
     }
     defer tx.Rollback()
 
-    result, err := tx.Exec("INSERT INTO rooms (name, created_by) VALUES (?, ?)", req.Name, userID)
+    var workspace sql.NullInt64
+    if req.WorkspaceID != nil {
+        if _, err := chat.WorkspaceRole(tx, *req.WorkspaceID, userID); err == chat.ErrNotWorkspaceMember {
+            utils.JSON(w, http.StatusNotFound, utils.APIResponse{Success: false, Message: "workspace not found"})
+            return
+        } else if err != nil {
+            utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "DB error checking workspace", Data: map[string]interface{}{"error": err.Error()}})
+            return
+        }
+        workspace = sql.NullInt64{Int64: *req.WorkspaceID, Valid: true}
+    }
+
+    result, err := tx.Exec("INSERT INTO rooms (name, created_by, workspace_id) VALUES (?, ?, ?)", req.Name, userID, workspace)
     if err != nil {
         utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "Failed to create room", Data: map[string]interface{}{"error": err.Error()}})
         return
@@ -58,7 +75,7 @@ func (h *CreateRoomHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
     id, _ := result.LastInsertId()
 
     // add creator to room_members
-    if _, err := tx.Exec("INSERT INTO room_members (room_id, user_id) VALUES (?, ?)", id, userID); err != nil {
+    if _, err := tx.Exec("INSERT INTO room_members (room_id, user_id, role) VALUES (?, ?, 'owner')", id, userID); err != nil {
         utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "Failed to add creator to room", Data: map[string]interface{}{"error": err.Error()}})
         return
     }
@@ -67,6 +84,17 @@ func (h *CreateRoomHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
     if req.OtherEmail != "" {
         var otherID int64
         err := tx.QueryRow("SELECT id FROM users WHERE email = ?", req.OtherEmail).Scan(&otherID)
+        if err == nil {
+            // users the room may not take are treated as not found
+            allowed, cerr := chat.CanJoinRoom(tx, id, otherID)
+            if cerr != nil {
+                utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "DB error", Data: map[string]interface{}{"error": cerr.Error()}})
+                return
+            }
+            if !allowed {
+                err = sql.ErrNoRows
+            }
+        }
         if err == nil {
             // user exists, insert membership
             if _, err := tx.Exec("INSERT INTO room_members (room_id, user_id) VALUES (?, ?)", id, otherID); err != nil {
@@ -90,6 +118,7 @@ func (h *CreateRoomHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
         ID:        id,
         Name:      req.Name,
         CreatedBy: userID,
+        WorkspaceID: req.WorkspaceID,
         CreatedAt: createdAt,
     }
 
@@ -0,0 +1,33 @@
+package room
+
+import (
+	"database/sql"
+	"net/http"
+
+	"convo/internal/chat"
+	"convo/internal/utils"
+)
+
+// DeleteMessageHandler deletes a message for the caller only or, for its
+// sender and room moderators, for everyone
+type DeleteMessageHandler struct {
+	DB *sql.DB
+}
+
+// ServeHTTP handles DELETE /rooms/{id}/messages/{msgId}?scope=me|everyone
+func (h *DeleteMessageHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	userID, roomID, msgID, ok := messagePath(w, r)
+	if !ok {
+		return
+	}
+	scope := r.URL.Query().Get("scope")
+	if scope == "" {
+		scope = chat.DeleteForMe
+	}
+
+	if err := chat.DeleteMessage(h.DB, roomID, msgID, userID, scope); err != nil {
+		writeMessageError(w, err)
+		return
+	}
+	utils.JSON(w, http.StatusOK, utils.APIResponse{Success: true, Message: "Message deleted", Data: map[string]interface{}{"room_id": roomID, "message_id": msgID, "scope": scope}})
+}
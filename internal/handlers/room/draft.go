@@ -0,0 +1,66 @@
+package room
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"convo/internal/chat"
+	"convo/internal/middleware"
+	"convo/internal/utils"
+)
+
+type DraftRequest struct {
+	Content string `json:"content"`
+}
+
+// DraftHandler reads (GET), saves (PUT) or discards (DELETE) the caller's
+// draft in a room
+type DraftHandler struct {
+	DB *sql.DB
+}
+
+// ServeHTTP handles GET, PUT and DELETE /rooms/{id}/draft
+func (h *DraftHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int64)
+	if !ok {
+		utils.JSON(w, http.StatusUnauthorized, utils.APIResponse{Success: false, Message: "Unauthorized"})
+		return
+	}
+	roomID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "invalid room id"})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		d, err := chat.GetDraft(h.DB, roomID, userID)
+		if err != nil {
+			writeMessageError(w, err)
+			return
+		}
+		utils.JSON(w, http.StatusOK, utils.APIResponse{Success: true, Message: "draft fetched", Data: d})
+	case http.MethodDelete:
+		if err := chat.DeleteDraft(h.DB, roomID, userID); err != nil {
+			writeMessageError(w, err)
+			return
+		}
+		utils.JSON(w, http.StatusOK, utils.APIResponse{Success: true, Message: "draft deleted", Data: map[string]interface{}{"room_id": roomID}})
+	default:
+		var req DraftRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "Invalid request body"})
+			return
+		}
+		d, err := chat.SaveDraft(h.DB, roomID, userID, req.Content)
+		if err != nil {
+			writeMessageError(w, err)
+			return
+		}
+		utils.JSON(w, http.StatusOK, utils.APIResponse{Success: true, Message: "draft saved", Data: d})
+	}
+}
@@ -0,0 +1,126 @@
+package room
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"convo/internal/chat"
+	"convo/internal/middleware"
+	"convo/internal/models"
+	"convo/internal/utils"
+)
+
+type EditMessageRequest struct {
+	Content string `json:"content"`
+}
+
+// EditMessageHandler lets a sender change their message within chat.EditWindow
+type EditMessageHandler struct {
+	DB *sql.DB
+}
+
+// ServeHTTP handles PATCH /rooms/{id}/messages/{msgId}
+func (h *EditMessageHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	userID, roomID, msgID, ok := messagePath(w, r)
+	if !ok {
+		return
+	}
+
+	var req EditMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "invalid request"})
+		return
+	}
+
+	m, err := chat.EditMessage(h.DB, roomID, msgID, userID, req.Content)
+	if err != nil {
+		writeMessageError(w, err)
+		return
+	}
+	utils.JSON(w, http.StatusOK, utils.APIResponse{Success: true, Message: "Message edited", Data: m})
+}
+
+// MessageEditsHandler returns the previous versions of a message
+type MessageEditsHandler struct {
+	DB *sql.DB
+}
+
+// ServeHTTP handles GET /rooms/{id}/messages/{msgId}/edits
+func (h *MessageEditsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	userID, roomID, msgID, ok := messagePath(w, r)
+	if !ok {
+		return
+	}
+	if _, err := chat.GetRole(h.DB, roomID, userID); err != nil {
+		writeMessageError(w, err)
+		return
+	}
+
+	rows, err := h.DB.Query(`SELECT e.id, e.editor_id, e.previous_content, e.edited_at FROM message_edits e
+		JOIN messages m ON m.id = e.message_id
+		WHERE e.message_id = ? AND m.room_id = ? ORDER BY e.id`, msgID, roomID)
+	if err != nil {
+		utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "DB error", Data: map[string]interface{}{"error": err.Error()}})
+		return
+	}
+	defer rows.Close()
+
+	edits := []models.MessageEdit{}
+	for rows.Next() {
+		e := models.MessageEdit{MessageID: msgID}
+		if err := rows.Scan(&e.ID, &e.EditorID, &e.PreviousContent, &e.EditedAt); err != nil {
+			continue
+		}
+		edits = append(edits, e)
+	}
+	utils.JSON(w, http.StatusOK, utils.APIResponse{Success: true, Message: "edits fetched", Data: edits})
+}
+
+// messagePath parses the caller, {id} and {msgId} of a message route. It
+// writes the error response itself and returns ok=false on failure.
+func messagePath(w http.ResponseWriter, r *http.Request) (userID, roomID, msgID int64, ok bool) {
+	userID, ok = r.Context().Value(middleware.UserIDKey).(int64)
+	if !ok {
+		utils.JSON(w, http.StatusUnauthorized, utils.APIResponse{Success: false, Message: "Unauthorized"})
+		return 0, 0, 0, false
+	}
+	roomID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "invalid room id"})
+		return 0, 0, 0, false
+	}
+	msgID, err = strconv.ParseInt(chi.URLParam(r, "msgId"), 10, 64)
+	if err != nil {
+		utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "invalid message id"})
+		return 0, 0, 0, false
+	}
+	return userID, roomID, msgID, true
+}
+
+// writeMessageError maps errors from the chat message operations to API
+// responses.
+func writeMessageError(w http.ResponseWriter, err error) {
+	switch err {
+	case chat.ErrNotMember:
+		utils.JSON(w, http.StatusForbidden, utils.APIResponse{Success: false, Message: "not a member of room"})
+	case chat.ErrMessageNotFound:
+		utils.JSON(w, http.StatusNotFound, utils.APIResponse{Success: false, Message: "message not found"})
+	case chat.ErrForbidden:
+		utils.JSON(w, http.StatusForbidden, utils.APIResponse{Success: false, Message: "not allowed on this message"})
+	case chat.ErrEditWindowClosed:
+		utils.JSON(w, http.StatusForbidden, utils.APIResponse{Success: false, Message: "edit window has closed"})
+	case chat.ErrMessageDeleted:
+		utils.JSON(w, http.StatusGone, utils.APIResponse{Success: false, Message: "message was deleted"})
+	case chat.ErrTooManyPins, chat.ErrPollClosed:
+		utils.JSON(w, http.StatusConflict, utils.APIResponse{Success: false, Message: err.Error()})
+	case chat.ErrEmptyContent, chat.ErrInvalidScope, chat.ErrInvalidStatus, chat.ErrInvalidEmoji, chat.ErrTooManyReactions, chat.ErrInvalidVote,
+		chat.ErrDraftTooLong, chat.ErrContentTooLong:
+		utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: err.Error()})
+	default:
+		utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "DB error", Data: map[string]interface{}{"error": err.Error()}})
+	}
+}
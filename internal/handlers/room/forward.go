@@ -0,0 +1,47 @@
+package room
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+
+	"convo/internal/chat"
+	"convo/internal/utils"
+)
+
+type ForwardMessageRequest struct {
+	RoomID int64 `json:"room_id"` // room to forward into
+}
+
+// ForwardMessageHandler copies a message into another room the caller
+// belongs to, crediting the original author
+type ForwardMessageHandler struct {
+	DB *sql.DB
+}
+
+// ServeHTTP handles POST /rooms/{id}/messages/{msgId}/forward
+func (h *ForwardMessageHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	userID, roomID, msgID, ok := messagePath(w, r)
+	if !ok {
+		return
+	}
+	var req ForwardMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RoomID == 0 {
+		utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "room_id required"})
+		return
+	}
+
+	m, err := chat.ForwardMessage(h.DB, roomID, msgID, userID, req.RoomID)
+	if err != nil {
+		writeSendError(w, err)
+		return
+	}
+	utils.JSON(w, http.StatusCreated, utils.APIResponse{Success: true, Message: "Message forwarded", Data: SendMessageResponse{
+		ID:       m.ID,
+		RoomID:   m.RoomID,
+		SenderID: m.SenderID,
+		Content:  m.Content,
+		Forward:  m.ForwardedFrom,
+		SentAt:   m.SentAt,
+	}})
+}
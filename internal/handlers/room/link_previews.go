@@ -0,0 +1,43 @@
+package room
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+
+	"convo/internal/chat"
+	"convo/internal/utils"
+)
+
+type LinkPreviewsRequest struct {
+	Enabled *bool `json:"enabled"`
+}
+
+// LinkPreviewsHandler lets moderators turn link previews in a room on or off
+type LinkPreviewsHandler struct {
+	DB *sql.DB
+}
+
+// ServeHTTP handles PUT /rooms/{id}/link-previews
+func (h *LinkPreviewsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, roomID, ok := requireModerator(w, r, h.DB)
+	if !ok {
+		return
+	}
+
+	var req LinkPreviewsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Enabled == nil {
+		utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "enabled required"})
+		return
+	}
+
+	if _, err := h.DB.Exec("UPDATE rooms SET link_previews = ? WHERE id = ?", *req.Enabled, roomID); err != nil {
+		utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "failed to update link previews", Data: map[string]interface{}{"error": err.Error()}})
+		return
+	}
+
+	data := map[string]interface{}{"room_id": roomID, "link_previews": *req.Enabled}
+	chat.Broadcast(roomID, map[string]interface{}{"type": "link_previews", "room_id": roomID, "link_previews": *req.Enabled})
+
+	utils.JSON(w, http.StatusOK, utils.APIResponse{Success: true, Message: "link previews updated", Data: data})
+}
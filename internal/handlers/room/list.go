@@ -3,7 +3,10 @@ package room
 import (
 	"database/sql"
 	"net/http"
+	"strconv"
+	"time"
 
+	"convo/internal/chat"
 	"convo/internal/middleware"
 	"convo/internal/utils"
 )
@@ -20,8 +23,31 @@ func (h *RoomListHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	rows, err := h.DB.Query(`SELECT r.id, r.name, r.created_by, r.created_at FROM rooms r
-		JOIN room_members m ON r.id = m.room_id WHERE m.user_id = ?`, userID)
+	// optional ?workspace_id= narrows the list to one workspace
+	var workspaceID int64
+	if v := r.URL.Query().Get("workspace_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "invalid workspace_id"})
+			return
+		}
+		workspaceID = id
+	}
+
+	// pinned rooms first; unread counts skip the caller's own messages,
+	// tombstones, messages they hid, and thread replies, which are tracked
+	// per thread
+	rows, err := h.DB.Query(`SELECT r.id, r.name, r.created_by, r.workspace_id, r.created_at, r.slow_mode_seconds, r.retention_seconds, r.disappearing_seconds, r.link_previews,
+		m.muted_until, m.notify_level, m.pinned, m.last_read_message_id, m.unread_mentions,
+		(SELECT COUNT(*) FROM messages msg WHERE msg.room_id = r.id
+			AND msg.id > m.last_read_message_id AND msg.sender_id <> m.user_id
+			AND msg.thread_id IS NULL AND msg.deleted_at IS NULL
+			AND NOT EXISTS (SELECT 1 FROM message_hidden h
+				WHERE h.message_id = msg.id AND h.user_id = m.user_id)) AS unread
+		FROM rooms r
+		JOIN room_members m ON r.id = m.room_id
+		WHERE m.user_id = ? AND (? = 0 OR r.workspace_id = ?)
+		ORDER BY m.pinned DESC, r.id`, userID, workspaceID, workspaceID)
 	if err != nil {
 		utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "DB error", Data: map[string]interface{}{ "error": err.Error() }})
 		return
@@ -29,17 +55,48 @@ func (h *RoomListHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	defer rows.Close()
 
 	type Room struct {
-		ID        int64  `json:"id"`
-		Name      string `json:"name"`
-		CreatedBy int64  `json:"created_by"`
-		CreatedAt string `json:"created_at"`
+		ID          int64      `json:"id"`
+		Name        string     `json:"name"`
+		CreatedBy   int64      `json:"created_by"`
+		WorkspaceID *int64     `json:"workspace_id,omitempty"`
+		CreatedAt   string     `json:"created_at"`
+		SlowMode    int        `json:"slow_mode_seconds"`
+		Retention   int        `json:"retention_seconds"`
+		Disappear   int        `json:"disappearing_seconds"`
+		Previews    bool       `json:"link_previews"`
+		Pinned      bool       `json:"pinned"`
+		NotifyLevel string     `json:"notify_level"`
+		MutedUntil  *time.Time `json:"muted_until,omitempty"`
+		// UnreadCount is zero for muted rooms and rooms set to notify on
+		// nothing; HasUnread still reports whether anything is unread.
+		UnreadCount int   `json:"unread_count"`
+		HasUnread   bool  `json:"has_unread"`
+		LastReadID  int64 `json:"last_read_message_id"`
+		// MentionCount badges unread mentions of the caller; unlike
+		// UnreadCount it is reported even for muted rooms.
+		MentionCount int `json:"mention_count"`
 	}
+	now := time.Now()
 	var rooms []Room
 	for rows.Next() {
 		var r Room
-		if err := rows.Scan(&r.ID, &r.Name, &r.CreatedBy, &r.CreatedAt); err != nil {
+		var mutedUntil sql.NullTime
+		var workspace sql.NullInt64
+		var unread int
+		if err := rows.Scan(&r.ID, &r.Name, &r.CreatedBy, &workspace, &r.CreatedAt, &r.SlowMode, &r.Retention, &r.Disappear, &r.Previews, &mutedUntil, &r.NotifyLevel, &r.Pinned, &r.LastReadID, &r.MentionCount, &unread); err != nil {
 			continue
 		}
+		if workspace.Valid {
+			r.WorkspaceID = &workspace.Int64
+		}
+		muted := mutedUntil.Valid && mutedUntil.Time.After(now)
+		if muted {
+			r.MutedUntil = &mutedUntil.Time
+		}
+		r.HasUnread = unread > 0
+		if !muted && r.NotifyLevel != chat.NotifyNone {
+			r.UnreadCount = unread
+		}
 		rooms = append(rooms, r)
 	}
 	utils.JSON(w, http.StatusOK, utils.APIResponse{Success: true, Message: "rooms fetched", Data: rooms})
@@ -0,0 +1,83 @@
+package room
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+
+	"convo/internal/chat"
+	"convo/internal/utils"
+	"convo/internal/ws"
+)
+
+// RemoveMemberHandler lets moderators kick a member out of a room
+type RemoveMemberHandler struct {
+	DB *sql.DB
+}
+
+// ServeHTTP handles DELETE /rooms/{id}/members/{userId}
+func (h *RemoveMemberHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	targetID := pathUserID(r)
+	_, roomID, targetRole, ok := moderationTarget(w, r, h.DB, targetID)
+	if !ok {
+		return
+	}
+	if targetRole == "" {
+		utils.JSON(w, http.StatusNotFound, utils.APIResponse{Success: false, Message: "user not a member of the room"})
+		return
+	}
+
+	if _, err := h.DB.Exec("DELETE FROM room_members WHERE room_id = ? AND user_id = ?", roomID, targetID); err != nil {
+		utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "failed to remove member", Data: map[string]interface{}{"error": err.Error()}})
+		return
+	}
+
+	b, _ := json.Marshal(map[string]interface{}{"type": "removed", "room_id": roomID})
+	ws.Disconnect(roomID, targetID, b)
+
+	utils.JSON(w, http.StatusOK, utils.APIResponse{Success: true, Message: "member removed", Data: map[string]interface{}{"room_id": roomID, "user_id": targetID}})
+}
+
+type MemberRoleRequest struct {
+	Role string `json:"role"`
+}
+
+// MemberRoleHandler lets the room owner promote or demote moderators
+type MemberRoleHandler struct {
+	DB *sql.DB
+}
+
+// ServeHTTP handles PUT /rooms/{id}/members/{userId}/role
+func (h *MemberRoleHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req MemberRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "Invalid request body"})
+		return
+	}
+	if req.Role != chat.RoleModerator && req.Role != chat.RoleMember {
+		utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "role must be moderator or member"})
+		return
+	}
+
+	targetID := pathUserID(r)
+	userID, roomID, targetRole, ok := moderationTarget(w, r, h.DB, targetID)
+	if !ok {
+		return
+	}
+	if targetRole == "" {
+		utils.JSON(w, http.StatusNotFound, utils.APIResponse{Success: false, Message: "user not a member of the room"})
+		return
+	}
+	// only the owner outranks moderators, but make that explicit: a
+	// moderator must not be able to mint other moderators
+	if role, _ := chat.GetRole(h.DB, roomID, userID); role != chat.RoleOwner {
+		utils.JSON(w, http.StatusForbidden, utils.APIResponse{Success: false, Message: "only the room owner can change roles"})
+		return
+	}
+
+	if _, err := h.DB.Exec("UPDATE room_members SET role = ? WHERE room_id = ? AND user_id = ?", req.Role, roomID, targetID); err != nil {
+		utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "failed to update role", Data: map[string]interface{}{"error": err.Error()}})
+		return
+	}
+	utils.JSON(w, http.StatusOK, utils.APIResponse{Success: true, Message: "role updated", Data: map[string]interface{}{"room_id": roomID, "user_id": targetID, "role": req.Role}})
+}
@@ -0,0 +1,69 @@
+package room
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+
+	"convo/internal/chat"
+	"convo/internal/utils"
+)
+
+type UpdateMessageStateRequest struct {
+	Status  *string `json:"status,omitempty"` // delivered or read; never moves backwards
+	Starred *bool   `json:"starred,omitempty"`
+}
+
+// MessageStateHandler reads and updates the caller's own state for a
+// message: delivery status, starred and forwarded flags
+type MessageStateHandler struct {
+	DB *sql.DB
+}
+
+// ServeHTTP handles GET and PUT /rooms/{id}/messages/{msgId}/state
+func (h *MessageStateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	userID, roomID, msgID, ok := messagePath(w, r)
+	if !ok {
+		return
+	}
+	if _, err := chat.GetRole(h.DB, roomID, userID); err != nil {
+		writeMessageError(w, err)
+		return
+	}
+
+	if r.Method == http.MethodPut {
+		var req UpdateMessageStateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "Invalid request body"})
+			return
+		}
+		if req.Status != nil && !chat.ValidStatus(*req.Status) {
+			utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: chat.ErrInvalidStatus.Error()})
+			return
+		}
+		// make sure the message is in this room before touching its state
+		if _, err := chat.GetMeta(h.DB, roomID, msgID, userID); err != nil {
+			writeMessageError(w, err)
+			return
+		}
+		if req.Status != nil {
+			if err := chat.SetStatus(h.DB, msgID, userID, *req.Status); err != nil {
+				writeMessageError(w, err)
+				return
+			}
+		}
+		if req.Starred != nil {
+			if err := chat.SetStarred(h.DB, msgID, userID, *req.Starred); err != nil {
+				writeMessageError(w, err)
+				return
+			}
+		}
+	}
+
+	meta, err := chat.GetMeta(h.DB, roomID, msgID, userID)
+	if err != nil {
+		writeMessageError(w, err)
+		return
+	}
+	utils.JSON(w, http.StatusOK, utils.APIResponse{Success: true, Message: "message state fetched", Data: meta})
+}
@@ -5,6 +5,8 @@ import (
 	"net/http"
 	"strconv"
 
+	"convo/internal/chat"
+	"convo/internal/middleware"
 	"convo/internal/utils"
 	"github.com/go-chi/chi/v5"
 )
@@ -13,56 +15,86 @@ type RoomMessagesHandler struct {
 	DB *sql.DB
 }
 
-// ServeHTTP handles GET /rooms/{id}/messages
+// ServeHTTP handles GET /rooms/{id}/messages?num=&cursor=|before_id=|after_id=|around=
+// Pages are newest first; has_more_before/has_more_after tell whether to
+// follow before_cursor/after_cursor.
 func (h *RoomMessagesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-       // userID is not used directly, but JWT middleware ensures authentication
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int64)
+	if !ok {
+		utils.JSON(w, http.StatusUnauthorized, utils.APIResponse{Success: false, Message: "Unauthorized"})
+		return
+	}
 	roomIDStr := chi.URLParam(r, "id")
 	roomID, err := strconv.ParseInt(roomIDStr, 10, 64)
 	if err != nil {
 		utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "Invalid room id"})
 		return
 	}
-	numStr := r.URL.Query().Get("num")
-	num, err := strconv.Atoi(numStr)
-	if err != nil || num <= 0 || num > 100 {
-		utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "num param required (1-100)"})
+	if isMember, err := chat.IsMember(h.DB, roomID, userID); err != nil {
+		utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "DB error checking membership", Data: map[string]interface{}{"error": err.Error()}})
+		return
+	} else if !isMember {
+		utils.JSON(w, http.StatusForbidden, utils.APIResponse{Success: false, Message: "not a member of room"})
 		return
 	}
-	lastIDStr := r.URL.Query().Get("last_id")
-	var rows *sql.Rows
-       if lastIDStr != "" {
-	       lastID, err := strconv.ParseInt(lastIDStr, 10, 64)
-	       if err != nil {
-		       utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "Invalid last_id"})
-		       return
-	       }
-	       rows, err = h.DB.Query(`SELECT id, sender_id, content, sent_at FROM messages WHERE room_id = ? AND id < ? ORDER BY id DESC LIMIT ?`, roomID, lastID, num)
-       } else {
-	       rows, err = h.DB.Query(`SELECT id, sender_id, content, sent_at FROM messages WHERE room_id = ? ORDER BY id DESC LIMIT ?`, roomID, num)
-       }
-	if err != nil {
-		utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "DB error", Data: map[string]interface{}{ "error": err.Error() }})
+	v := r.URL.Query()
+	q := chat.PageQuery{HistoryQuery: chat.HistoryQuery{RoomID: roomID, Limit: 50}}
+	if numStr := v.Get("num"); numStr != "" {
+		num, err := strconv.Atoi(numStr)
+		if err != nil || num <= 0 || num > 100 {
+			utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "num must be 1-100"})
+			return
+		}
+		q.Limit = num
+	}
+	// last_id is the original name of before_id
+	positions := 0
+	for _, p := range []struct {
+		name string
+		dst  *int64
+	}{{"last_id", &q.BeforeID}, {"before_id", &q.BeforeID}, {"after_id", &q.AfterID}, {"around", &q.AroundID}} {
+		s := v.Get(p.name)
+		if s == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || id <= 0 {
+			utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "Invalid " + p.name})
+			return
+		}
+		*p.dst = id
+		positions++
+	}
+	if cursor := v.Get("cursor"); cursor != "" {
+		dir, id, err := chat.DecodeCursor(cursor)
+		if err != nil {
+			utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: err.Error()})
+			return
+		}
+		if dir == chat.CursorAfter {
+			q.AfterID = id
+		} else {
+			q.BeforeID = id
+		}
+		positions++
+	}
+	if positions > 1 {
+		utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "use only one of cursor, before_id, after_id and around"})
 		return
 	}
-	defer rows.Close()
 
-       type Message struct {
-	       ID        int64  `json:"id"`
-	       SenderID  int64  `json:"sender_id"`
-	       Content   string `json:"content"`
-	       SentAt    string `json:"sent_at"`
-       }
-       var messages []Message
-       for rows.Next() {
-	       var m Message
-	       if err := rows.Scan(&m.ID, &m.SenderID, &m.Content, &m.SentAt); err != nil {
-		       continue
-	       }
-	       messages = append(messages, m)
-       }
-	if len(messages) == 0 {
-		utils.JSON(w, http.StatusOK, utils.APIResponse{Success: true, Message: "no history", Data: []Message{}})
+	page, err := chat.Page(h.DB, userID, q)
+	if err == chat.ErrMessageNotFound {
+		utils.JSON(w, http.StatusNotFound, utils.APIResponse{Success: false, Message: "message not found"})
+		return
+	}
+	if err != nil {
+		utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "DB error", Data: map[string]interface{}{"error": err.Error()}})
+		return
+	}
+	if len(page.Messages) == 0 {
+		utils.JSON(w, http.StatusOK, utils.APIResponse{Success: true, Message: "no history", Data: page})
 		return
 	}
-	utils.JSON(w, http.StatusOK, utils.APIResponse{Success: true, Message: "messages fetched", Data: messages})
+	utils.JSON(w, http.StatusOK, utils.APIResponse{Success: true, Message: "messages fetched", Data: page})
 }
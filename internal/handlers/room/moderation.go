@@ -0,0 +1,97 @@
+package room
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"convo/internal/chat"
+	"convo/internal/middleware"
+	"convo/internal/utils"
+)
+
+// moderationTarget parses the caller, room and target user of a moderator
+// action and checks that the caller may act on the target. It writes the
+// error response itself and returns ok=false when the request must stop.
+// targetRole is empty when the target is not a member of the room.
+func moderationTarget(w http.ResponseWriter, r *http.Request, db *sql.DB, targetID int64) (userID, roomID int64, targetRole string, ok bool) {
+	userID, ok = r.Context().Value(middleware.UserIDKey).(int64)
+	if !ok {
+		utils.JSON(w, http.StatusUnauthorized, utils.APIResponse{Success: false, Message: "Unauthorized"})
+		return 0, 0, "", false
+	}
+	roomID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "invalid room id"})
+		return 0, 0, "", false
+	}
+	if targetID <= 0 {
+		utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "user_id required"})
+		return 0, 0, "", false
+	}
+	if targetID == userID {
+		utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "cannot moderate yourself"})
+		return 0, 0, "", false
+	}
+
+	role, err := chat.GetRole(db, roomID, userID)
+	if err == chat.ErrNotMember {
+		utils.JSON(w, http.StatusForbidden, utils.APIResponse{Success: false, Message: "not a member of room"})
+		return 0, 0, "", false
+	} else if err != nil {
+		utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "DB error checking membership", Data: map[string]interface{}{"error": err.Error()}})
+		return 0, 0, "", false
+	}
+
+	targetRole, err = chat.GetRole(db, roomID, targetID)
+	if err == chat.ErrNotMember {
+		targetRole = ""
+	} else if err != nil {
+		utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "DB error checking membership", Data: map[string]interface{}{"error": err.Error()}})
+		return 0, 0, "", false
+	}
+	if !chat.Outranks(role, targetRole) {
+		utils.JSON(w, http.StatusForbidden, utils.APIResponse{Success: false, Message: "insufficient room permissions"})
+		return 0, 0, "", false
+	}
+	return userID, roomID, targetRole, true
+}
+
+// requireModerator checks that the caller moderates the room in the path.
+// It writes the error response itself and returns ok=false on failure.
+func requireModerator(w http.ResponseWriter, r *http.Request, db *sql.DB) (userID, roomID int64, ok bool) {
+	userID, ok = r.Context().Value(middleware.UserIDKey).(int64)
+	if !ok {
+		utils.JSON(w, http.StatusUnauthorized, utils.APIResponse{Success: false, Message: "Unauthorized"})
+		return 0, 0, false
+	}
+	roomID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "invalid room id"})
+		return 0, 0, false
+	}
+	role, err := chat.GetRole(db, roomID, userID)
+	if err == chat.ErrNotMember {
+		utils.JSON(w, http.StatusForbidden, utils.APIResponse{Success: false, Message: "not a member of room"})
+		return 0, 0, false
+	} else if err != nil {
+		utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "DB error checking membership", Data: map[string]interface{}{"error": err.Error()}})
+		return 0, 0, false
+	}
+	if !chat.IsModerator(role) {
+		utils.JSON(w, http.StatusForbidden, utils.APIResponse{Success: false, Message: "insufficient room permissions"})
+		return 0, 0, false
+	}
+	return userID, roomID, true
+}
+
+// pathUserID parses the {userId} URL param, returning 0 when invalid.
+func pathUserID(r *http.Request) int64 {
+	id, err := strconv.ParseInt(chi.URLParam(r, "userId"), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return id
+}
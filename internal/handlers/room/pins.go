@@ -0,0 +1,69 @@
+package room
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"convo/internal/chat"
+	"convo/internal/middleware"
+	"convo/internal/utils"
+)
+
+// PinHandler pins (POST) or unpins (DELETE) a message; moderators only
+type PinHandler struct {
+	DB *sql.DB
+}
+
+// ServeHTTP handles POST and DELETE /rooms/{id}/messages/{msgId}/pin
+func (h *PinHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	userID, roomID, msgID, ok := messagePath(w, r)
+	if !ok {
+		return
+	}
+
+	var err error
+	pinned := r.Method != http.MethodDelete
+	if pinned {
+		err = chat.PinMessage(h.DB, roomID, msgID, userID)
+	} else {
+		err = chat.UnpinMessage(h.DB, roomID, msgID, userID)
+	}
+	if err != nil {
+		writeMessageError(w, err)
+		return
+	}
+	utils.JSON(w, http.StatusOK, utils.APIResponse{Success: true, Message: "pins updated", Data: map[string]interface{}{"room_id": roomID, "message_id": msgID, "pinned": pinned}})
+}
+
+// PinsHandler lists a room's pinned messages, most recently pinned first
+type PinsHandler struct {
+	DB *sql.DB
+}
+
+// ServeHTTP handles GET /rooms/{id}/pins
+func (h *PinsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int64)
+	if !ok {
+		utils.JSON(w, http.StatusUnauthorized, utils.APIResponse{Success: false, Message: "Unauthorized"})
+		return
+	}
+	roomID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "invalid room id"})
+		return
+	}
+	if _, err := chat.GetRole(h.DB, roomID, userID); err != nil {
+		writeMessageError(w, err)
+		return
+	}
+
+	pins, err := chat.Pins(h.DB, userID, roomID)
+	if err != nil {
+		writeMessageError(w, err)
+		return
+	}
+	utils.JSON(w, http.StatusOK, utils.APIResponse{Success: true, Message: "pins fetched", Data: pins})
+}
@@ -0,0 +1,107 @@
+package room
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"convo/internal/chat"
+	"convo/internal/middleware"
+	"convo/internal/utils"
+)
+
+type CreatePollRequest struct {
+	Question    string     `json:"question"`
+	Options     []string   `json:"options"`
+	MultiChoice bool       `json:"multi_choice"`
+	Anonymous   bool       `json:"anonymous"`
+	ClosesAt    *time.Time `json:"closes_at,omitempty"`
+	ThreadID    int64      `json:"thread_id,omitempty"`
+}
+
+type VoteRequest struct {
+	// OptionIDs replaces the caller's votes; empty withdraws them
+	OptionIDs []int64 `json:"option_ids"`
+}
+
+// CreatePollHandler posts a poll to a room
+type CreatePollHandler struct {
+	DB *sql.DB
+}
+
+// ServeHTTP handles POST /rooms/{id}/polls
+func (h *CreatePollHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int64)
+	if !ok {
+		utils.JSON(w, http.StatusUnauthorized, utils.APIResponse{Success: false, Message: "Unauthorized"})
+		return
+	}
+	roomID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "invalid room id"})
+		return
+	}
+	var req CreatePollRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "invalid request"})
+		return
+	}
+
+	m, err := chat.CreatePoll(h.DB,
+		chat.SendRequest{RoomID: roomID, SenderID: userID, Content: req.Question, ThreadID: req.ThreadID},
+		chat.PollSpec{Options: req.Options, MultiChoice: req.MultiChoice, Anonymous: req.Anonymous, ClosesAt: req.ClosesAt})
+	if err != nil {
+		writeSendError(w, err)
+		return
+	}
+	utils.JSON(w, http.StatusCreated, utils.APIResponse{Success: true, Message: "Poll created", Data: m})
+}
+
+// PollVoteHandler sets the caller's votes on a poll
+type PollVoteHandler struct {
+	DB *sql.DB
+}
+
+// ServeHTTP handles POST /rooms/{id}/polls/{msgId}/votes
+func (h *PollVoteHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	userID, roomID, msgID, ok := messagePath(w, r)
+	if !ok {
+		return
+	}
+	var req VoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "invalid request"})
+		return
+	}
+
+	poll, err := chat.Vote(h.DB, roomID, msgID, userID, req.OptionIDs)
+	if err != nil {
+		writeMessageError(w, err)
+		return
+	}
+	utils.JSON(w, http.StatusOK, utils.APIResponse{Success: true, Message: "vote recorded", Data: poll})
+}
+
+// ClosePollHandler closes a poll early; its creator or moderators only
+type ClosePollHandler struct {
+	DB *sql.DB
+}
+
+// ServeHTTP handles POST /rooms/{id}/polls/{msgId}/close
+func (h *ClosePollHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	userID, roomID, msgID, ok := messagePath(w, r)
+	if !ok {
+		return
+	}
+
+	poll, err := chat.ClosePoll(h.DB, roomID, msgID, userID)
+	if err != nil {
+		writeMessageError(w, err)
+		return
+	}
+	utils.JSON(w, http.StatusOK, utils.APIResponse{Success: true, Message: "poll closed", Data: poll})
+}
@@ -0,0 +1,48 @@
+package room
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+
+	"convo/internal/chat"
+	"convo/internal/utils"
+)
+
+type ReactionRequest struct {
+	Emoji string `json:"emoji"`
+}
+
+// ReactionHandler adds (POST) or removes (DELETE) the caller's reaction
+type ReactionHandler struct {
+	DB *sql.DB
+}
+
+// ServeHTTP handles POST /rooms/{id}/messages/{msgId}/reactions with
+// {"emoji": ...} and DELETE /rooms/{id}/messages/{msgId}/reactions?emoji=...
+func (h *ReactionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	userID, roomID, msgID, ok := messagePath(w, r)
+	if !ok {
+		return
+	}
+
+	var err error
+	var emoji string
+	if r.Method == http.MethodDelete {
+		emoji = r.URL.Query().Get("emoji")
+		err = chat.RemoveReaction(h.DB, roomID, msgID, userID, emoji)
+	} else {
+		var req ReactionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "Invalid request body"})
+			return
+		}
+		emoji = req.Emoji
+		err = chat.AddReaction(h.DB, roomID, msgID, userID, emoji)
+	}
+	if err != nil {
+		writeMessageError(w, err)
+		return
+	}
+	utils.JSON(w, http.StatusOK, utils.APIResponse{Success: true, Message: "reactions updated", Data: map[string]interface{}{"room_id": roomID, "message_id": msgID, "emoji": emoji}})
+}
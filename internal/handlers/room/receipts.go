@@ -0,0 +1,78 @@
+package room
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"convo/internal/chat"
+	"convo/internal/middleware"
+	"convo/internal/utils"
+)
+
+type AckRequest struct {
+	// MessageID acknowledges this message and every earlier one in the room
+	MessageID int64  `json:"message_id"`
+	Status    string `json:"status,omitempty"` // delivered or read (default)
+}
+
+// AckHandler records delivery and read acknowledgements
+type AckHandler struct {
+	DB *sql.DB
+}
+
+// ServeHTTP handles POST /rooms/{id}/ack
+func (h *AckHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int64)
+	if !ok {
+		utils.JSON(w, http.StatusUnauthorized, utils.APIResponse{Success: false, Message: "Unauthorized"})
+		return
+	}
+	roomID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "invalid room id"})
+		return
+	}
+
+	var req AckRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "Invalid request body"})
+		return
+	}
+	if req.Status == "" {
+		req.Status = chat.StatusRead
+	}
+
+	if err := chat.Ack(h.DB, roomID, userID, req.MessageID, req.Status); err != nil {
+		writeMessageError(w, err)
+		return
+	}
+	utils.JSON(w, http.StatusOK, utils.APIResponse{Success: true, Message: "acknowledged", Data: map[string]interface{}{"room_id": roomID, "message_id": req.MessageID, "status": req.Status}})
+}
+
+// ReceiptsHandler lists per-member delivery and read state of a message
+type ReceiptsHandler struct {
+	DB *sql.DB
+}
+
+// ServeHTTP handles GET /rooms/{id}/messages/{msgId}/receipts
+func (h *ReceiptsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	userID, roomID, msgID, ok := messagePath(w, r)
+	if !ok {
+		return
+	}
+	if _, err := chat.GetRole(h.DB, roomID, userID); err != nil {
+		writeMessageError(w, err)
+		return
+	}
+
+	receipts, err := chat.Receipts(h.DB, roomID, msgID)
+	if err != nil {
+		writeMessageError(w, err)
+		return
+	}
+	utils.JSON(w, http.StatusOK, utils.APIResponse{Success: true, Message: "receipts fetched", Data: receipts})
+}
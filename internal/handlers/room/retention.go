@@ -0,0 +1,58 @@
+package room
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+
+	"convo/internal/chat"
+	"convo/internal/utils"
+	"convo/internal/ws"
+)
+
+type RetentionRequest struct {
+	// RetentionSeconds purges messages older than this; 0 keeps them.
+	RetentionSeconds int `json:"retention_seconds"`
+	// DisappearingSeconds is the default ttl_seconds of new messages; 0
+	// turns disappearing messages off.
+	DisappearingSeconds int `json:"disappearing_seconds"`
+}
+
+// RetentionHandler lets moderators configure how long a room keeps messages
+type RetentionHandler struct {
+	DB *sql.DB
+}
+
+// ServeHTTP handles PUT /rooms/{id}/retention
+func (h *RetentionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, roomID, ok := requireModerator(w, r, h.DB)
+	if !ok {
+		return
+	}
+
+	var req RetentionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "Invalid request body"})
+		return
+	}
+	if !chat.ValidRetention(req.RetentionSeconds) {
+		utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "retention_seconds must be 0 or between one hour and ten years"})
+		return
+	}
+	if !chat.ValidTTL(req.DisappearingSeconds) {
+		utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "disappearing_seconds must be 0 or between 5 seconds and 30 days"})
+		return
+	}
+
+	if _, err := h.DB.Exec("UPDATE rooms SET retention_seconds = ?, disappearing_seconds = ? WHERE id = ?",
+		req.RetentionSeconds, req.DisappearingSeconds, roomID); err != nil {
+		utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "failed to update retention", Data: map[string]interface{}{"error": err.Error()}})
+		return
+	}
+
+	data := map[string]interface{}{"room_id": roomID, "retention_seconds": req.RetentionSeconds, "disappearing_seconds": req.DisappearingSeconds}
+	b, _ := json.Marshal(map[string]interface{}{"type": "retention", "room_id": roomID, "retention_seconds": req.RetentionSeconds, "disappearing_seconds": req.DisappearingSeconds})
+	ws.GetRoomHub(roomID).Broadcast <- b
+
+	utils.JSON(w, http.StatusOK, utils.APIResponse{Success: true, Message: "retention updated", Data: data})
+}
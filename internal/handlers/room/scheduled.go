@@ -0,0 +1,137 @@
+package room
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"convo/internal/chat"
+	"convo/internal/middleware"
+	"convo/internal/utils"
+)
+
+type ScheduleMessageRequest struct {
+	Content  string    `json:"content"`
+	Format   string    `json:"format,omitempty"` // plain (default) or markdown
+	SendAt   time.Time `json:"send_at"`          // RFC 3339
+	ThreadID int64     `json:"thread_id,omitempty"`
+	ReplyTo  int64     `json:"reply_to,omitempty"`
+}
+
+type UpdateScheduledRequest struct {
+	Content *string    `json:"content,omitempty"`
+	SendAt  *time.Time `json:"send_at,omitempty"`
+}
+
+// ScheduledMessagesHandler lists (GET) the caller's pending scheduled
+// messages in a room or schedules a new one (POST)
+type ScheduledMessagesHandler struct {
+	DB *sql.DB
+}
+
+// ServeHTTP handles GET and POST /rooms/{id}/scheduled-messages
+func (h *ScheduledMessagesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int64)
+	if !ok {
+		utils.JSON(w, http.StatusUnauthorized, utils.APIResponse{Success: false, Message: "Unauthorized"})
+		return
+	}
+	roomID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "invalid room id"})
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		if _, err := chat.GetRole(h.DB, roomID, userID); err != nil {
+			writeScheduledError(w, err)
+			return
+		}
+		list, err := chat.ListScheduled(h.DB, roomID, userID)
+		if err != nil {
+			writeScheduledError(w, err)
+			return
+		}
+		utils.JSON(w, http.StatusOK, utils.APIResponse{Success: true, Message: "scheduled messages fetched", Data: list})
+		return
+	}
+
+	var req ScheduleMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "invalid request"})
+		return
+	}
+	s, err := chat.ScheduleMessage(h.DB, chat.SendRequest{RoomID: roomID, SenderID: userID, Content: req.Content, Format: req.Format, ThreadID: req.ThreadID, ReplyToID: req.ReplyTo}, req.SendAt)
+	if err != nil {
+		writeScheduledError(w, err)
+		return
+	}
+	utils.JSON(w, http.StatusCreated, utils.APIResponse{Success: true, Message: "Message scheduled", Data: s})
+}
+
+// ScheduledMessageHandler edits (PATCH) or cancels (DELETE) one of the
+// caller's pending scheduled messages
+type ScheduledMessageHandler struct {
+	DB *sql.DB
+}
+
+// ServeHTTP handles PATCH and DELETE /rooms/{id}/scheduled-messages/{schedId}
+func (h *ScheduledMessageHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int64)
+	if !ok {
+		utils.JSON(w, http.StatusUnauthorized, utils.APIResponse{Success: false, Message: "Unauthorized"})
+		return
+	}
+	roomID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "invalid room id"})
+		return
+	}
+	id, err := strconv.ParseInt(chi.URLParam(r, "schedId"), 10, 64)
+	if err != nil {
+		utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "invalid scheduled message id"})
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		if err := chat.CancelScheduled(h.DB, roomID, id, userID); err != nil {
+			writeScheduledError(w, err)
+			return
+		}
+		utils.JSON(w, http.StatusOK, utils.APIResponse{Success: true, Message: "Scheduled message cancelled", Data: map[string]interface{}{"id": id, "room_id": roomID}})
+		return
+	}
+
+	var req UpdateScheduledRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "invalid request"})
+		return
+	}
+	s, err := chat.UpdateScheduled(h.DB, roomID, id, userID, req.Content, req.SendAt)
+	if err != nil {
+		writeScheduledError(w, err)
+		return
+	}
+	utils.JSON(w, http.StatusOK, utils.APIResponse{Success: true, Message: "Scheduled message updated", Data: s})
+}
+
+// writeScheduledError maps errors from the chat scheduling operations to
+// API responses.
+func writeScheduledError(w http.ResponseWriter, err error) {
+	switch err {
+	case chat.ErrNotMember:
+		utils.JSON(w, http.StatusForbidden, utils.APIResponse{Success: false, Message: "not a member of room"})
+	case chat.ErrScheduledNotFound:
+		utils.JSON(w, http.StatusNotFound, utils.APIResponse{Success: false, Message: err.Error()})
+	case chat.ErrNotPending:
+		utils.JSON(w, http.StatusConflict, utils.APIResponse{Success: false, Message: err.Error()})
+	case chat.ErrEmptyContent, chat.ErrInvalidFormat, chat.ErrContentTooLong, chat.ErrInvalidSendTime:
+		utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: err.Error()})
+	default:
+		utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "DB error", Data: map[string]interface{}{"error": err.Error()}})
+	}
+}
@@ -3,17 +3,26 @@ package room
 import (
     "database/sql"
     "encoding/json"
+    "errors"
     "net/http"
     "time"
     "strconv"
 
     "github.com/go-chi/chi/v5"
+    "convo/internal/chat"
     "convo/internal/middleware"
+    "convo/internal/models"
     "convo/internal/utils"
 )
 
 type SendMessageRequest struct {
-    Content string `json:"content"`
+    Content  string `json:"content"`
+    Format   string `json:"format,omitempty"`    // plain (default) or markdown
+    ThreadID int64  `json:"thread_id,omitempty"` // reply in this message's thread
+    ReplyTo  int64  `json:"reply_to,omitempty"`  // quote this message inline
+    TTLSeconds int  `json:"ttl_seconds,omitempty"` // disappear this long after being read
+    ClientMsgID string `json:"client_msg_id,omitempty"` // retries with the same id return the original message
+    AttachmentIDs []int64 `json:"attachment_ids,omitempty"` // uploads from POST /rooms/{id}/attachments
 }
 
 type SendMessageResponse struct {
@@ -21,6 +30,13 @@ type SendMessageResponse struct {
     RoomID   int64     `json:"room_id"`
     SenderID int64     `json:"sender_id"`
     Content  string    `json:"content"`
+    Format   string    `json:"format"`
+    Rich     json.RawMessage `json:"rich,omitempty"`
+    ThreadID *int64    `json:"thread_id,omitempty"`
+    ReplyTo  *models.ReplyRef   `json:"reply_to,omitempty"`
+    Forward  *models.ForwardRef `json:"forwarded_from,omitempty"`
+    ClientMsgID string          `json:"client_msg_id,omitempty"`
+    Attachments []models.Attachment `json:"attachments,omitempty"`
     SentAt   time.Time `json:"sent_at"`
 }
 
@@ -42,52 +58,56 @@ func (h *SendMessageHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
         return
     }
 
-    // check membership
-    var found int
-    if err := h.DB.QueryRow("SELECT 1 FROM room_members WHERE room_id=? AND user_id=?", roomID, userID).Scan(&found); err != nil {
-        if err == sql.ErrNoRows {
-            utils.JSON(w, http.StatusForbidden, utils.APIResponse{Success: false, Message: "not a member of room"})
-            return
-        }
-        utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "DB error checking membership", Data: map[string]interface{}{"error": err.Error()}})
-        return
-    }
-
     var req SendMessageRequest
     if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
         utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "invalid request"})
         return
     }
-    if req.Content == "" {
-        utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "content required"})
-        return
-    }
 
-    result, err := h.DB.Exec("INSERT INTO messages (room_id, sender_id, content) VALUES (?, ?, ?)", roomID, userID, req.Content)
+    // chat.SendMessage checks membership, moderation and rate limits,
+    // stores the message and its message_meta rows and broadcasts it
+    m, err := chat.SendMessage(h.DB, chat.SendRequest{RoomID: roomID, SenderID: userID, Content: req.Content, Format: req.Format, ThreadID: req.ThreadID, ReplyToID: req.ReplyTo, TTLSeconds: req.TTLSeconds, ClientMsgID: req.ClientMsgID, AttachmentIDs: req.AttachmentIDs})
     if err != nil {
-        utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "failed to insert message", Data: map[string]interface{}{"error": err.Error()}})
+        writeSendError(w, err)
         return
     }
-    id, _ := result.LastInsertId()
-
-    var sentAt time.Time
-    _ = h.DB.QueryRow("SELECT sent_at FROM messages WHERE id = ?", id).Scan(&sentAt)
-
-    // populate message_meta for all current room members (including sender)
-    // insert ignoring duplicates
-    _, _ = h.DB.Exec(`
-        INSERT INTO message_meta (message_id, user_id, status, forwarded, starred, reactions, extra)
-        SELECT ?, rm.user_id, 'sent', FALSE, FALSE, NULL, NULL FROM room_members rm WHERE rm.room_id = ?
-        ON DUPLICATE KEY UPDATE message_id = message_id
-    `, id, roomID)
 
     resp := SendMessageResponse{
-        ID: id,
-        RoomID: roomID,
-        SenderID: userID,
-        Content: req.Content,
-        SentAt: sentAt,
+        ID: m.ID,
+        RoomID: m.RoomID,
+        SenderID: m.SenderID,
+        Content: m.Content,
+        Format: m.Format,
+        Rich: m.Rich,
+        ThreadID: m.ThreadID,
+        ReplyTo: m.ReplyTo,
+        Forward: m.ForwardedFrom,
+        ClientMsgID: m.ClientMsgID,
+        Attachments: m.Attachments,
+        SentAt: m.SentAt,
     }
 
     utils.JSON(w, http.StatusCreated, utils.APIResponse{Success: true, Message: "Message sent", Data: resp})
 }
+
+// writeSendError maps an error from chat.SendMessage to an API response,
+// telling the client when it may try again if it has to wait.
+func writeSendError(w http.ResponseWriter, err error) {
+    var wait *chat.WaitError
+    switch {
+    case err == chat.ErrNotMember:
+        utils.JSON(w, http.StatusForbidden, utils.APIResponse{Success: false, Message: "not a member of room"})
+    case err == chat.ErrEmptyContent:
+        utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "content required"})
+    case err == chat.ErrInvalidTTL, err == chat.ErrInvalidPoll, err == chat.ErrInvalidClientMsgID, err == chat.ErrInvalidFormat, err == chat.ErrContentTooLong, err == chat.ErrInvalidAttachment:
+        utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: err.Error()})
+    case err == chat.ErrMessageNotFound, err == chat.ErrMessageDeleted:
+        utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "referenced " + err.Error()})
+    case errors.As(err, &wait):
+        secs := int64(wait.RetryAfter() / time.Second)
+        w.Header().Set("Retry-After", strconv.FormatInt(secs, 10))
+        utils.JSON(w, http.StatusTooManyRequests, utils.APIResponse{Success: false, Message: wait.Reason, Data: map[string]interface{}{"retry_after": secs, "retry_at": wait.Until}})
+    default:
+        utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "failed to send message", Data: map[string]interface{}{"error": err.Error()}})
+    }
+}
@@ -0,0 +1,96 @@
+package room
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"convo/internal/chat"
+	"convo/internal/middleware"
+	"convo/internal/utils"
+)
+
+// RoomSettingsHandler reads and updates the caller's per-room settings
+type RoomSettingsHandler struct {
+	DB *sql.DB
+}
+
+type UpdateRoomSettingsRequest struct {
+	// MuteSeconds mutes the room for that many seconds; 0 unmutes and -1
+	// mutes until further notice. Omit to leave muting unchanged.
+	MuteSeconds *int64  `json:"mute_seconds,omitempty"`
+	NotifyLevel *string `json:"notify_level,omitempty"`
+	Pinned      *bool   `json:"pinned,omitempty"`
+}
+
+// ServeHTTP handles GET and PUT /rooms/{id}/settings
+func (h *RoomSettingsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int64)
+	if !ok {
+		utils.JSON(w, http.StatusUnauthorized, utils.APIResponse{Success: false, Message: "Unauthorized"})
+		return
+	}
+	roomID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "invalid room id"})
+		return
+	}
+
+	member, err := chat.GetMember(h.DB, roomID, userID)
+	if err == sql.ErrNoRows {
+		utils.JSON(w, http.StatusForbidden, utils.APIResponse{Success: false, Message: "not a member of room"})
+		return
+	} else if err != nil {
+		utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "DB error checking membership", Data: map[string]interface{}{"error": err.Error()}})
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		utils.JSON(w, http.StatusOK, utils.APIResponse{Success: true, Message: "settings fetched", Data: member})
+		return
+	}
+
+	var req UpdateRoomSettingsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "Invalid request body"})
+		return
+	}
+
+	if req.MuteSeconds != nil {
+		switch s := *req.MuteSeconds; {
+		case s == 0:
+			member.MutedUntil = nil
+		case s == -1:
+			until := chat.MutedForever
+			member.MutedUntil = &until
+		case s > 0:
+			until := time.Now().Add(time.Duration(s) * time.Second)
+			member.MutedUntil = &until
+		default:
+			utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "mute_seconds must be -1, 0 or positive"})
+			return
+		}
+	}
+	if req.NotifyLevel != nil {
+		if !chat.ValidNotifyLevel(*req.NotifyLevel) {
+			utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "notify_level must be all, mentions or none"})
+			return
+		}
+		member.NotifyLevel = *req.NotifyLevel
+	}
+	if req.Pinned != nil {
+		member.Pinned = *req.Pinned
+	}
+
+	if _, err := h.DB.Exec(`UPDATE room_members SET muted_until = ?, notify_level = ?, pinned = ?
+		WHERE room_id = ? AND user_id = ?`, member.MutedUntil, member.NotifyLevel, member.Pinned, roomID, userID); err != nil {
+		utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "failed to update settings", Data: map[string]interface{}{"error": err.Error()}})
+		return
+	}
+
+	utils.JSON(w, http.StatusOK, utils.APIResponse{Success: true, Message: "settings updated", Data: member})
+}
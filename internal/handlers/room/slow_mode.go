@@ -0,0 +1,52 @@
+package room
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+
+	"convo/internal/utils"
+	"convo/internal/ws"
+)
+
+// maxSlowModeSeconds caps slow mode at six hours.
+const maxSlowModeSeconds = 6 * 60 * 60
+
+type SlowModeRequest struct {
+	// Seconds is the minimum gap between two messages of the same member;
+	// 0 turns slow mode off.
+	Seconds int `json:"seconds"`
+}
+
+// SlowModeHandler lets moderators configure slow mode for a room
+type SlowModeHandler struct {
+	DB *sql.DB
+}
+
+// ServeHTTP handles PUT /rooms/{id}/slow-mode
+func (h *SlowModeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, roomID, ok := requireModerator(w, r, h.DB)
+	if !ok {
+		return
+	}
+
+	var req SlowModeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "Invalid request body"})
+		return
+	}
+	if req.Seconds < 0 || req.Seconds > maxSlowModeSeconds {
+		utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "seconds must be between 0 and 21600"})
+		return
+	}
+
+	if _, err := h.DB.Exec("UPDATE rooms SET slow_mode_seconds = ? WHERE id = ?", req.Seconds, roomID); err != nil {
+		utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "failed to update slow mode", Data: map[string]interface{}{"error": err.Error()}})
+		return
+	}
+
+	b, _ := json.Marshal(map[string]interface{}{"type": "slow_mode", "room_id": roomID, "seconds": req.Seconds})
+	ws.GetRoomHub(roomID).Broadcast <- b
+
+	utils.JSON(w, http.StatusOK, utils.APIResponse{Success: true, Message: "slow mode updated", Data: map[string]interface{}{"room_id": roomID, "slow_mode_seconds": req.Seconds}})
+}
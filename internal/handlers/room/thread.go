@@ -0,0 +1,94 @@
+package room
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"convo/internal/chat"
+	"convo/internal/models"
+	"convo/internal/utils"
+)
+
+type ThreadResponse struct {
+	Root      *models.Message  `json:"root"`
+	Replies   []models.Message `json:"replies"`
+	Following bool             `json:"following"`
+}
+
+// ThreadHandler pages through the replies of a thread, newest first
+type ThreadHandler struct {
+	DB *sql.DB
+}
+
+// ServeHTTP handles GET /rooms/{id}/messages/{msgId}/thread?num=&last_id=
+func (h *ThreadHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	userID, roomID, msgID, ok := messagePath(w, r)
+	if !ok {
+		return
+	}
+	if _, err := chat.GetRole(h.DB, roomID, userID); err != nil {
+		writeMessageError(w, err)
+		return
+	}
+
+	num := 50
+	if v := r.URL.Query().Get("num"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 100 {
+			utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "num must be 1-100"})
+			return
+		}
+		num = n
+	}
+	q := chat.HistoryQuery{RoomID: roomID, ThreadID: msgID, Limit: num}
+	if v := r.URL.Query().Get("last_id"); v != "" {
+		lastID, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "Invalid last_id"})
+			return
+		}
+		q.BeforeID = lastID
+	}
+
+	root, err := chat.GetMessage(h.DB, userID, roomID, msgID)
+	if err == nil && root.ThreadID != nil {
+		// a reply is not a thread of its own
+		err = chat.ErrMessageNotFound
+	}
+	if err != nil {
+		writeMessageError(w, err)
+		return
+	}
+	replies, err := chat.History(h.DB, userID, q)
+	if err != nil {
+		writeMessageError(w, err)
+		return
+	}
+	following, err := chat.IsFollowing(h.DB, msgID, userID)
+	if err != nil {
+		writeMessageError(w, err)
+		return
+	}
+
+	utils.JSON(w, http.StatusOK, utils.APIResponse{Success: true, Message: "thread fetched", Data: ThreadResponse{Root: root, Replies: replies, Following: following}})
+}
+
+// FollowThreadHandler follows (POST) or unfollows (DELETE) a thread
+type FollowThreadHandler struct {
+	DB *sql.DB
+}
+
+// ServeHTTP handles POST and DELETE /rooms/{id}/messages/{msgId}/follow
+func (h *FollowThreadHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	userID, roomID, msgID, ok := messagePath(w, r)
+	if !ok {
+		return
+	}
+	follow := r.Method != http.MethodDelete
+	if err := chat.Follow(h.DB, roomID, msgID, userID, follow); err != nil {
+		writeMessageError(w, err)
+		return
+	}
+	utils.JSON(w, http.StatusOK, utils.APIResponse{Success: true, Message: "thread follow updated", Data: map[string]interface{}{"room_id": roomID, "message_id": msgID, "following": follow}})
+}
@@ -0,0 +1,94 @@
+package room
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"convo/internal/models"
+	"convo/internal/utils"
+	"convo/internal/ws"
+)
+
+type TimeoutRequest struct {
+	UserID          int64  `json:"user_id"`
+	Reason          string `json:"reason,omitempty"`
+	DurationSeconds int64  `json:"duration_seconds"`
+}
+
+// TimeoutHandler temporarily prevents a member from sending messages
+type TimeoutHandler struct {
+	DB *sql.DB
+}
+
+// ServeHTTP handles POST /rooms/{id}/timeouts
+func (h *TimeoutHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req TimeoutRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "Invalid request body"})
+		return
+	}
+	if req.DurationSeconds <= 0 {
+		utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "duration_seconds must be positive"})
+		return
+	}
+	userID, roomID, targetRole, ok := moderationTarget(w, r, h.DB, req.UserID)
+	if !ok {
+		return
+	}
+	if targetRole == "" {
+		utils.JSON(w, http.StatusNotFound, utils.APIResponse{Success: false, Message: "user not a member of the room"})
+		return
+	}
+
+	now := time.Now()
+	t := models.RoomTimeout{
+		RoomID:    roomID,
+		UserID:    req.UserID,
+		Reason:    req.Reason,
+		IssuedBy:  userID,
+		ExpiresAt: now.Add(time.Duration(req.DurationSeconds) * time.Second),
+		CreatedAt: now,
+	}
+	if _, err := h.DB.Exec(`INSERT INTO room_timeouts (room_id, user_id, reason, issued_by, expires_at) VALUES (?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE reason = VALUES(reason), issued_by = VALUES(issued_by), expires_at = VALUES(expires_at), created_at = CURRENT_TIMESTAMP`,
+		roomID, req.UserID, req.Reason, userID, t.ExpiresAt); err != nil {
+		utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "failed to time out user", Data: map[string]interface{}{"error": err.Error()}})
+		return
+	}
+
+	b, _ := json.Marshal(map[string]interface{}{"type": "timed_out", "room_id": roomID, "reason": req.Reason, "expires_at": t.ExpiresAt})
+	ws.SendToUser(req.UserID, 0, b)
+
+	utils.JSON(w, http.StatusCreated, utils.APIResponse{Success: true, Message: "user timed out", Data: t})
+}
+
+// ClearTimeoutHandler ends a member's timeout early
+type ClearTimeoutHandler struct {
+	DB *sql.DB
+}
+
+// ServeHTTP handles DELETE /rooms/{id}/timeouts/{userId}
+func (h *ClearTimeoutHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, roomID, ok := requireModerator(w, r, h.DB)
+	if !ok {
+		return
+	}
+	targetID := pathUserID(r)
+	if targetID == 0 {
+		utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "invalid user id"})
+		return
+	}
+
+	res, err := h.DB.Exec("DELETE FROM room_timeouts WHERE room_id = ? AND user_id = ? AND expires_at > NOW()", roomID, targetID)
+	if err != nil {
+		utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "failed to clear timeout", Data: map[string]interface{}{"error": err.Error()}})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		utils.JSON(w, http.StatusNotFound, utils.APIResponse{Success: false, Message: "no active timeout"})
+		return
+	}
+	utils.JSON(w, http.StatusOK, utils.APIResponse{Success: true, Message: "timeout cleared", Data: map[string]interface{}{"room_id": roomID, "user_id": targetID}})
+}
@@ -0,0 +1,216 @@
+package room
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"convo/internal/chat"
+	"convo/internal/middleware"
+	"convo/internal/models"
+	"convo/internal/utils"
+)
+
+// Resumable uploads speak tus 1.0.0 (https://tus.io/protocols/resumable-upload)
+// with the creation, expiration, checksum and termination extensions.
+const tusVersion = "1.0.0"
+
+// statusChecksumMismatch is the status tus uses for a failed chunk checksum.
+const statusChecksumMismatch = 460
+
+// UploadsHandler starts resumable uploads (POST) and advertises the
+// protocol (OPTIONS)
+type UploadsHandler struct {
+	DB *sql.DB
+}
+
+// ServeHTTP handles POST and OPTIONS /rooms/{id}/uploads. POST takes the
+// file size in Upload-Length and may name the file in Upload-Metadata
+// ("filename <base64>"); the upload's URL comes back in Location.
+func (h *UploadsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Tus-Resumable", tusVersion)
+	if r.Method == http.MethodOptions {
+		algorithms := make([]string, 0, len(chat.ChecksumAlgorithms))
+		for name := range chat.ChecksumAlgorithms {
+			algorithms = append(algorithms, name)
+		}
+		sort.Strings(algorithms)
+		w.Header().Set("Tus-Version", tusVersion)
+		w.Header().Set("Tus-Extension", "creation,expiration,checksum,termination")
+		w.Header().Set("Tus-Max-Size", strconv.FormatInt(chat.MaxAttachmentSize, 10))
+		w.Header().Set("Tus-Checksum-Algorithm", strings.Join(algorithms, ","))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	userID, roomID, ok := uploadPath(w, r)
+	if !ok {
+		return
+	}
+
+	length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil {
+		utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "Upload-Length required"})
+		return
+	}
+	filename := uploadMetadata(r.Header.Get("Upload-Metadata"))["filename"]
+
+	u, err := chat.CreateUpload(h.DB, roomID, userID, filename, length)
+	if err != nil {
+		writeUploadError(w, err)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/rooms/%d/uploads/%s", roomID, u.ID))
+	setUploadHeaders(w, u)
+	utils.JSON(w, http.StatusCreated, utils.APIResponse{Success: true, Message: "upload created", Data: u})
+}
+
+// UploadHandler reports on (HEAD, GET), continues (PATCH) or abandons
+// (DELETE) a resumable upload
+type UploadHandler struct {
+	DB *sql.DB
+}
+
+// ServeHTTP handles HEAD, GET, PATCH and DELETE /rooms/{id}/uploads/{uploadId}.
+// PATCH sends the next chunk as application/offset+octet-stream starting at
+// Upload-Offset, optionally with Upload-Checksum ("<algorithm> <base64>").
+// Once the last chunk is in, the attachment id to send is returned in
+// Upload-Attachment-Id and in GET's response.
+func (h *UploadHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Tus-Resumable", tusVersion)
+	userID, roomID, ok := uploadPath(w, r)
+	if !ok {
+		return
+	}
+	id := chi.URLParam(r, "uploadId")
+
+	switch r.Method {
+	case http.MethodHead, http.MethodGet:
+		u, err := chat.GetUpload(h.DB, roomID, id, userID)
+		if err != nil {
+			writeUploadError(w, err)
+			return
+		}
+		setUploadHeaders(w, u)
+		w.Header().Set("Cache-Control", "no-store")
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		utils.JSON(w, http.StatusOK, utils.APIResponse{Success: true, Message: "upload fetched", Data: u})
+	case http.MethodDelete:
+		if err := chat.DeleteUpload(h.DB, roomID, id, userID); err != nil {
+			writeUploadError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		if r.Header.Get("Content-Type") != "application/offset+octet-stream" {
+			utils.JSON(w, http.StatusUnsupportedMediaType, utils.APIResponse{Success: false, Message: "Content-Type must be application/offset+octet-stream"})
+			return
+		}
+		offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+		if err != nil {
+			utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "Upload-Offset required"})
+			return
+		}
+		// chunks go to the blob store as is, which needs their size
+		if r.ContentLength < 0 {
+			utils.JSON(w, http.StatusLengthRequired, utils.APIResponse{Success: false, Message: "Content-Length required"})
+			return
+		}
+		var sum *chat.Checksum
+		if v := r.Header.Get("Upload-Checksum"); v != "" {
+			alg, enc, _ := strings.Cut(v, " ")
+			digest, err := base64.StdEncoding.DecodeString(enc)
+			if err != nil {
+				utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "invalid Upload-Checksum"})
+				return
+			}
+			sum = &chat.Checksum{Algorithm: alg, Sum: digest}
+		}
+
+		u, err := chat.WriteChunk(h.DB, roomID, id, userID, offset, r.Body, r.ContentLength, sum)
+		if err != nil {
+			writeUploadError(w, err)
+			return
+		}
+		setUploadHeaders(w, u)
+		if u.Attachment != nil {
+			w.Header().Set("Upload-Attachment-Id", strconv.FormatInt(u.Attachment.ID, 10))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// uploadPath authenticates the caller, parses the room id and rejects
+// clients speaking another tus version.
+func uploadPath(w http.ResponseWriter, r *http.Request) (userID, roomID int64, ok bool) {
+	userID, ok = r.Context().Value(middleware.UserIDKey).(int64)
+	if !ok {
+		utils.JSON(w, http.StatusUnauthorized, utils.APIResponse{Success: false, Message: "Unauthorized"})
+		return 0, 0, false
+	}
+	if v := r.Header.Get("Tus-Resumable"); v != "" && v != tusVersion {
+		w.Header().Set("Tus-Version", tusVersion)
+		utils.JSON(w, http.StatusPreconditionFailed, utils.APIResponse{Success: false, Message: "unsupported tus version"})
+		return 0, 0, false
+	}
+	roomID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "invalid room id"})
+		return 0, 0, false
+	}
+	return userID, roomID, true
+}
+
+// uploadMetadata decodes an Upload-Metadata header: comma-separated keys,
+// each followed by a space and its base64 value. Malformed pairs are
+// skipped.
+func uploadMetadata(header string) map[string]string {
+	meta := make(map[string]string)
+	for _, pair := range strings.Split(header, ",") {
+		key, enc, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			continue
+		}
+		value, err := base64.StdEncoding.DecodeString(enc)
+		if err != nil {
+			continue
+		}
+		meta[key] = string(value)
+	}
+	return meta
+}
+
+func setUploadHeaders(w http.ResponseWriter, u *models.Upload) {
+	w.Header().Set("Upload-Offset", strconv.FormatInt(u.Offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(u.Length, 10))
+	if u.Attachment == nil {
+		w.Header().Set("Upload-Expires", u.ExpiresAt.UTC().Format(http.TimeFormat))
+	}
+}
+
+// writeUploadError maps an error from the chat upload functions to an API
+// response with the status codes tus clients expect.
+func writeUploadError(w http.ResponseWriter, err error) {
+	switch err {
+	case chat.ErrUploadNotFound:
+		utils.JSON(w, http.StatusNotFound, utils.APIResponse{Success: false, Message: err.Error()})
+	case chat.ErrUploadOffset:
+		utils.JSON(w, http.StatusConflict, utils.APIResponse{Success: false, Message: err.Error()})
+	case chat.ErrChunkTooLarge:
+		utils.JSON(w, http.StatusRequestEntityTooLarge, utils.APIResponse{Success: false, Message: err.Error()})
+	case chat.ErrChecksumMismatch:
+		utils.JSON(w, statusChecksumMismatch, utils.APIResponse{Success: false, Message: err.Error()})
+	case chat.ErrUnsupportedChecksum:
+		utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: err.Error()})
+	default:
+		writeAttachmentError(w, err)
+	}
+}
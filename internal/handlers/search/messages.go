@@ -0,0 +1,119 @@
+package search
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"convo/internal/chat"
+	"convo/internal/middleware"
+	"convo/internal/search"
+	"convo/internal/utils"
+)
+
+// MessagesHandler searches the messages of the rooms the caller belongs to
+type MessagesHandler struct {
+	DB *sql.DB
+}
+
+// ServeHTTP handles GET /search/messages?q=&room_id=&sender_id=&from=&to=
+// &has_attachment=&sort=relevance|recent&num=&offset=
+// from and to are RFC 3339 times or YYYY-MM-DD dates; to is exclusive.
+func (h *MessagesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int64)
+	if !ok {
+		utils.JSON(w, http.StatusUnauthorized, utils.APIResponse{Success: false, Message: "Unauthorized"})
+		return
+	}
+
+	v := r.URL.Query()
+	q := search.Query{Text: v.Get("q"), Sort: search.SortRelevance, Limit: 20}
+	if len(search.Terms(q.Text)) == 0 {
+		utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "q required"})
+		return
+	}
+	switch s := v.Get("sort"); s {
+	case "":
+	case search.SortRelevance, search.SortRecent:
+		q.Sort = s
+	default:
+		utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "sort must be relevance or recent"})
+		return
+	}
+	if s := v.Get("num"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 || n > 100 {
+			utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "num must be 1-100"})
+			return
+		}
+		q.Limit = n
+	}
+	if s := v.Get("offset"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "invalid offset"})
+			return
+		}
+		q.Offset = n
+	}
+	var roomID int64
+	if s := v.Get("room_id"); s != "" {
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "invalid room_id"})
+			return
+		}
+		roomID = id
+	}
+	if s := v.Get("sender_id"); s != "" {
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "invalid sender_id"})
+			return
+		}
+		q.SenderID = id
+	}
+	for _, f := range []struct {
+		name string
+		dst  *time.Time
+	}{{"from", &q.From}, {"to", &q.To}} {
+		s := v.Get(f.name)
+		if s == "" {
+			continue
+		}
+		t, err := parseTime(s)
+		if err != nil {
+			utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "invalid " + f.name})
+			return
+		}
+		*f.dst = t
+	}
+	if s := v.Get("has_attachment"); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "invalid has_attachment"})
+			return
+		}
+		q.HasAttachment = &b
+	}
+
+	hits, err := chat.Search(h.DB, userID, roomID, q)
+	if err != nil {
+		if err == chat.ErrNotMember {
+			utils.JSON(w, http.StatusForbidden, utils.APIResponse{Success: false, Message: "not a member of room"})
+			return
+		}
+		utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "search failed", Data: map[string]interface{}{"error": err.Error()}})
+		return
+	}
+	utils.JSON(w, http.StatusOK, utils.APIResponse{Success: true, Message: "search results", Data: hits})
+}
+
+// parseTime accepts an RFC 3339 time or a plain date.
+func parseTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", s)
+}
@@ -0,0 +1,88 @@
+package user
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"convo/internal/middleware"
+	"convo/internal/models"
+	"convo/internal/utils"
+)
+
+// MentionsHandler lists the messages mentioning the caller, newest first,
+// in rooms they still belong to
+type MentionsHandler struct {
+	DB *sql.DB
+}
+
+// ServeHTTP handles GET /user/mentions?num=&last_id=&room_id=&unread=true
+func (h *MentionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int64)
+	if !ok {
+		utils.JSON(w, http.StatusUnauthorized, utils.APIResponse{Success: false, Message: "Unauthorized"})
+		return
+	}
+
+	q := r.URL.Query()
+	num := 50
+	if v := q.Get("num"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 100 {
+			utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "num must be 1-100"})
+			return
+		}
+		num = n
+	}
+	var lastID, roomID int64
+	if v := q.Get("last_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "Invalid last_id"})
+			return
+		}
+		lastID = id
+	}
+	if v := q.Get("room_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "Invalid room_id"})
+			return
+		}
+		roomID = id
+	}
+	unreadOnly, _ := strconv.ParseBool(q.Get("unread"))
+
+	rows, err := h.DB.Query(`SELECT m.id, m.room_id, m.sender_id, m.content, m.format, m.rich, m.kind, m.sent_at, m.thread_id, mm.kind,
+		mm.message_id > rm.last_read_message_id
+		FROM message_mentions mm
+		JOIN messages m ON m.id = mm.message_id
+		JOIN room_members rm ON rm.room_id = mm.room_id AND rm.user_id = mm.user_id
+		WHERE mm.user_id = ? AND (? = 0 OR mm.room_id = ?) AND (? = 0 OR mm.message_id < ?)
+			AND (? = FALSE OR mm.message_id > rm.last_read_message_id)
+			AND NOT EXISTS (SELECT 1 FROM message_hidden h WHERE h.message_id = m.id AND h.user_id = mm.user_id)
+		ORDER BY mm.message_id DESC LIMIT ?`, userID, roomID, roomID, lastID, lastID, unreadOnly, num)
+	if err != nil {
+		utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "DB error", Data: map[string]interface{}{"error": err.Error()}})
+		return
+	}
+	defer rows.Close()
+
+	mentions := []models.Mention{}
+	for rows.Next() {
+		var m models.Mention
+		var threadID sql.NullInt64
+		var rich []byte
+		if err := rows.Scan(&m.ID, &m.RoomID, &m.SenderID, &m.Content, &m.Format, &rich, &m.Kind, &m.SentAt, &threadID, &m.MentionKind, &m.Unread); err != nil {
+			continue
+		}
+		if len(rich) > 0 {
+			m.Rich = rich
+		}
+		if threadID.Valid {
+			m.ThreadID = &threadID.Int64
+		}
+		mentions = append(mentions, m)
+	}
+	utils.JSON(w, http.StatusOK, utils.APIResponse{Success: true, Message: "mentions fetched", Data: mentions})
+}
@@ -0,0 +1,90 @@
+package user
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"convo/internal/chat"
+	"convo/internal/middleware"
+	"convo/internal/utils"
+)
+
+// maxSearchResults caps the number of users returned by a search.
+const maxSearchResults = 20
+
+// SearchHandler finds users by name or email. Only users sharing a
+// workspace with the caller can be found, so workspaces stay isolated.
+type SearchHandler struct {
+	DB *sql.DB
+}
+
+type SearchResult struct {
+	ID    int64  `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// ServeHTTP handles GET /user/search?q=...&workspace_id=...
+func (h *SearchHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int64)
+	if !ok {
+		utils.JSON(w, http.StatusUnauthorized, utils.APIResponse{Success: false, Message: "Unauthorized"})
+		return
+	}
+
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if len(q) < 2 {
+		utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "q must be at least 2 characters"})
+		return
+	}
+	like := "%" + escapeLike(q) + "%"
+
+	var rows *sql.Rows
+	var err error
+	if wsStr := r.URL.Query().Get("workspace_id"); wsStr != "" {
+		workspaceID, perr := strconv.ParseInt(wsStr, 10, 64)
+		if perr != nil {
+			utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "invalid workspace_id"})
+			return
+		}
+		if _, err := chat.WorkspaceRole(h.DB, workspaceID, userID); err == chat.ErrNotWorkspaceMember {
+			utils.JSON(w, http.StatusNotFound, utils.APIResponse{Success: false, Message: "workspace not found"})
+			return
+		} else if err != nil {
+			utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "DB error checking workspace", Data: map[string]interface{}{"error": err.Error()}})
+			return
+		}
+		rows, err = h.DB.Query(`SELECT u.id, u.name, u.email FROM users u
+			JOIN workspace_members m ON m.user_id = u.id
+			WHERE m.workspace_id = ? AND (u.name LIKE ? OR u.email LIKE ?)
+			ORDER BY u.name LIMIT ?`, workspaceID, like, like, maxSearchResults)
+	} else {
+		rows, err = h.DB.Query(`SELECT DISTINCT u.id, u.name, u.email FROM users u
+			JOIN workspace_members m ON m.user_id = u.id
+			JOIN workspace_members mine ON mine.workspace_id = m.workspace_id AND mine.user_id = ?
+			WHERE u.name LIKE ? OR u.email LIKE ?
+			ORDER BY u.name LIMIT ?`, userID, like, like, maxSearchResults)
+	}
+	if err != nil {
+		utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "DB error", Data: map[string]interface{}{"error": err.Error()}})
+		return
+	}
+	defer rows.Close()
+
+	users := []SearchResult{}
+	for rows.Next() {
+		var u SearchResult
+		if err := rows.Scan(&u.ID, &u.Name, &u.Email); err != nil {
+			continue
+		}
+		users = append(users, u)
+	}
+	utils.JSON(w, http.StatusOK, utils.APIResponse{Success: true, Message: "users fetched", Data: users})
+}
+
+// escapeLike escapes the LIKE wildcards in s so user input matches literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,70 @@
+package user
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"convo/internal/middleware"
+	"convo/internal/models"
+	"convo/internal/utils"
+)
+
+// StarredHandler lists the caller's starred messages, newest first, in
+// rooms they still belong to
+type StarredHandler struct {
+	DB *sql.DB
+}
+
+// ServeHTTP handles GET /user/starred?num=&last_id=
+func (h *StarredHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int64)
+	if !ok {
+		utils.JSON(w, http.StatusUnauthorized, utils.APIResponse{Success: false, Message: "Unauthorized"})
+		return
+	}
+
+	num := 50
+	if v := r.URL.Query().Get("num"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 100 {
+			utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "num must be 1-100"})
+			return
+		}
+		num = n
+	}
+	var lastID int64
+	if v := r.URL.Query().Get("last_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "Invalid last_id"})
+			return
+		}
+		lastID = id
+	}
+
+	rows, err := h.DB.Query(`SELECT m.id, m.room_id, m.sender_id, m.content, m.format, m.rich, m.kind, m.sent_at FROM message_meta mm
+		JOIN messages m ON m.id = mm.message_id
+		JOIN room_members rm ON rm.room_id = m.room_id AND rm.user_id = mm.user_id
+		WHERE mm.user_id = ? AND mm.starred = 1 AND m.deleted_at IS NULL AND (? = 0 OR m.id < ?)
+		ORDER BY m.id DESC LIMIT ?`, userID, lastID, lastID, num)
+	if err != nil {
+		utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "DB error", Data: map[string]interface{}{"error": err.Error()}})
+		return
+	}
+	defer rows.Close()
+
+	messages := []models.Message{}
+	for rows.Next() {
+		var m models.Message
+		var rich []byte
+		if err := rows.Scan(&m.ID, &m.RoomID, &m.SenderID, &m.Content, &m.Format, &rich, &m.Kind, &m.SentAt); err != nil {
+			continue
+		}
+		if len(rich) > 0 {
+			m.Rich = rich
+		}
+		messages = append(messages, m)
+	}
+	utils.JSON(w, http.StatusOK, utils.APIResponse{Success: true, Message: "starred messages fetched", Data: messages})
+}
@@ -0,0 +1,48 @@
+package workspace
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"convo/internal/chat"
+	"convo/internal/middleware"
+	"convo/internal/utils"
+)
+
+// workspaceAccess resolves the caller and the workspace in the path and
+// returns the caller's role in it. Non-members get a 404 so workspaces
+// cannot be discovered by id. It writes the error response itself and
+// returns ok=false when the request must stop.
+func workspaceAccess(w http.ResponseWriter, r *http.Request, db *sql.DB) (userID, workspaceID int64, role string, ok bool) {
+	userID, ok = r.Context().Value(middleware.UserIDKey).(int64)
+	if !ok {
+		utils.JSON(w, http.StatusUnauthorized, utils.APIResponse{Success: false, Message: "Unauthorized"})
+		return 0, 0, "", false
+	}
+	workspaceID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "invalid workspace id"})
+		return 0, 0, "", false
+	}
+	role, err = chat.WorkspaceRole(db, workspaceID, userID)
+	if err == chat.ErrNotWorkspaceMember {
+		utils.JSON(w, http.StatusNotFound, utils.APIResponse{Success: false, Message: "workspace not found"})
+		return 0, 0, "", false
+	} else if err != nil {
+		utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "DB error checking workspace", Data: map[string]interface{}{"error": err.Error()}})
+		return 0, 0, "", false
+	}
+	return userID, workspaceID, role, true
+}
+
+// pathUserID parses the {userId} URL param, returning 0 when invalid.
+func pathUserID(r *http.Request) int64 {
+	id, err := strconv.ParseInt(chi.URLParam(r, "userId"), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return id
+}
@@ -0,0 +1,72 @@
+package workspace
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"convo/internal/chat"
+	"convo/internal/middleware"
+	"convo/internal/models"
+	"convo/internal/utils"
+)
+
+type CreateWorkspaceHandler struct {
+	DB *sql.DB
+}
+
+type CreateWorkspaceRequest struct {
+	Name string `json:"name"`
+}
+
+// ServeHTTP handles POST /workspaces
+func (h *CreateWorkspaceHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int64)
+	if !ok {
+		utils.JSON(w, http.StatusUnauthorized, utils.APIResponse{Success: false, Message: "Unauthorized"})
+		return
+	}
+
+	var req CreateWorkspaceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "Invalid request body"})
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "name is required"})
+		return
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "Failed to start tx", Data: map[string]interface{}{"error": err.Error()}})
+		return
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("INSERT INTO workspaces (name, created_by) VALUES (?, ?)", req.Name, userID)
+	if err != nil {
+		utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "Failed to create workspace", Data: map[string]interface{}{"error": err.Error()}})
+		return
+	}
+	id, _ := result.LastInsertId()
+
+	if _, err := tx.Exec("INSERT INTO workspace_members (workspace_id, user_id, role) VALUES (?, ?, ?)", id, userID, chat.WorkspaceOwner); err != nil {
+		utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "Failed to add creator to workspace", Data: map[string]interface{}{"error": err.Error()}})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "Failed to commit tx", Data: map[string]interface{}{"error": err.Error()}})
+		return
+	}
+
+	var createdAt time.Time
+	_ = h.DB.QueryRow("SELECT created_at FROM workspaces WHERE id = ?", id).Scan(&createdAt)
+
+	resp := models.Workspace{ID: id, Name: req.Name, CreatedBy: userID, CreatedAt: createdAt, Role: chat.WorkspaceOwner}
+	utils.JSON(w, http.StatusCreated, utils.APIResponse{Success: true, Message: "Workspace created", Data: resp})
+}
@@ -0,0 +1,193 @@
+package workspace
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	mysql "github.com/go-sql-driver/mysql"
+
+	"convo/internal/chat"
+	"convo/internal/middleware"
+	"convo/internal/models"
+	"convo/internal/utils"
+)
+
+// defaultInviteTTL is how long an invitation stays valid unless the
+// request asks for something else.
+const defaultInviteTTL = 7 * 24 * time.Hour
+
+type CreateInviteRequest struct {
+	Email        string `json:"email"`
+	Role         string `json:"role,omitempty"` // admin or member, default member
+	ExpiresHours int    `json:"expires_hours,omitempty"`
+}
+
+// CreateInviteHandler lets workspace admins invite people by email
+type CreateInviteHandler struct {
+	DB *sql.DB
+}
+
+// ServeHTTP handles POST /workspaces/{id}/invites
+func (h *CreateInviteHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	userID, workspaceID, role, ok := workspaceAccess(w, r, h.DB)
+	if !ok {
+		return
+	}
+	if !chat.IsWorkspaceAdmin(role) {
+		utils.JSON(w, http.StatusForbidden, utils.APIResponse{Success: false, Message: "insufficient workspace permissions"})
+		return
+	}
+
+	var req CreateInviteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "Invalid request body"})
+		return
+	}
+	req.Email = strings.TrimSpace(req.Email)
+	if req.Email == "" {
+		utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "email is required"})
+		return
+	}
+	if req.Role == "" {
+		req.Role = chat.WorkspaceMember
+	}
+	if req.Role != chat.WorkspaceAdmin && req.Role != chat.WorkspaceMember {
+		utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "role must be admin or member"})
+		return
+	}
+	if req.Role == chat.WorkspaceAdmin && role != chat.WorkspaceOwner {
+		utils.JSON(w, http.StatusForbidden, utils.APIResponse{Success: false, Message: "only the workspace owner can invite admins"})
+		return
+	}
+	ttl := defaultInviteTTL
+	if req.ExpiresHours > 0 {
+		ttl = time.Duration(req.ExpiresHours) * time.Hour
+	}
+
+	token, err := utils.RandomTokenHex(32)
+	if err != nil {
+		utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "Failed to generate token"})
+		return
+	}
+
+	inv := models.WorkspaceInvite{
+		WorkspaceID: workspaceID,
+		Email:       req.Email,
+		Role:        req.Role,
+		Token:       token,
+		InvitedBy:   userID,
+		ExpiresAt:   time.Now().Add(ttl),
+	}
+	result, err := h.DB.Exec(`INSERT INTO workspace_invites (workspace_id, email, role, token, invited_by, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?)`, workspaceID, inv.Email, inv.Role, inv.Token, userID, inv.ExpiresAt)
+	if err != nil {
+		utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "Failed to create invite", Data: map[string]interface{}{"error": err.Error()}})
+		return
+	}
+	inv.ID, _ = result.LastInsertId()
+
+	utils.JSON(w, http.StatusCreated, utils.APIResponse{Success: true, Message: "Invite created", Data: inv})
+}
+
+// InviteListHandler lists a workspace's pending invitations for its admins
+type InviteListHandler struct {
+	DB *sql.DB
+}
+
+// ServeHTTP handles GET /workspaces/{id}/invites
+func (h *InviteListHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, workspaceID, role, ok := workspaceAccess(w, r, h.DB)
+	if !ok {
+		return
+	}
+	if !chat.IsWorkspaceAdmin(role) {
+		utils.JSON(w, http.StatusForbidden, utils.APIResponse{Success: false, Message: "insufficient workspace permissions"})
+		return
+	}
+
+	rows, err := h.DB.Query(`SELECT id, email, role, invited_by, expires_at FROM workspace_invites
+		WHERE workspace_id = ? AND accepted_at IS NULL AND expires_at > NOW() ORDER BY id DESC`, workspaceID)
+	if err != nil {
+		utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "DB error", Data: map[string]interface{}{"error": err.Error()}})
+		return
+	}
+	defer rows.Close()
+
+	invites := []models.WorkspaceInvite{}
+	for rows.Next() {
+		inv := models.WorkspaceInvite{WorkspaceID: workspaceID}
+		if err := rows.Scan(&inv.ID, &inv.Email, &inv.Role, &inv.InvitedBy, &inv.ExpiresAt); err != nil {
+			continue
+		}
+		invites = append(invites, inv)
+	}
+	utils.JSON(w, http.StatusOK, utils.APIResponse{Success: true, Message: "invites fetched", Data: invites})
+}
+
+// AcceptInviteHandler joins the caller to the workspace of an invitation
+// addressed to their email
+type AcceptInviteHandler struct {
+	DB *sql.DB
+}
+
+// ServeHTTP handles POST /workspaces/invites/{token}/accept
+func (h *AcceptInviteHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int64)
+	if !ok {
+		utils.JSON(w, http.StatusUnauthorized, utils.APIResponse{Success: false, Message: "Unauthorized"})
+		return
+	}
+	token := chi.URLParam(r, "token")
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "Failed to start tx", Data: map[string]interface{}{"error": err.Error()}})
+		return
+	}
+	defer tx.Rollback()
+
+	var inv models.WorkspaceInvite
+	var userEmail string
+	var acceptedAt sql.NullTime
+	err = tx.QueryRow(`SELECT i.id, i.workspace_id, i.email, i.role, i.invited_by, i.expires_at, i.accepted_at, u.email
+		FROM workspace_invites i JOIN users u ON u.id = ? WHERE i.token = ? FOR UPDATE`, userID, token).
+		Scan(&inv.ID, &inv.WorkspaceID, &inv.Email, &inv.Role, &inv.InvitedBy, &inv.ExpiresAt, &acceptedAt, &userEmail)
+	// invites for someone else look exactly like unknown tokens
+	if err == sql.ErrNoRows || (err == nil && !strings.EqualFold(inv.Email, userEmail)) {
+		utils.JSON(w, http.StatusNotFound, utils.APIResponse{Success: false, Message: "invite not found"})
+		return
+	} else if err != nil {
+		utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "DB error", Data: map[string]interface{}{"error": err.Error()}})
+		return
+	}
+	if acceptedAt.Valid {
+		utils.JSON(w, http.StatusConflict, utils.APIResponse{Success: false, Message: "invite already used"})
+		return
+	}
+	if time.Now().After(inv.ExpiresAt) {
+		utils.JSON(w, http.StatusGone, utils.APIResponse{Success: false, Message: "invite expired"})
+		return
+	}
+
+	if _, err := tx.Exec("INSERT INTO workspace_members (workspace_id, user_id, role) VALUES (?, ?, ?)", inv.WorkspaceID, userID, inv.Role); err != nil {
+		// already a member: just consume the invite
+		if me, ok := err.(*mysql.MySQLError); !ok || me.Number != 1062 {
+			utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "failed to join workspace", Data: map[string]interface{}{"error": err.Error()}})
+			return
+		}
+	}
+	if _, err := tx.Exec("UPDATE workspace_invites SET accepted_by = ?, accepted_at = NOW() WHERE id = ?", userID, inv.ID); err != nil {
+		utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "failed to accept invite", Data: map[string]interface{}{"error": err.Error()}})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "Failed to commit tx", Data: map[string]interface{}{"error": err.Error()}})
+		return
+	}
+
+	utils.JSON(w, http.StatusOK, utils.APIResponse{Success: true, Message: "joined workspace", Data: map[string]interface{}{"workspace_id": inv.WorkspaceID, "role": inv.Role}})
+}
@@ -0,0 +1,41 @@
+package workspace
+
+import (
+	"database/sql"
+	"net/http"
+
+	"convo/internal/middleware"
+	"convo/internal/models"
+	"convo/internal/utils"
+)
+
+type WorkspaceListHandler struct {
+	DB *sql.DB
+}
+
+// ServeHTTP handles GET /workspaces
+func (h *WorkspaceListHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int64)
+	if !ok {
+		utils.JSON(w, http.StatusUnauthorized, utils.APIResponse{Success: false, Message: "Unauthorized"})
+		return
+	}
+
+	rows, err := h.DB.Query(`SELECT w.id, w.name, w.created_by, w.created_at, m.role FROM workspaces w
+		JOIN workspace_members m ON w.id = m.workspace_id WHERE m.user_id = ? ORDER BY w.id`, userID)
+	if err != nil {
+		utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "DB error", Data: map[string]interface{}{"error": err.Error()}})
+		return
+	}
+	defer rows.Close()
+
+	workspaces := []models.Workspace{}
+	for rows.Next() {
+		var ws models.Workspace
+		if err := rows.Scan(&ws.ID, &ws.Name, &ws.CreatedBy, &ws.CreatedAt, &ws.Role); err != nil {
+			continue
+		}
+		workspaces = append(workspaces, ws)
+	}
+	utils.JSON(w, http.StatusOK, utils.APIResponse{Success: true, Message: "workspaces fetched", Data: workspaces})
+}
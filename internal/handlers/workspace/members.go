@@ -0,0 +1,183 @@
+package workspace
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+
+	"convo/internal/chat"
+	"convo/internal/models"
+	"convo/internal/utils"
+	"convo/internal/ws"
+)
+
+type MembersHandler struct {
+	DB *sql.DB
+}
+
+// ServeHTTP handles GET /workspaces/{id}/members
+func (h *MembersHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, workspaceID, _, ok := workspaceAccess(w, r, h.DB)
+	if !ok {
+		return
+	}
+
+	rows, err := h.DB.Query(`SELECT u.id, u.name, u.email, m.role, m.joined_at FROM workspace_members m
+		JOIN users u ON u.id = m.user_id WHERE m.workspace_id = ? ORDER BY u.name`, workspaceID)
+	if err != nil {
+		utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "DB error", Data: map[string]interface{}{"error": err.Error()}})
+		return
+	}
+	defer rows.Close()
+
+	members := []models.WorkspaceMember{}
+	for rows.Next() {
+		m := models.WorkspaceMember{WorkspaceID: workspaceID}
+		if err := rows.Scan(&m.UserID, &m.Name, &m.Email, &m.Role, &m.JoinedAt); err != nil {
+			continue
+		}
+		members = append(members, m)
+	}
+	utils.JSON(w, http.StatusOK, utils.APIResponse{Success: true, Message: "members fetched", Data: members})
+}
+
+type MemberRoleRequest struct {
+	Role string `json:"role"`
+}
+
+// MemberRoleHandler lets the workspace owner promote or demote admins
+type MemberRoleHandler struct {
+	DB *sql.DB
+}
+
+// ServeHTTP handles PUT /workspaces/{id}/members/{userId}/role
+func (h *MemberRoleHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	userID, workspaceID, role, ok := workspaceAccess(w, r, h.DB)
+	if !ok {
+		return
+	}
+	if role != chat.WorkspaceOwner {
+		utils.JSON(w, http.StatusForbidden, utils.APIResponse{Success: false, Message: "only the workspace owner can change roles"})
+		return
+	}
+
+	var req MemberRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "Invalid request body"})
+		return
+	}
+	if req.Role != chat.WorkspaceAdmin && req.Role != chat.WorkspaceMember {
+		utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "role must be admin or member"})
+		return
+	}
+	targetID := pathUserID(r)
+	if targetID == 0 || targetID == userID {
+		utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "invalid user id"})
+		return
+	}
+
+	res, err := h.DB.Exec("UPDATE workspace_members SET role = ? WHERE workspace_id = ? AND user_id = ?", req.Role, workspaceID, targetID)
+	if err != nil {
+		utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "failed to update role", Data: map[string]interface{}{"error": err.Error()}})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		// either not a member or already had that role
+		if _, err := chat.WorkspaceRole(h.DB, workspaceID, targetID); err == chat.ErrNotWorkspaceMember {
+			utils.JSON(w, http.StatusNotFound, utils.APIResponse{Success: false, Message: "user not a member of the workspace"})
+			return
+		}
+	}
+	utils.JSON(w, http.StatusOK, utils.APIResponse{Success: true, Message: "role updated", Data: map[string]interface{}{"workspace_id": workspaceID, "user_id": targetID, "role": req.Role}})
+}
+
+// RemoveMemberHandler removes a user from a workspace and all of its rooms
+type RemoveMemberHandler struct {
+	DB *sql.DB
+}
+
+// ServeHTTP handles DELETE /workspaces/{id}/members/{userId}
+func (h *RemoveMemberHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	userID, workspaceID, role, ok := workspaceAccess(w, r, h.DB)
+	if !ok {
+		return
+	}
+	targetID := pathUserID(r)
+	if targetID == 0 {
+		utils.JSON(w, http.StatusBadRequest, utils.APIResponse{Success: false, Message: "invalid user id"})
+		return
+	}
+	// members may leave on their own; removing others takes an admin
+	if targetID != userID && !chat.IsWorkspaceAdmin(role) {
+		utils.JSON(w, http.StatusForbidden, utils.APIResponse{Success: false, Message: "insufficient workspace permissions"})
+		return
+	}
+
+	targetRole, err := chat.WorkspaceRole(h.DB, workspaceID, targetID)
+	if err == chat.ErrNotWorkspaceMember {
+		utils.JSON(w, http.StatusNotFound, utils.APIResponse{Success: false, Message: "user not a member of the workspace"})
+		return
+	} else if err != nil {
+		utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "DB error checking workspace", Data: map[string]interface{}{"error": err.Error()}})
+		return
+	}
+	if targetRole == chat.WorkspaceOwner {
+		utils.JSON(w, http.StatusForbidden, utils.APIResponse{Success: false, Message: "the workspace owner cannot be removed"})
+		return
+	}
+	if targetRole == chat.WorkspaceAdmin && targetID != userID && role != chat.WorkspaceOwner {
+		utils.JSON(w, http.StatusForbidden, utils.APIResponse{Success: false, Message: "only the workspace owner can remove admins"})
+		return
+	}
+
+	tx, err := h.DB.Begin()
+	if err != nil {
+		utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "Failed to start tx", Data: map[string]interface{}{"error": err.Error()}})
+		return
+	}
+	defer tx.Rollback()
+
+	// remember the rooms so the member's live connections can be dropped
+	rows, err := tx.Query(`SELECT rm.room_id FROM room_members rm JOIN rooms r ON r.id = rm.room_id
+		WHERE r.workspace_id = ? AND rm.user_id = ?`, workspaceID, targetID)
+	if err != nil {
+		utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "failed to list room memberships", Data: map[string]interface{}{"error": err.Error()}})
+		return
+	}
+	var roomIDs []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "failed to list room memberships", Data: map[string]interface{}{"error": err.Error()}})
+			return
+		}
+		roomIDs = append(roomIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "failed to list room memberships", Data: map[string]interface{}{"error": err.Error()}})
+		return
+	}
+
+	if _, err := tx.Exec(`DELETE rm FROM room_members rm JOIN rooms r ON r.id = rm.room_id
+		WHERE r.workspace_id = ? AND rm.user_id = ?`, workspaceID, targetID); err != nil {
+		utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "failed to remove room memberships", Data: map[string]interface{}{"error": err.Error()}})
+		return
+	}
+	if _, err := tx.Exec("DELETE FROM workspace_members WHERE workspace_id = ? AND user_id = ?", workspaceID, targetID); err != nil {
+		utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "failed to remove member", Data: map[string]interface{}{"error": err.Error()}})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		utils.JSON(w, http.StatusInternalServerError, utils.APIResponse{Success: false, Message: "Failed to commit tx", Data: map[string]interface{}{"error": err.Error()}})
+		return
+	}
+
+	for _, roomID := range roomIDs {
+		b, _ := json.Marshal(map[string]interface{}{"type": "removed", "room_id": roomID, "workspace_id": workspaceID})
+		ws.Disconnect(roomID, targetID, b)
+	}
+
+	utils.JSON(w, http.StatusOK, utils.APIResponse{Success: true, Message: "member removed", Data: map[string]interface{}{"workspace_id": workspaceID, "user_id": targetID}})
+}
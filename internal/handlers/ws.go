@@ -3,10 +3,12 @@ package handlers
 import (
        "database/sql"
        "encoding/json"
+       "errors"
        "net/http"
        "strconv"
        "time"
 
+       "convo/internal/chat"
        "convo/internal/ws"
        "convo/internal/utils"
 
@@ -17,6 +19,17 @@ type WSMessage struct {
        Type      string `json:"type"`
        RoomID    int64  `json:"room_id"`
        Content   string `json:"content,omitempty"`
+       Format    string `json:"format,omitempty"` // send_message: plain (default) or markdown
+       MessageID int64  `json:"message_id,omitempty"`
+       Scope     string `json:"scope,omitempty"` // delete_message: me or everyone
+       Emoji     string `json:"emoji,omitempty"`
+       ThreadID  int64  `json:"thread_id,omitempty"` // send_message: reply in this thread
+       ReplyTo   int64  `json:"reply_to,omitempty"`  // send_message: quote this message
+       TargetID  int64  `json:"target_room_id,omitempty"` // forward_message: room to forward into
+       TTLSeconds int   `json:"ttl_seconds,omitempty"` // send_message: disappear after being read
+       OptionIDs []int64 `json:"option_ids,omitempty"` // vote_poll: replaces the caller's votes
+       ClientMsgID string `json:"client_msg_id,omitempty"` // send_message: dedupes retries
+       AttachmentIDs []int64 `json:"attachment_ids,omitempty"` // send_message: uploads to send
        // Add more fields as needed
 }
 
@@ -59,6 +72,13 @@ func Handler(w http.ResponseWriter, r *http.Request) {
               http.Error(w, "db error", http.StatusInternalServerError)
               return
        }
+       if ban, err := chat.ActiveBan(db, roomID, userID); err != nil {
+              http.Error(w, "db error", http.StatusInternalServerError)
+              return
+       } else if ban != nil {
+              http.Error(w, "banned from room", http.StatusForbidden)
+              return
+       }
 
        // Upgrade to WebSocket
        conn, err := upgrader.Upgrade(w, r, nil)
@@ -93,26 +113,74 @@ func Handler(w http.ResponseWriter, r *http.Request) {
               }
               switch wsmsg.Type {
               case "send_message":
-                     // Save to DB (reuse send-message handler logic)
-                     if wsmsg.Content == "" {
-                            sendError(c, "content required")
+                     // Same path as POST /rooms/{id}/send-message; it broadcasts
+                     // the stored message to the room itself
+                     m, err := chat.SendMessage(db, chat.SendRequest{RoomID: roomID, SenderID: userID, Content: wsmsg.Content, Format: wsmsg.Format, ThreadID: wsmsg.ThreadID, ReplyToID: wsmsg.ReplyTo, TTLSeconds: wsmsg.TTLSeconds, ClientMsgID: wsmsg.ClientMsgID, AttachmentIDs: wsmsg.AttachmentIDs})
+                     if err != nil {
+                            sendSendError(c, err)
                             continue
                      }
-                     // Insert into DB (use sender_id)
-                     _, err := db.Exec("INSERT INTO messages (room_id, sender_id, content, sent_at) VALUES (?, ?, ?, ?)", roomID, userID, wsmsg.Content, time.Now())
-                     if err != nil {
-                            sendError(c, "db error sending message")
+                     if wsmsg.ClientMsgID != "" {
+                            // a retry is not broadcast again, so always tell
+                            // the sender which message their id maps to
+                            b, _ := json.Marshal(map[string]interface{}{"type": "message_sent", "client_msg_id": wsmsg.ClientMsgID, "message": m})
+                            c.Send <- b
+                     }
+              case "forward_message":
+                     // the copy is broadcast to the target room, which this
+                     // connection may not be watching
+                     if _, err := chat.ForwardMessage(db, roomID, wsmsg.MessageID, userID, wsmsg.TargetID); err != nil {
+                            sendSendError(c, err)
+                            continue
+                     }
+                     sendAck(c, "message forwarded")
+              case "edit_message":
+                     // chat.EditMessage broadcasts message_edited itself
+                     if _, err := chat.EditMessage(db, roomID, wsmsg.MessageID, userID, wsmsg.Content); err != nil {
+                            sendError(c, messageErrorText(err))
+                            continue
+                     }
+              case "delete_message":
+                     if wsmsg.Scope == "" {
+                            wsmsg.Scope = chat.DeleteForMe
+                     }
+                     if err := chat.DeleteMessage(db, roomID, wsmsg.MessageID, userID, wsmsg.Scope); err != nil {
+                            sendError(c, messageErrorText(err))
+                            continue
+                     }
+              case "add_reaction":
+                     if err := chat.AddReaction(db, roomID, wsmsg.MessageID, userID, wsmsg.Emoji); err != nil {
+                            sendError(c, messageErrorText(err))
+                            continue
+                     }
+              case "remove_reaction":
+                     if err := chat.RemoveReaction(db, roomID, wsmsg.MessageID, userID, wsmsg.Emoji); err != nil {
+                            sendError(c, messageErrorText(err))
+                            continue
+                     }
+              case "pin_message", "unpin_message":
+                     // moderators only; broadcasts message_pinned/unpinned
+                     pin := chat.PinMessage
+                     if wsmsg.Type == "unpin_message" {
+                            pin = chat.UnpinMessage
+                     }
+                     if err := pin(db, roomID, wsmsg.MessageID, userID); err != nil {
+                            sendError(c, messageErrorText(err))
+                            continue
+                     }
+              case "vote_poll":
+                     // chat.Vote broadcasts poll_updated itself
+                     if _, err := chat.Vote(db, roomID, wsmsg.MessageID, userID, wsmsg.OptionIDs); err != nil {
+                            sendError(c, messageErrorText(err))
+                            continue
+                     }
+              case "read", "delivered":
+                     // message_id acknowledges everything up to and including it
+                     if err := chat.Ack(db, roomID, userID, wsmsg.MessageID, wsmsg.Type); err != nil {
+                            sendError(c, messageErrorText(err))
                             continue
                      }
-                     // Broadcast to room
-                       wsmsg.RoomID = roomID
-                       wsmsg.Type = "message" // outgoing type
-                       b, _ := json.Marshal(wsmsg)
-                       hub.Broadcast <- b
-              case "read":
-                     // Optionally: mark as read in DB, or just acknowledge
-                     // For now, just send ack
-                     sendAck(c, "read received")
+                     sendAck(c, wsmsg.Type+" received")
               case "join":
                      // Already joined on connect, but can send ack
                      sendAck(c, "joined room")
@@ -140,3 +208,37 @@ func sendAck(c *ws.Connection, msg string) {
        b, _ := json.Marshal(m)
        c.Send <- b
 }
+
+// sendSendError reports an error from chat.SendMessage to the client; when the
+// user has to wait it includes when they may send again.
+func sendSendError(c *ws.Connection, err error) {
+       var wait *chat.WaitError
+       switch {
+       case err == chat.ErrNotMember, err == chat.ErrEmptyContent, err == chat.ErrMessageNotFound, err == chat.ErrMessageDeleted,
+              err == chat.ErrInvalidTTL, err == chat.ErrInvalidClientMsgID, err == chat.ErrInvalidFormat, err == chat.ErrContentTooLong, err == chat.ErrInvalidAttachment:
+              sendError(c, err.Error())
+       case errors.As(err, &wait):
+              m := map[string]interface{}{
+                     "type":        "error",
+                     "message":     wait.Reason,
+                     "retry_after": int64(wait.RetryAfter() / time.Second),
+                     "retry_at":    wait.Until,
+              }
+              b, _ := json.Marshal(m)
+              c.Send <- b
+       default:
+              sendError(c, "db error sending message")
+       }
+}
+
+// messageErrorText turns an error from the chat message operations into a
+// message safe to show to clients.
+func messageErrorText(err error) string {
+       switch err {
+       case chat.ErrNotMember, chat.ErrMessageNotFound, chat.ErrForbidden, chat.ErrEditWindowClosed, chat.ErrEmptyContent,
+              chat.ErrMessageDeleted, chat.ErrInvalidScope, chat.ErrInvalidStatus, chat.ErrInvalidEmoji, chat.ErrTooManyReactions, chat.ErrTooManyPins,
+              chat.ErrPollClosed, chat.ErrInvalidVote, chat.ErrContentTooLong:
+              return err.Error()
+       }
+       return "db error"
+}
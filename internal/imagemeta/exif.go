@@ -0,0 +1,208 @@
+package imagemeta
+
+import (
+	"encoding/binary"
+	"math"
+	"strings"
+	"time"
+)
+
+// EXIF tags read by parseEXIF.
+const (
+	tagMake              = 0x010F
+	tagModel             = 0x0110
+	tagOrientation       = 0x0112
+	tagSoftware          = 0x0131
+	tagDateTime          = 0x0132
+	tagExifIFD           = 0x8769
+	tagGPSIFD            = 0x8825
+	tagDateTimeOriginal  = 0x9003
+	tagDateTimeDigitized = 0x9004
+
+	tagGPSLatitudeRef  = 1
+	tagGPSLatitude     = 2
+	tagGPSLongitudeRef = 3
+	tagGPSLongitude    = 4
+	tagGPSAltitudeRef  = 5
+	tagGPSAltitude     = 6
+)
+
+// typeSizes is the size in bytes of one value of each TIFF field type.
+var typeSizes = map[uint16]int{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8}
+
+// tiff is an EXIF block: a TIFF header and its IFDs.
+type tiff struct {
+	b     []byte
+	order binary.ByteOrder
+}
+
+// entry is one IFD field; data holds its values.
+type entry struct {
+	typ   uint16
+	count int
+	data  []byte
+}
+
+// parseEXIF fills m from the TIFF-structured EXIF data in b. Anything it
+// cannot read is skipped.
+func parseEXIF(b []byte, m *Metadata) {
+	if len(b) < 8 {
+		return
+	}
+	t := &tiff{b: b}
+	switch string(b[:2]) {
+	case "II":
+		t.order = binary.LittleEndian
+	case "MM":
+		t.order = binary.BigEndian
+	default:
+		return
+	}
+	if t.order.Uint16(b[2:]) != 42 {
+		return
+	}
+
+	ifd0 := t.ifd(t.order.Uint32(b[4:]))
+	m.CameraMake = t.str(ifd0[tagMake])
+	m.CameraModel = t.str(ifd0[tagModel])
+	m.Software = t.str(ifd0[tagSoftware])
+	if o := t.uint(ifd0[tagOrientation]); o >= 1 && o <= 8 {
+		m.Orientation = int(o)
+	}
+	m.DateTime = exifTime(t.str(ifd0[tagDateTime]))
+
+	if e, ok := ifd0[tagExifIFD]; ok {
+		sub := t.ifd(t.uint(e))
+		m.DateTimeOriginal = exifTime(t.str(sub[tagDateTimeOriginal]))
+		m.DateTimeDigitized = exifTime(t.str(sub[tagDateTimeDigitized]))
+	}
+	if e, ok := ifd0[tagGPSIFD]; ok {
+		m.GPS = t.gps(t.ifd(t.uint(e)))
+	}
+}
+
+// ifd reads the IFD at offset into a map by tag.
+func (t *tiff) ifd(offset uint32) map[uint16]entry {
+	entries := make(map[uint16]entry)
+	off := int(offset)
+	if off < 8 || off+2 > len(t.b) {
+		return entries
+	}
+	n := int(t.order.Uint16(t.b[off:]))
+	for i := 0; i < n; i++ {
+		p := off + 2 + 12*i
+		if p+12 > len(t.b) {
+			break
+		}
+		typ := t.order.Uint16(t.b[p+2:])
+		size, ok := typeSizes[typ]
+		if !ok {
+			continue
+		}
+		count := int(t.order.Uint32(t.b[p+4:]))
+		total := size * count
+		if count < 0 || total < 0 || total > len(t.b) {
+			continue
+		}
+		// values up to four bytes are stored in the entry itself
+		data := t.b[p+8 : p+12]
+		if total > 4 {
+			vo := int(t.order.Uint32(t.b[p+8:]))
+			if vo < 0 || vo+total > len(t.b) {
+				continue
+			}
+			data = t.b[vo : vo+total]
+		}
+		entries[t.order.Uint16(t.b[p:])] = entry{typ: typ, count: count, data: data[:total]}
+	}
+	return entries
+}
+
+func (t *tiff) str(e entry) string {
+	if e.typ != 2 {
+		return ""
+	}
+	s := string(e.data)
+	if i := strings.IndexByte(s, 0); i >= 0 {
+		s = s[:i]
+	}
+	return strings.TrimSpace(s)
+}
+
+// uint returns the first value of a BYTE, SHORT or LONG entry.
+func (t *tiff) uint(e entry) uint32 {
+	if e.count < 1 {
+		return 0
+	}
+	switch e.typ {
+	case 1:
+		return uint32(e.data[0])
+	case 3:
+		return uint32(t.order.Uint16(e.data))
+	case 4:
+		return t.order.Uint32(e.data)
+	}
+	return 0
+}
+
+// rationals returns the values of a RATIONAL entry.
+func (t *tiff) rationals(e entry) []float64 {
+	if e.typ != 5 {
+		return nil
+	}
+	vals := make([]float64, 0, e.count)
+	for i := 0; i < e.count; i++ {
+		num := t.order.Uint32(e.data[8*i:])
+		den := t.order.Uint32(e.data[8*i+4:])
+		if den == 0 {
+			return nil
+		}
+		vals = append(vals, float64(num)/float64(den))
+	}
+	return vals
+}
+
+func (t *tiff) gps(ifd map[uint16]entry) *GPS {
+	lat := t.degrees(ifd[tagGPSLatitude], t.str(ifd[tagGPSLatitudeRef]), "S")
+	lon := t.degrees(ifd[tagGPSLongitude], t.str(ifd[tagGPSLongitudeRef]), "W")
+	if math.IsNaN(lat) || math.IsNaN(lon) || math.Abs(lat) > 90 || math.Abs(lon) > 180 {
+		return nil
+	}
+	g := &GPS{Latitude: lat, Longitude: lon}
+	if alt := t.rationals(ifd[tagGPSAltitude]); len(alt) == 1 {
+		a := alt[0]
+		// reference 1 means below sea level
+		if ref, ok := ifd[tagGPSAltitudeRef]; ok && t.uint(ref) == 1 {
+			a = -a
+		}
+		g.Altitude = &a
+	}
+	return g
+}
+
+// degrees converts a degrees/minutes/seconds entry to decimal degrees,
+// negative for the negative reference; NaN if it is missing.
+func (t *tiff) degrees(e entry, ref, negative string) float64 {
+	dms := t.rationals(e)
+	if len(dms) != 3 {
+		return math.NaN()
+	}
+	d := dms[0] + dms[1]/60 + dms[2]/3600
+	if strings.EqualFold(ref, negative) {
+		d = -d
+	}
+	return d
+}
+
+// exifTime parses an EXIF timestamp. EXIF stores local time without a
+// zone, so the result is in UTC only nominally.
+func exifTime(s string) *time.Time {
+	if s == "" {
+		return nil
+	}
+	ts, err := time.Parse("2006:01:02 15:04:05", s)
+	if err != nil {
+		return nil
+	}
+	return &ts
+}
@@ -0,0 +1,218 @@
+package imagemeta
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+func parseJPEG(b []byte) (*Metadata, error) {
+	m := &Metadata{Format: JPEG, MIMEType: "image/jpeg"}
+	for i := 2; ; {
+		// markers may be padded with any number of 0xFF bytes
+		for i < len(b) && b[i] == 0xFF {
+			i++
+		}
+		if i >= len(b) {
+			break
+		}
+		marker := b[i]
+		i++
+		// standalone markers have no length
+		if marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7) {
+			continue
+		}
+		if marker == 0xD9 || marker == 0xDA {
+			// end of image, or start of scan: no headers past this point
+			break
+		}
+		if i+2 > len(b) {
+			break
+		}
+		n := int(binary.BigEndian.Uint16(b[i:]))
+		if n < 2 || i+n > len(b) {
+			break
+		}
+		seg := b[i+2 : i+n]
+		i += n
+		switch {
+		case marker == 0xE1 && bytes.HasPrefix(seg, []byte("Exif\x00\x00")):
+			parseEXIF(seg[6:], m)
+		// SOF0-SOF15 except DHT (C4), JPG (C8) and DAC (CC)
+		case marker >= 0xC0 && marker <= 0xCF && marker != 0xC4 && marker != 0xC8 && marker != 0xCC:
+			if len(seg) < 5 {
+				return nil, ErrMalformed
+			}
+			m.BitDepth = int(seg[0])
+			m.Height = int(binary.BigEndian.Uint16(seg[1:]))
+			m.Width = int(binary.BigEndian.Uint16(seg[3:]))
+		}
+	}
+	if m.Width == 0 || m.Height == 0 {
+		return nil, ErrMalformed
+	}
+	return m, nil
+}
+
+func parsePNG(b []byte) (*Metadata, error) {
+	m := &Metadata{Format: PNG, MIMEType: "image/png"}
+	for i := 8; i+8 <= len(b); {
+		n := int(binary.BigEndian.Uint32(b[i:]))
+		typ := string(b[i+4 : i+8])
+		if n < 0 || i+8+n > len(b) {
+			break
+		}
+		data := b[i+8 : i+8+n]
+		i += 12 + n // length, type, data and CRC
+		switch typ {
+		case "IHDR":
+			if len(data) < 9 {
+				return nil, ErrMalformed
+			}
+			m.Width = int(binary.BigEndian.Uint32(data))
+			m.Height = int(binary.BigEndian.Uint32(data[4:]))
+			m.BitDepth = int(data[8])
+		case "acTL":
+			m.Animated = true
+			if len(data) >= 4 {
+				m.Frames = int(binary.BigEndian.Uint32(data))
+			}
+		case "eXIf":
+			parseEXIF(data, m)
+		case "IDAT", "IEND":
+			// eXIf and acTL must come before the image data
+			i = len(b)
+		}
+	}
+	if m.Width == 0 || m.Height == 0 {
+		return nil, ErrMalformed
+	}
+	return m, nil
+}
+
+func parseGIF(b []byte) (*Metadata, error) {
+	if len(b) < 13 {
+		return nil, ErrMalformed
+	}
+	m := &Metadata{Format: GIF, MIMEType: "image/gif"}
+	m.Width = int(binary.LittleEndian.Uint16(b[6:]))
+	m.Height = int(binary.LittleEndian.Uint16(b[8:]))
+	i := 13
+	if b[10]&0x80 != 0 {
+		m.BitDepth = int(b[10]&0x07) + 1
+		i += 3 << (int(b[10]&0x07) + 1) // global colour table
+	}
+	// count image descriptors, skipping everything else
+	for i < len(b) {
+		switch b[i] {
+		case 0x21: // extension: label, then data sub-blocks
+			i = skipSubBlocks(b, i+2)
+		case 0x2C: // image descriptor, local colour table, LZW data
+			if i+10 > len(b) {
+				i = len(b)
+				break
+			}
+			m.Frames++
+			flags := b[i+9]
+			i += 10
+			if flags&0x80 != 0 {
+				i += 3 << (int(flags&0x07) + 1)
+			}
+			i = skipSubBlocks(b, i+1)
+		default: // trailer or garbage
+			i = len(b)
+		}
+	}
+	m.Animated = m.Frames > 1
+	if m.Width == 0 || m.Height == 0 {
+		return nil, ErrMalformed
+	}
+	return m, nil
+}
+
+// skipSubBlocks returns the index past the sub-block chain starting at i.
+func skipSubBlocks(b []byte, i int) int {
+	for i < len(b) {
+		n := int(b[i])
+		i++
+		if n == 0 {
+			return i
+		}
+		i += n
+	}
+	return len(b)
+}
+
+func parseWebP(b []byte) (*Metadata, error) {
+	m := &Metadata{Format: WebP, MIMEType: "image/webp"}
+	for i := 12; i+8 <= len(b); {
+		typ := string(b[i : i+4])
+		n := int(binary.LittleEndian.Uint32(b[i+4:]))
+		if n < 0 || i+8+n > len(b) {
+			break
+		}
+		data := b[i+8 : i+8+n]
+		i += 8 + n + n%2 // chunks are padded to even sizes
+		switch typ {
+		case "VP8X":
+			if len(data) < 10 {
+				return nil, ErrMalformed
+			}
+			m.Animated = data[0]&0x02 != 0
+			m.Width = int(uint32(data[4])|uint32(data[5])<<8|uint32(data[6])<<16) + 1
+			m.Height = int(uint32(data[7])|uint32(data[8])<<8|uint32(data[9])<<16) + 1
+		case "VP8 ":
+			// frame tag, then the start code 9d 01 2a and 14-bit sizes
+			if len(data) < 10 || !bytes.Equal(data[3:6], []byte{0x9D, 0x01, 0x2A}) {
+				return nil, ErrMalformed
+			}
+			if m.Width == 0 {
+				m.Width = int(binary.LittleEndian.Uint16(data[6:]) & 0x3FFF)
+				m.Height = int(binary.LittleEndian.Uint16(data[8:]) & 0x3FFF)
+			}
+		case "VP8L":
+			if len(data) < 5 || data[0] != 0x2F {
+				return nil, ErrMalformed
+			}
+			if m.Width == 0 {
+				bits := binary.LittleEndian.Uint32(data[1:])
+				m.Width = int(bits&0x3FFF) + 1
+				m.Height = int(bits>>14&0x3FFF) + 1
+			}
+		case "ANMF":
+			m.Frames++
+		case "EXIF":
+			parseEXIF(bytes.TrimPrefix(data, []byte("Exif\x00\x00")), m)
+		}
+	}
+	if m.Width == 0 || m.Height == 0 {
+		return nil, ErrMalformed
+	}
+	return m, nil
+}
+
+func parseBMP(b []byte) (*Metadata, error) {
+	if len(b) < 26 {
+		return nil, ErrMalformed
+	}
+	m := &Metadata{Format: BMP, MIMEType: "image/bmp"}
+	switch header := binary.LittleEndian.Uint32(b[14:]); {
+	case header == 12: // BITMAPCOREHEADER
+		m.Width = int(binary.LittleEndian.Uint16(b[18:]))
+		m.Height = int(binary.LittleEndian.Uint16(b[20:]))
+		m.BitDepth = int(binary.LittleEndian.Uint16(b[24:]))
+	case header >= 40 && len(b) >= 30:
+		m.Width = int(int32(binary.LittleEndian.Uint32(b[18:])))
+		// a negative height marks a top-down bitmap
+		m.Height = int(int32(binary.LittleEndian.Uint32(b[22:])))
+		if m.Height < 0 {
+			m.Height = -m.Height
+		}
+		m.BitDepth = int(binary.LittleEndian.Uint16(b[28:]))
+	default:
+		return nil, ErrMalformed
+	}
+	if m.Width <= 0 || m.Height == 0 {
+		return nil, ErrMalformed
+	}
+	return m, nil
+}
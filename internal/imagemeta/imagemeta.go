@@ -0,0 +1,89 @@
+// Package imagemeta reads image metadata without decoding pixels: format,
+// dimensions and, where the file carries EXIF, camera, orientation,
+// timestamps and GPS position. JPEG, PNG, GIF, WebP and BMP are supported.
+package imagemeta
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"time"
+)
+
+// Formats, as reported in Metadata.Format.
+const (
+	JPEG = "jpeg"
+	PNG  = "png"
+	GIF  = "gif"
+	WebP = "webp"
+	BMP  = "bmp"
+)
+
+var (
+	// ErrUnknownFormat is returned for data that is not a supported image.
+	ErrUnknownFormat = errors.New("imagemeta: unknown image format")
+	// ErrMalformed is returned when an image's headers are cut short or
+	// inconsistent.
+	ErrMalformed = errors.New("imagemeta: malformed image")
+)
+
+// MaxRead is how much of an image Decode reads. Dimensions and EXIF sit at
+// the start of every supported format; only counting GIF frames needs more.
+var MaxRead int64 = 64 << 20
+
+// Metadata describes an image. Width and Height are as stored; an EXIF
+// Orientation of 5 to 8 means the image is displayed rotated by 90 degrees.
+type Metadata struct {
+	Format   string `json:"format"`
+	MIMEType string `json:"mime_type"`
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+	// BitDepth is bits per pixel for BMP and bits per channel for PNG.
+	BitDepth int  `json:"bit_depth,omitempty"`
+	Animated bool `json:"animated,omitempty"`
+	Frames   int  `json:"frames,omitempty"`
+
+	Orientation       int        `json:"orientation,omitempty"`
+	CameraMake        string     `json:"camera_make,omitempty"`
+	CameraModel       string     `json:"camera_model,omitempty"`
+	Software          string     `json:"software,omitempty"`
+	DateTime          *time.Time `json:"date_time,omitempty"`
+	DateTimeOriginal  *time.Time `json:"date_time_original,omitempty"`
+	DateTimeDigitized *time.Time `json:"date_time_digitized,omitempty"`
+	GPS               *GPS       `json:"gps,omitempty"`
+}
+
+// GPS is the position an image was taken at, in decimal degrees and
+// metres above sea level.
+type GPS struct {
+	Latitude  float64  `json:"latitude"`
+	Longitude float64  `json:"longitude"`
+	Altitude  *float64 `json:"altitude,omitempty"`
+}
+
+// Decode reads up to MaxRead bytes of r and returns the image's metadata.
+// Broken EXIF does not fail the call; the fields it would fill stay empty.
+func Decode(r io.Reader) (*Metadata, error) {
+	b, err := io.ReadAll(io.LimitReader(r, MaxRead))
+	if err != nil {
+		return nil, err
+	}
+	return Parse(b)
+}
+
+// Parse returns the metadata of the image in b.
+func Parse(b []byte) (*Metadata, error) {
+	switch {
+	case bytes.HasPrefix(b, []byte{0xFF, 0xD8, 0xFF}):
+		return parseJPEG(b)
+	case bytes.HasPrefix(b, []byte("\x89PNG\r\n\x1a\n")):
+		return parsePNG(b)
+	case bytes.HasPrefix(b, []byte("GIF87a")), bytes.HasPrefix(b, []byte("GIF89a")):
+		return parseGIF(b)
+	case len(b) >= 12 && bytes.Equal(b[:4], []byte("RIFF")) && bytes.Equal(b[8:12], []byte("WEBP")):
+		return parseWebP(b)
+	case bytes.HasPrefix(b, []byte("BM")):
+		return parseBMP(b)
+	}
+	return nil, ErrUnknownFormat
+}
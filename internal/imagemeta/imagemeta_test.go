@@ -0,0 +1,475 @@
+package imagemeta
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"hash/crc32"
+	"math"
+	"testing"
+	"time"
+)
+
+// jpeg wraps segments between SOI and EOI.
+func jpeg(segments ...[]byte) []byte {
+	b := []byte{0xFF, 0xD8}
+	for _, s := range segments {
+		b = append(b, s...)
+	}
+	return append(b, 0xFF, 0xD9)
+}
+
+func segment(marker byte, payload []byte) []byte {
+	b := []byte{0xFF, marker, 0, 0}
+	binary.BigEndian.PutUint16(b[2:], uint16(len(payload)+2))
+	return append(b, payload...)
+}
+
+func sof(marker byte, width, height int) []byte {
+	return segment(marker, []byte{8, byte(height >> 8), byte(height), byte(width >> 8), byte(width), 3, 1, 0x22, 0, 2, 0x11, 1, 3, 0x11, 1})
+}
+
+func app1(tiff []byte) []byte {
+	return segment(0xE1, append([]byte("Exif\x00\x00"), tiff...))
+}
+
+func png(chunks ...[]byte) []byte {
+	b := []byte("\x89PNG\r\n\x1a\n")
+	for _, c := range chunks {
+		b = append(b, c...)
+	}
+	return b
+}
+
+func pngChunk(typ string, data []byte) []byte {
+	b := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	b = append(b, typ...)
+	b = append(b, data...)
+	return binary.BigEndian.AppendUint32(b, crc32.ChecksumIEEE(b[4:]))
+}
+
+func ihdr(width, height uint32, depth byte) []byte {
+	data := binary.BigEndian.AppendUint32(nil, width)
+	data = binary.BigEndian.AppendUint32(data, height)
+	return pngChunk("IHDR", append(data, depth, 6, 0, 0, 0))
+}
+
+// gif builds a GIF with the given number of 1x1 frames and, when colours
+// is non-zero, a global colour table of 2^colours entries.
+func gif(version string, width, height uint16, colours, frames int) []byte {
+	b := append([]byte(version), 0, 0, 0, 0, 0, 0, 0)
+	binary.LittleEndian.PutUint16(b[6:], width)
+	binary.LittleEndian.PutUint16(b[8:], height)
+	if colours > 0 {
+		b[10] = 0x80 | byte(colours-1)
+		b = append(b, make([]byte, 3<<colours)...)
+	}
+	for range frames {
+		b = append(b, 0x21, 0xF9, 4, 0, 10, 0, 0, 0) // graphic control
+		b = append(b, 0x2C, 0, 0, 0, 0, 1, 0, 1, 0, 0)
+		b = append(b, 2, 2, 0x4C, 0x01, 0) // LZW data
+	}
+	return append(b, 0x3B)
+}
+
+func webp(chunks ...[]byte) []byte {
+	var body []byte
+	for _, c := range chunks {
+		body = append(body, c...)
+	}
+	b := append([]byte("RIFF"), 0, 0, 0, 0)
+	binary.LittleEndian.PutUint32(b[4:], uint32(4+len(body)))
+	b = append(b, "WEBP"...)
+	return append(b, body...)
+}
+
+func riffChunk(typ string, data []byte) []byte {
+	b := append([]byte(typ), 0, 0, 0, 0)
+	binary.LittleEndian.PutUint32(b[4:], uint32(len(data)))
+	b = append(b, data...)
+	if len(data)%2 == 1 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+func vp8(width, height uint16) []byte {
+	data := []byte{0x50, 0x01, 0x00, 0x9D, 0x01, 0x2A}
+	data = binary.LittleEndian.AppendUint16(data, width)
+	return riffChunk("VP8 ", binary.LittleEndian.AppendUint16(data, height))
+}
+
+func vp8l(width, height uint32) []byte {
+	return riffChunk("VP8L", binary.LittleEndian.AppendUint32([]byte{0x2F}, (width-1)|(height-1)<<14))
+}
+
+func vp8x(flags byte, width, height uint32) []byte {
+	data := []byte{flags, 0, 0, 0}
+	w, h := width-1, height-1
+	data = append(data, byte(w), byte(w>>8), byte(w>>16), byte(h), byte(h>>8), byte(h>>16))
+	return riffChunk("VP8X", data)
+}
+
+func bmp(header uint32, width, height int32, depth uint16) []byte {
+	b := make([]byte, 14+header)
+	copy(b, "BM")
+	binary.LittleEndian.PutUint32(b[2:], uint32(len(b)))
+	binary.LittleEndian.PutUint32(b[14:], header)
+	if header == 12 {
+		binary.LittleEndian.PutUint16(b[18:], uint16(width))
+		binary.LittleEndian.PutUint16(b[20:], uint16(height))
+		binary.LittleEndian.PutUint16(b[22:], 1)
+		binary.LittleEndian.PutUint16(b[24:], depth)
+		return b
+	}
+	binary.LittleEndian.PutUint32(b[18:], uint32(width))
+	binary.LittleEndian.PutUint32(b[22:], uint32(height))
+	binary.LittleEndian.PutUint16(b[26:], 1)
+	binary.LittleEndian.PutUint16(b[28:], depth)
+	return b
+}
+
+// field is an IFD entry for exifWriter.build. Pointer entries hold the
+// offset of ifds[ifd]; entries with offset set point there instead of at
+// their data.
+type field struct {
+	tag, typ uint16
+	count    uint32
+	data     []byte
+	pointer  bool
+	ifd      int
+	offset   uint32
+}
+
+// byteOrder is binary.LittleEndian or binary.BigEndian.
+type byteOrder interface {
+	binary.ByteOrder
+	binary.AppendByteOrder
+}
+
+type exifWriter struct{ order byteOrder }
+
+func (w exifWriter) ascii(tag uint16, s string) field {
+	return field{tag: tag, typ: 2, count: uint32(len(s) + 1), data: append([]byte(s), 0)}
+}
+
+func (w exifWriter) short(tag, v uint16) field {
+	return field{tag: tag, typ: 3, count: 1, data: w.order.AppendUint16(nil, v)}
+}
+
+func (w exifWriter) rationals(tag uint16, fractions ...uint32) field {
+	var data []byte
+	for _, v := range fractions {
+		data = w.order.AppendUint32(data, v)
+	}
+	return field{tag: tag, typ: 5, count: uint32(len(fractions) / 2), data: data}
+}
+
+func (w exifWriter) pointer(tag uint16, ifd int) field {
+	return field{tag: tag, typ: 4, count: 1, pointer: true, ifd: ifd}
+}
+
+// build lays out a TIFF header and ifds one after the other, each followed
+// by the values that do not fit in its entries. IFD0 is ifds[0].
+func (w exifWriter) build(ifds ...[]field) []byte {
+	offsets := make([]int, len(ifds))
+	size := 8
+	for i, fields := range ifds {
+		offsets[i] = size
+		size += 2 + 12*len(fields) + 4
+		for _, f := range fields {
+			if len(f.data) > 4 && f.offset == 0 {
+				size += len(f.data)
+			}
+		}
+	}
+	b := make([]byte, size)
+	copy(b, "MM")
+	if w.order == binary.LittleEndian {
+		copy(b, "II")
+	}
+	w.order.PutUint16(b[2:], 42)
+	w.order.PutUint32(b[4:], 8)
+	for i, fields := range ifds {
+		p := offsets[i]
+		w.order.PutUint16(b[p:], uint16(len(fields)))
+		values := p + 2 + 12*len(fields) + 4
+		for j, f := range fields {
+			e := b[p+2+12*j:]
+			w.order.PutUint16(e, f.tag)
+			w.order.PutUint16(e[2:], f.typ)
+			w.order.PutUint32(e[4:], f.count)
+			switch {
+			case f.pointer:
+				w.order.PutUint32(e[8:], uint32(offsets[f.ifd]))
+			case f.offset != 0:
+				w.order.PutUint32(e[8:], f.offset)
+			case len(f.data) > 4:
+				w.order.PutUint32(e[8:], uint32(values))
+				values += copy(b[values:], f.data)
+			default:
+				copy(e[8:12], f.data)
+			}
+		}
+	}
+	return b
+}
+
+// camera is a complete EXIF block: IFD0, the EXIF IFD and the GPS IFD.
+func camera(order byteOrder) []byte {
+	w := exifWriter{order}
+	return w.build(
+		[]field{
+			w.ascii(tagMake, "Canon"),
+			w.ascii(tagModel, "EOS 5D  "),
+			w.ascii(tagSoftware, "GIMP"),
+			w.short(tagOrientation, 6),
+			w.ascii(tagDateTime, "2024:05:01 12:00:00"),
+			w.pointer(tagExifIFD, 1),
+			w.pointer(tagGPSIFD, 2),
+		},
+		[]field{
+			w.ascii(tagDateTimeOriginal, "2024:04:30 08:15:00"),
+			w.ascii(tagDateTimeDigitized, "2024:04:30 08:15:01"),
+		},
+		[]field{
+			w.ascii(tagGPSLatitudeRef, "S"),
+			w.rationals(tagGPSLatitude, 33, 1, 51, 1, 3156, 100),
+			w.ascii(tagGPSLongitudeRef, "E"),
+			w.rationals(tagGPSLongitude, 151, 1, 12, 1, 5076, 100),
+			{tag: tagGPSAltitudeRef, typ: 1, count: 1, data: []byte{1}},
+			w.rationals(tagGPSAltitude, 58, 1),
+		},
+	)
+}
+
+func at(s string) *time.Time {
+	t, err := time.Parse(time.DateTime, s)
+	if err != nil {
+		panic(err)
+	}
+	return &t
+}
+
+func cameraMetadata(m Metadata) Metadata {
+	alt := -58.0
+	m.Orientation = 6
+	m.CameraMake = "Canon"
+	m.CameraModel = "EOS 5D"
+	m.Software = "GIMP"
+	m.DateTime = at("2024-05-01 12:00:00")
+	m.DateTimeOriginal = at("2024-04-30 08:15:00")
+	m.DateTimeDigitized = at("2024-04-30 08:15:01")
+	m.GPS = &GPS{
+		Latitude:  -(33.0 + 51.0/60 + 31.56/3600),
+		Longitude: 151.0 + 12.0/60 + 50.76/3600,
+		Altitude:  &alt,
+	}
+	return m
+}
+
+var jpegMeta = Metadata{Format: JPEG, MIMEType: "image/jpeg", Width: 8, Height: 8, BitDepth: 8}
+
+var formatTests = []struct {
+	name string
+	data []byte
+	want Metadata
+}{
+	{"jpeg", jpeg(segment(0xE0, []byte("JFIF\x00\x01\x01")), sof(0xC0, 640, 480)), Metadata{Format: JPEG, MIMEType: "image/jpeg", Width: 640, Height: 480, BitDepth: 8}},
+	{"jpeg progressive after DHT and fill bytes", jpeg(segment(0xC4, []byte{0}), []byte{0xFF, 0xFF}, sof(0xC2, 1, 65535)), Metadata{Format: JPEG, MIMEType: "image/jpeg", Width: 1, Height: 65535, BitDepth: 8}},
+	{"jpeg exif little endian", jpeg(app1(camera(binary.LittleEndian)), sof(0xC0, 8, 8)), cameraMetadata(jpegMeta)},
+	{"jpeg exif big endian", jpeg(app1(camera(binary.BigEndian)), sof(0xC0, 8, 8)), cameraMetadata(jpegMeta)},
+	{"png", png(ihdr(100, 50, 16), pngChunk("IDAT", []byte{1}), pngChunk("IEND", nil)), Metadata{Format: PNG, MIMEType: "image/png", Width: 100, Height: 50, BitDepth: 16}},
+	{"apng with exif", png(ihdr(3, 4, 8), pngChunk("acTL", []byte{0, 0, 0, 3, 0, 0, 0, 0}), pngChunk("eXIf", camera(binary.BigEndian)), pngChunk("IDAT", []byte{1})),
+		cameraMetadata(Metadata{Format: PNG, MIMEType: "image/png", Width: 3, Height: 4, BitDepth: 8, Animated: true, Frames: 3})},
+	{"png chunks after IDAT", png(ihdr(3, 4, 8), pngChunk("IDAT", []byte{1}), pngChunk("acTL", []byte{0, 0, 0, 3, 0, 0, 0, 0})), Metadata{Format: PNG, MIMEType: "image/png", Width: 3, Height: 4, BitDepth: 8}},
+	{"gif87a", gif("GIF87a", 10, 20, 0, 1), Metadata{Format: GIF, MIMEType: "image/gif", Width: 10, Height: 20, Frames: 1}},
+	{"gif89a animated", gif("GIF89a", 300, 200, 2, 3), Metadata{Format: GIF, MIMEType: "image/gif", Width: 300, Height: 200, BitDepth: 2, Animated: true, Frames: 3}},
+	{"webp lossy", webp(vp8(320, 240)), Metadata{Format: WebP, MIMEType: "image/webp", Width: 320, Height: 240}},
+	{"webp lossless", webp(vp8l(64, 16384)), Metadata{Format: WebP, MIMEType: "image/webp", Width: 64, Height: 16384}},
+	{"webp extended", webp(vp8x(0x02, 1<<24, 400), riffChunk("EXIF", append([]byte("Exif\x00\x00"), camera(binary.LittleEndian)...)), riffChunk("ANMF", []byte{1, 2, 3}), riffChunk("ANMF", nil)),
+		cameraMetadata(Metadata{Format: WebP, MIMEType: "image/webp", Width: 1 << 24, Height: 400, Animated: true, Frames: 2})},
+	{"webp extended keeps VP8X size", webp(vp8x(0, 500, 400), riffChunk("EXIF", camera(binary.BigEndian)), vp8(20, 10)),
+		cameraMetadata(Metadata{Format: WebP, MIMEType: "image/webp", Width: 500, Height: 400})},
+	{"bmp core header", bmp(12, 12, 34, 24), Metadata{Format: BMP, MIMEType: "image/bmp", Width: 12, Height: 34, BitDepth: 24}},
+	{"bmp info header", bmp(40, 640, 480, 32), Metadata{Format: BMP, MIMEType: "image/bmp", Width: 640, Height: 480, BitDepth: 32}},
+	{"bmp top-down v5 header", bmp(124, 7, -30, 8), Metadata{Format: BMP, MIMEType: "image/bmp", Width: 7, Height: 30, BitDepth: 8}},
+}
+
+// equal compares metadata by its JSON form, which follows the pointers.
+func equal(t *testing.T, got *Metadata, want Metadata) {
+	t.Helper()
+	g, _ := json.Marshal(got)
+	w, _ := json.Marshal(want)
+	if string(g) != string(w) {
+		t.Errorf("got  %s\nwant %s", g, w)
+	}
+}
+
+func TestParse(t *testing.T) {
+	for _, tt := range formatTests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := Parse(tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			equal(t, m, tt.want)
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{"empty", nil, ErrUnknownFormat},
+		{"text", []byte("hello, world"), ErrUnknownFormat},
+		{"riff but not webp", []byte("RIFF\x04\x00\x00\x00WAVE"), ErrUnknownFormat},
+		{"jpeg without a frame", jpeg(segment(0xE0, []byte("JFIF"))), ErrMalformed},
+		{"jpeg short frame header", jpeg(segment(0xC0, []byte{8, 0, 1})), ErrMalformed},
+		{"jpeg zero width", jpeg(sof(0xC0, 0, 480)), ErrMalformed},
+		{"jpeg segment past the end", []byte{0xFF, 0xD8, 0xFF, 0xC0, 0x00, 0x11, 8, 0, 1, 0, 1}, ErrMalformed},
+		{"jpeg segment length below two", []byte{0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x01, 0xFF, 0xC0}, ErrMalformed},
+		{"jpeg frame after start of scan", jpeg(segment(0xDA, []byte{0}), sof(0xC0, 8, 8)), ErrMalformed},
+		{"png signature only", png(), ErrMalformed},
+		{"png short IHDR", png(pngChunk("IHDR", []byte{0, 0, 0, 1})), ErrMalformed},
+		{"png IHDR past the end", png(ihdr(1, 1, 8))[:20], ErrMalformed},
+		{"png huge chunk length", png([]byte{0xFF, 0xFF, 0xFF, 0xFF, 'I', 'H', 'D', 'R'}), ErrMalformed},
+		{"png zero height", png(ihdr(1, 0, 8)), ErrMalformed},
+		{"gif short header", []byte("GIF89a\x01\x00\x01\x00"), ErrMalformed},
+		{"gif zero width", gif("GIF89a", 0, 1, 0, 1), ErrMalformed},
+		{"webp header only", webp(), ErrMalformed},
+		{"webp bad VP8 start code", webp(riffChunk("VP8 ", []byte{0, 0, 0, 0x9D, 0x01, 0x2B, 1, 0, 1, 0})), ErrMalformed},
+		{"webp short VP8", webp(riffChunk("VP8 ", []byte{0, 0, 0, 0x9D, 0x01, 0x2A})), ErrMalformed},
+		{"webp bad VP8L signature", webp(riffChunk("VP8L", []byte{0x2E, 0, 0, 0, 0})), ErrMalformed},
+		{"webp short VP8X", webp(riffChunk("VP8X", []byte{0, 0, 0, 0})), ErrMalformed},
+		{"webp chunk past the end", webp(vp8(1, 1))[:20], ErrMalformed},
+		{"webp zero VP8 size", webp(vp8(0, 10)), ErrMalformed},
+		{"bmp short", []byte("BM\x00\x00"), ErrMalformed},
+		{"bmp unknown header", bmp(20, 1, 1, 8), ErrMalformed},
+		{"bmp info header cut short", bmp(40, 1, 1, 8)[:28], ErrMalformed},
+		{"bmp negative width", bmp(40, -1, 1, 8), ErrMalformed},
+		{"bmp zero height", bmp(12, 1, 0, 8), ErrMalformed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := Parse(tt.data)
+			if err != tt.err {
+				t.Fatalf("got %+v, %v; want %v", m, err, tt.err)
+			}
+		})
+	}
+}
+
+// Every prefix of a valid image either fails or parses to sane metadata.
+func TestParseTruncated(t *testing.T) {
+	for _, tt := range formatTests {
+		for n := range len(tt.data) {
+			if m, err := Parse(tt.data[:n]); err == nil {
+				checkMetadata(t, m)
+			}
+		}
+	}
+}
+
+// Broken EXIF leaves the image readable and the fields it would have set
+// empty; whatever is intact is still read.
+func TestParseEXIFCorrupt(t *testing.T) {
+	le := exifWriter{binary.LittleEndian}
+	loop := []byte{
+		'I', 'I', 42, 0, 8, 0, 0, 0,
+		// IFD0 at 8: an EXIF IFD and a GPS IFD both pointing back at
+		// IFD0, and a next-IFD link to itself
+		3, 0,
+		0x0F, 0x01, 2, 0, 4, 0, 0, 0, 'A', 'c', 'm', 0,
+		0x69, 0x87, 4, 0, 1, 0, 0, 0, 8, 0, 0, 0,
+		0x25, 0x88, 4, 0, 1, 0, 0, 0, 8, 0, 0, 0,
+		8, 0, 0, 0,
+	}
+	tests := []struct {
+		name string
+		exif []byte
+		want Metadata
+	}{
+		{"IFDs pointing back at IFD0", loop, Metadata{CameraMake: "Acm"}},
+		{"IFDs pointing at themselves", le.build(
+			[]field{le.ascii(tagMake, "Acme"), le.pointer(tagExifIFD, 1), le.pointer(tagGPSIFD, 2)},
+			[]field{le.pointer(tagExifIFD, 1), le.ascii(tagDateTimeOriginal, "2024:04:30 08:15:00")},
+			[]field{le.pointer(tagGPSIFD, 2), le.rationals(tagGPSLatitude, 1, 1, 0, 1, 0, 1), le.rationals(tagGPSLongitude, 2, 1, 0, 1, 0, 1)},
+		), Metadata{CameraMake: "Acme", DateTimeOriginal: at("2024-04-30 08:15:00"), GPS: &GPS{Latitude: 1, Longitude: 2}}},
+		{"pointers out of range", le.build(
+			[]field{le.ascii(tagMake, "Acme"), {tag: tagExifIFD, typ: 4, count: 1, offset: 0xFFFFFFF0}, {tag: tagGPSIFD, typ: 4, count: 1, offset: 4}},
+		), Metadata{CameraMake: "Acme"}},
+		{"IFD0 past the end", []byte("MM\x00\x2a\xff\xff\xff\xf0"), Metadata{}},
+		{"IFD0 inside the header", []byte("II\x2a\x00\x04\x00\x00\x00"), Metadata{}},
+		{"bad byte order", append([]byte("XX"), camera(binary.BigEndian)[2:]...), Metadata{}},
+		{"bad magic", append([]byte("MM\x00\x2b"), camera(binary.BigEndian)[4:]...), Metadata{}},
+		{"entry count past the end", func() []byte {
+			b := le.build([]field{le.short(tagOrientation, 3)})
+			b[8], b[9] = 0xFF, 0xFF
+			return b
+		}(), Metadata{Orientation: 3}},
+		{"values past the end", le.build([]field{
+			{tag: tagMake, typ: 2, count: 64, offset: 20},
+			{tag: tagModel, typ: 2, count: 0xFFFFFFFF, offset: 8},
+			{tag: tagSoftware, typ: 0xFF, count: 1},
+			le.ascii(tagDateTime, "2024:05:01 12:00:00"),
+		}), Metadata{DateTime: at("2024-05-01 12:00:00")}},
+		{"values out of range", le.build(
+			[]field{le.short(tagOrientation, 9), le.ascii(tagDateTime, "2024-05-01"), le.pointer(tagGPSIFD, 1)},
+			[]field{le.rationals(tagGPSLatitude, 95, 1, 0, 1, 0, 1), le.rationals(tagGPSLongitude, 2, 1, 0, 1, 0, 1)},
+		), Metadata{}},
+		{"zero denominator", le.build(
+			[]field{le.pointer(tagGPSIFD, 1)},
+			[]field{le.rationals(tagGPSLatitude, 1, 0, 0, 1, 0, 1), le.rationals(tagGPSLongitude, 2, 1, 0, 1, 0, 1)},
+		), Metadata{}},
+		{"wrong types", le.build(
+			[]field{le.ascii(tagOrientation, "6"), le.short(tagMake, 1), le.pointer(tagGPSIFD, 1)},
+			[]field{le.ascii(tagGPSLatitude, "1"), le.rationals(tagGPSLongitude, 2, 1)},
+		), Metadata{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := Parse(jpeg(app1(tt.exif), sof(0xC0, 8, 8)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := tt.want
+			want.Format, want.MIMEType, want.Width, want.Height, want.BitDepth = JPEG, "image/jpeg", 8, 8, 8
+			equal(t, m, want)
+		})
+	}
+}
+
+// checkMetadata fails t if m breaks the guarantees Parse makes on success.
+func checkMetadata(t *testing.T, m *Metadata) {
+	t.Helper()
+	if m.Format == "" || m.MIMEType == "" || m.Width <= 0 || m.Height <= 0 {
+		t.Fatalf("parsed to %+v", m)
+	}
+	if m.Orientation < 0 || m.Orientation > 8 || m.Frames < 0 {
+		t.Fatalf("parsed to %+v", m)
+	}
+	if g := m.GPS; g != nil && (math.IsNaN(g.Latitude) || math.Abs(g.Latitude) > 90 || math.IsNaN(g.Longitude) || math.Abs(g.Longitude) > 180) {
+		t.Fatalf("GPS out of range: %+v", *g)
+	}
+}
+
+func FuzzExtract(f *testing.F) {
+	for _, tt := range formatTests {
+		f.Add(tt.data)
+	}
+	f.Add(jpeg(app1(camera(binary.BigEndian)[:40]), sof(0xC0, 8, 8)))
+	f.Add(png(ihdr(1, 1, 8), pngChunk("eXIf", camera(binary.LittleEndian)[:100])))
+	f.Fuzz(func(t *testing.T, b []byte) {
+		m, err := Parse(b)
+		if err != nil {
+			if m != nil || (err != ErrUnknownFormat && err != ErrMalformed) {
+				t.Fatalf("got %+v, %v", m, err)
+			}
+			return
+		}
+		checkMetadata(t, m)
+	})
+}
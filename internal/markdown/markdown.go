@@ -0,0 +1,418 @@
+// Package markdown parses the small markdown dialect messages may use into
+// a structured tree that clients render themselves. The tree never
+// carries markup: text is returned as is for the client to escape, and
+// links are only kept for safe URL schemes.
+package markdown
+
+import (
+	"net/url"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Node types.
+const (
+	Paragraph = "paragraph"
+	Quote     = "quote"
+	CodeBlock = "code_block"
+	Text      = "text"
+	Break     = "break"
+	Bold      = "bold"
+	Italic    = "italic"
+	Strike    = "strike"
+	Code      = "code"
+	Link      = "link"
+	Mention   = "mention"
+)
+
+// maxDepth bounds how deeply quotes and inline styles may nest; anything
+// deeper is kept as text.
+const maxDepth = 8
+
+// Node is one element of a parsed message. Text holds the content of text,
+// code and code_block nodes and the literal "@name" of mentions; the
+// others hold Children.
+type Node struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	URL      string `json:"url,omitempty"`
+	Lang     string `json:"lang,omitempty"`
+	UserID   int64  `json:"user_id,omitempty"`
+	Children []Node `json:"children,omitempty"`
+}
+
+// MentionFunc recognises a mention at the start of s, the text right after
+// an '@'. It returns the length in bytes of the mentioned name and the
+// user it refers to (0 for @here and @room), or 0 if s is not a mention.
+type MentionFunc func(s string) (n int, userID int64)
+
+// Parse turns src into a list of block nodes. mention may be nil, in which
+// case no mentions are recognised.
+func Parse(src string, mention MentionFunc) []Node {
+	p := &parser{mention: mention}
+	return p.blocks(clean(src), 0)
+}
+
+// clean normalises line endings and drops control characters other than
+// newlines and tabs.
+func clean(s string) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\t' {
+			return r
+		}
+		if unicode.IsControl(r) || r == utf8.RuneError {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+type parser struct {
+	mention MentionFunc
+}
+
+func (p *parser) blocks(src string, depth int) []Node {
+	nodes := []Node{}
+	lines := strings.Split(src, "\n")
+	var para []string
+	flush := func() {
+		if len(para) > 0 {
+			nodes = append(nodes, Node{Type: Paragraph, Children: p.inline(strings.Join(para, "\n"), depth)})
+			para = nil
+		}
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "```"):
+			flush()
+			lang := strings.TrimSpace(strings.TrimPrefix(trimmed, "```"))
+			var code []string
+			for i++; i < len(lines) && strings.TrimSpace(lines[i]) != "```"; i++ {
+				code = append(code, lines[i])
+			}
+			nodes = append(nodes, Node{Type: CodeBlock, Lang: safeLang(lang), Text: strings.Join(code, "\n")})
+		case strings.HasPrefix(trimmed, ">") && depth < maxDepth:
+			flush()
+			var quoted []string
+			for ; i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), ">"); i++ {
+				q := strings.TrimPrefix(strings.TrimSpace(lines[i]), ">")
+				quoted = append(quoted, strings.TrimPrefix(q, " "))
+			}
+			i--
+			nodes = append(nodes, Node{Type: Quote, Children: p.blocks(strings.Join(quoted, "\n"), depth+1)})
+		case trimmed == "":
+			flush()
+		default:
+			para = append(para, line)
+		}
+	}
+	flush()
+	return nodes
+}
+
+// safeLang keeps a code block language only if it looks like one.
+func safeLang(lang string) string {
+	if len(lang) > 32 {
+		return ""
+	}
+	for _, r := range lang {
+		if !(r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("+-_.#", r))) {
+			return ""
+		}
+	}
+	return lang
+}
+
+// inline parses the spans of one paragraph. Every search for a closing
+// delimiter goes through the finders of sp, so each kind of delimiter is
+// scanned for once per call however many openers fail to find one.
+func (p *parser) inline(s string, depth int) []Node {
+	sp := newSpans(s)
+	var nodes []Node
+	var text strings.Builder
+	emit := func(n Node) {
+		if n.Type == Text {
+			text.WriteString(n.Text)
+			return
+		}
+		if text.Len() > 0 {
+			nodes = append(nodes, Node{Type: Text, Text: text.String()})
+			text.Reset()
+		}
+		nodes = append(nodes, n)
+	}
+
+	for i := 0; i < len(s); {
+		rest := s[i:]
+		atWordStart := i == 0 || !isWordByte(s[i-1])
+		switch {
+		case rest[0] == '\\' && len(rest) > 1 && isPunct(rest[1]):
+			text.WriteByte(rest[1])
+			i += 2
+			continue
+		case rest[0] == '\n':
+			emit(Node{Type: Break})
+			i++
+			continue
+		case rest[0] == '`':
+			if end := sp.code.next(i + 1); end > i+1 {
+				emit(Node{Type: Code, Text: s[i+1 : end]})
+				i = end + 1
+				continue
+			}
+		case depth < maxDepth && strings.HasPrefix(rest, "**"):
+			if end := sp.bold.next(i + 2); end > i+2 {
+				emit(Node{Type: Bold, Children: p.inline(s[i+2:end], depth+1)})
+				i = end + 2
+				continue
+			}
+		case depth < maxDepth && strings.HasPrefix(rest, "~~"):
+			if end := sp.strike.next(i + 2); end > i+2 {
+				emit(Node{Type: Strike, Children: p.inline(s[i+2:end], depth+1)})
+				i = end + 2
+				continue
+			}
+		case depth < maxDepth && (rest[0] == '*' || (rest[0] == '_' && atWordStart)):
+			if end := sp.closingEmphasis(i); end > 0 {
+				emit(Node{Type: Italic, Children: p.inline(s[i+1:end], depth+1)})
+				i = end + 1
+				continue
+			}
+		case rest[0] == '[':
+			if label, target, end, ok := sp.linkAt(i); ok {
+				children := p.inline(label, depth+1)
+				if u, ok := SafeURL(target); ok && depth < maxDepth {
+					emit(Node{Type: Link, URL: u, Children: children})
+				} else {
+					// unsafe or unparsable target: keep only the label
+					for _, c := range children {
+						emit(c)
+					}
+				}
+				i = end
+				continue
+			}
+		case atWordStart && (strings.HasPrefix(rest, "http://") || strings.HasPrefix(rest, "https://")):
+			raw := sp.autolinkAt(i)
+			if len(raw) > maxURLLen {
+				break
+			}
+			if u, ok := SafeURL(raw); ok {
+				emit(Node{Type: Link, URL: u, Children: []Node{{Type: Text, Text: raw}}})
+				i += len(raw)
+				continue
+			}
+		case rest[0] == '@' && atWordStart && p.mention != nil:
+			if n, userID := p.mention(rest[1:]); n > 0 {
+				emit(Node{Type: Mention, Text: rest[:1+n], UserID: userID})
+				i += 1 + n
+				continue
+			}
+		}
+		_, size := utf8.DecodeRuneInString(rest)
+		text.WriteString(rest[:size])
+		i += size
+	}
+	if text.Len() > 0 {
+		nodes = append(nodes, Node{Type: Text, Text: text.String()})
+	}
+	return nodes
+}
+
+// maxURLLen is the longest bare URL turned into a link.
+const maxURLLen = 2048
+
+// finder returns the first position at or after from where match holds.
+// It remembers its last answer, and inline only ever asks with a growing
+// from, so each finder walks its paragraph once.
+type finder struct {
+	n     int
+	match func(i int) bool
+	from  int
+	at    int
+	ok    bool
+}
+
+func (f *finder) next(from int) int {
+	if f.ok && from >= f.from && (f.at < 0 || f.at >= from) {
+		return f.at
+	}
+	f.from, f.at, f.ok = from, -1, true
+	for i := from; i < f.n; i++ {
+		if f.match(i) {
+			f.at = i
+			break
+		}
+	}
+	return f.at
+}
+
+// spans holds the finders inline uses over one paragraph.
+type spans struct {
+	s                      string
+	newline, code          finder
+	bold, strike           finder
+	star, underscore       finder
+	linkMid, blank, filled finder
+	urlEnd                 finder
+	parens                 []int
+}
+
+func newSpans(s string) *spans {
+	sp := &spans{s: s}
+	at := func(sub string) finder {
+		return finder{n: len(s), match: func(i int) bool { return strings.HasPrefix(s[i:], sub) }}
+	}
+	// an emphasis closer is not preceded by a space nor followed by a word
+	closer := func(d byte) finder {
+		return finder{n: len(s), match: func(i int) bool {
+			return s[i] == d && s[i-1] != ' ' && (i+1 == len(s) || !isWordByte(s[i+1]))
+		}}
+	}
+	sp.newline = at("\n")
+	sp.code = at("`")
+	sp.bold = at("**")
+	sp.strike = at("~~")
+	sp.star = closer('*')
+	sp.underscore = closer('_')
+	sp.linkMid = at("](")
+	sp.blank = finder{n: len(s), match: func(i int) bool { return s[i] == ' ' || s[i] == '\t' || s[i] == '\n' }}
+	sp.filled = finder{n: len(s), match: func(i int) bool { return s[i] != ' ' && s[i] != '\t' }}
+	sp.urlEnd = finder{n: len(s), match: func(i int) bool {
+		r, _ := utf8.DecodeRuneInString(s[i:])
+		return unicode.IsSpace(r) || r == '<' || r == '>'
+	}}
+	return sp
+}
+
+// closingEmphasis finds the delimiter closing the one at s[i], which must
+// not be followed by a space and must close on the same line.
+func (sp *spans) closingEmphasis(i int) int {
+	s := sp.s
+	d := s[i]
+	if len(s)-i < 3 || s[i+1] == ' ' || s[i+1] == d {
+		return 0
+	}
+	f := &sp.star
+	if d == '_' {
+		f = &sp.underscore
+	}
+	end := f.next(i + 2)
+	if end < 0 {
+		return 0
+	}
+	if nl := sp.newline.next(i + 2); nl >= 0 && nl < end {
+		return 0
+	}
+	return end
+}
+
+// linkAt parses "[label](target)" at s[i] and returns the position after
+// it. The label may not span lines and the target may not contain blanks,
+// but may itself contain balanced parentheses.
+func (sp *spans) linkAt(i int) (label, target string, end int, ok bool) {
+	s := sp.s
+	mid := sp.linkMid.next(i)
+	if mid < 0 {
+		return "", "", 0, false
+	}
+	if nl := sp.newline.next(i); nl >= 0 && nl < mid {
+		return "", "", 0, false
+	}
+	if sp.parens == nil {
+		sp.parens = closingParens(s)
+	}
+	start, close := mid+2, sp.parens[mid+2]
+	if close < 0 {
+		return "", "", 0, false
+	}
+	// only leading and trailing blanks are allowed around the target
+	for start < close && (s[start] == ' ' || s[start] == '\t') {
+		start++
+	}
+	stop := close
+	if b := sp.blank.next(start); b >= 0 && b < close {
+		if f := sp.filled.next(b); f >= 0 && f < close {
+			return "", "", 0, false
+		}
+		stop = b
+	}
+	if start == stop {
+		return "", "", 0, false
+	}
+	return s[i+1 : mid], s[start:stop], close + 1, true
+}
+
+// closingParens returns, for every position k of s, the index of the
+// first ')' in s[k:] not matched by a '(' in s[k:], or -1. It is worked out
+// for all positions at once so that links do not each scan to the end of
+// the paragraph.
+func closingParens(s string) []int {
+	ends := make([]int, len(s)+1)
+	type open struct{ pos, depth int }
+	var pending []open
+	depth := 0
+	for k := 0; k <= len(s); k++ {
+		ends[k] = -1
+		pending = append(pending, open{k, depth})
+		if k == len(s) {
+			break
+		}
+		switch s[k] {
+		case '(':
+			depth++
+		case ')':
+			for len(pending) > 0 && pending[len(pending)-1].depth == depth {
+				ends[pending[len(pending)-1].pos] = k
+				pending = pending[:len(pending)-1]
+			}
+			depth--
+		}
+	}
+	return ends
+}
+
+// autolinkAt returns the bare URL at s[i], without trailing punctuation.
+func (sp *spans) autolinkAt(i int) string {
+	end := sp.urlEnd.next(i)
+	if end < 0 {
+		end = len(sp.s)
+	}
+	return strings.TrimRight(sp.s[i:end], ".,;:!?)'\"")
+}
+
+// SafeURL returns the normalised form of raw if it is an absolute http,
+// https or mailto URL.
+func SafeURL(raw string) (string, bool) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+		if u.Host == "" {
+			return "", false
+		}
+	case "mailto":
+		if u.Opaque == "" {
+			return "", false
+		}
+	default:
+		return "", false
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	return u.String(), true
+}
+
+func isWordByte(b byte) bool {
+	return b == '_' || b >= utf8.RuneSelf || unicode.IsLetter(rune(b)) || unicode.IsDigit(rune(b))
+}
+
+func isPunct(b byte) bool {
+	return b < utf8.RuneSelf && unicode.IsPunct(rune(b)) || b == '`' || b == '*' || b == '~' || b == '>' || b == '@'
+}
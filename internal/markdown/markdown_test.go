@@ -0,0 +1,87 @@
+package markdown
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseInline(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"plain", `[{"type":"paragraph","children":[{"type":"text","text":"plain"}]}]`},
+		{"**bold** and *it*", `[{"type":"paragraph","children":[{"type":"bold","children":[{"type":"text","text":"bold"}]},{"type":"text","text":" and "},{"type":"italic","children":[{"type":"text","text":"it"}]}]}]`},
+		{"~~gone~~ `x*y*`", `[{"type":"paragraph","children":[{"type":"strike","children":[{"type":"text","text":"gone"}]},{"type":"text","text":" "},{"type":"code","text":"x*y*"}]}]`},
+		{"snake_case_name", `[{"type":"paragraph","children":[{"type":"text","text":"snake_case_name"}]}]`},
+		{"*open\nclose*", `[{"type":"paragraph","children":[{"type":"text","text":"*open"},{"type":"break"},{"type":"text","text":"close*"}]}]`},
+		{`\*kept\*`, `[{"type":"paragraph","children":[{"type":"text","text":"*kept*"}]}]`},
+		{"[site](https://example.com/a_(b))", `[{"type":"paragraph","children":[{"type":"link","url":"https://example.com/a_(b)","children":[{"type":"text","text":"site"}]}]}]`},
+		{"[site]( https://example.com )", `[{"type":"paragraph","children":[{"type":"link","url":"https://example.com","children":[{"type":"text","text":"site"}]}]}]`},
+		{"[x](javascript:alert(1))", `[{"type":"paragraph","children":[{"type":"text","text":"x"}]}]`},
+		{"[x](a b)", `[{"type":"paragraph","children":[{"type":"text","text":"[x](a b)"}]}]`},
+		{"see https://example.com.", `[{"type":"paragraph","children":[{"type":"text","text":"see "},{"type":"link","url":"https://example.com","children":[{"type":"text","text":"https://example.com"}]},{"type":"text","text":"."}]}]`},
+		{"> quoted\n\n```go\nx := 1\n```", `[{"type":"quote","children":[{"type":"paragraph","children":[{"type":"text","text":"quoted"}]}]},{"type":"code_block","text":"x := 1","lang":"go"}]`},
+	}
+	for _, tt := range tests {
+		b, err := json.Marshal(Parse(tt.src, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != tt.want {
+			t.Errorf("Parse(%q)\n got %s\nwant %s", tt.src, b, tt.want)
+		}
+	}
+}
+
+func TestParseMention(t *testing.T) {
+	mention := func(s string) (int, int64) {
+		if strings.HasPrefix(s, "ann") {
+			return 3, 7
+		}
+		return 0, 0
+	}
+	b, _ := json.Marshal(Parse("hi @ann, mail@annex", mention))
+	want := `[{"type":"paragraph","children":[{"type":"text","text":"hi "},{"type":"mention","text":"@ann","user_id":7},{"type":"text","text":", mail@annex"}]}]`
+	if string(b) != want {
+		t.Fatalf("got %s\nwant %s", b, want)
+	}
+}
+
+// Openers without a closer used to make every later opener rescan the
+// rest of the paragraph. Each of these takes seconds when parsing is
+// quadratic and milliseconds when it is linear.
+func TestParseLinear(t *testing.T) {
+	const n = 1 << 18
+	inputs := map[string]string{
+		"italic":     strings.Repeat("*a", n/2),
+		"underscore": strings.Repeat(" _a", n/3),
+		"bold":       "**" + strings.Repeat("*a", n/2),
+		"strike":     "~~" + strings.Repeat("a~", n/2),
+		"code":       "`" + strings.Repeat("a", n),
+		"brackets":   strings.Repeat("[", n) + "](x",
+		"links":      strings.Repeat("[a](", n/4),
+		"blank link": strings.Repeat("[a](b", n/5) + " )",
+		"urls":       strings.Repeat("http:///", n/8),
+	}
+	for name, src := range inputs {
+		start := time.Now()
+		Parse(src, nil)
+		if d := time.Since(start); d > time.Second {
+			t.Errorf("%s: parsing %d bytes took %v", name, len(src), d)
+		}
+	}
+}
+
+func TestClosingParens(t *testing.T) {
+	s := "a(b)c)d)"
+	got := closingParens(s)
+	want := []int{5, 5, 3, 3, 5, 5, 7, 7, -1}
+	for k := range want {
+		if got[k] != want[k] {
+			t.Fatalf("closingParens(%q) = %v, want %v", s, got, want)
+		}
+	}
+}
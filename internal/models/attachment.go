@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// Attachment is a file uploaded to a room. MessageID is nil until the file
+// is sent with a message. URL is a signed download link that stops working
+// at URLExpiresAt; fetch the attachment again for a fresh one.
+type Attachment struct {
+	ID           int64     `json:"id"`
+	RoomID       int64     `json:"room_id"`
+	UploaderID   int64     `json:"uploader_id"`
+	MessageID    *int64    `json:"message_id,omitempty"`
+	Filename     string    `json:"filename"`
+	ContentType  string    `json:"content_type"`
+	Size         int64     `json:"size"`
+	SHA256       string    `json:"sha256"`
+	URL          string    `json:"url"`
+	URLExpiresAt time.Time `json:"url_expires_at"`
+	CreatedAt    time.Time `json:"created_at"`
+}
@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// Draft is a member's unsent message text in a room.
+type Draft struct {
+	RoomID    int64     `json:"room_id"`
+	Content   string    `json:"content"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
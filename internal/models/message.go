@@ -1,11 +1,126 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 type Message struct {
-	ID        int64     `json:"id"`
-	RoomID    int64     `json:"room_id"`
-	SenderID  int64     `json:"sender_id"`
-	Content   string    `json:"content"`
-	SentAt    time.Time `json:"sent_at"`
+	ID       int64  `json:"id"`
+	RoomID   int64  `json:"room_id"`
+	SenderID int64  `json:"sender_id"`
+	Content  string `json:"content"`
+	// Format is "plain" or "markdown". Markdown messages also carry Rich,
+	// the parsed and sanitised tree of Content for clients to render.
+	Format string          `json:"format"`
+	Rich   json.RawMessage `json:"rich,omitempty"`
+	// Kind is "text" for messages members write, "poll" for polls and
+	// "system" for the notices the server posts, such as pin changes.
+	Kind     string     `json:"kind"`
+	SentAt   time.Time  `json:"sent_at"`
+	Edited   bool       `json:"edited"`
+	EditedAt *time.Time `json:"edited_at,omitempty"`
+	// Deleted messages are tombstones: Content is empty and DeletedBy is
+	// the sender or moderator who removed it.
+	Deleted   bool       `json:"deleted"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	DeletedBy *int64     `json:"deleted_by,omitempty"`
+
+	// ThreadID is the root message of the thread this is a reply in.
+	// Roots carry the number of replies and the time of the latest one.
+	ThreadID    *int64     `json:"thread_id,omitempty"`
+	ReplyCount  int        `json:"reply_count,omitempty"`
+	LastReplyAt *time.Time `json:"last_reply_at,omitempty"`
+
+	ReplyTo       *ReplyRef   `json:"reply_to,omitempty"`
+	ForwardedFrom *ForwardRef `json:"forwarded_from,omitempty"`
+
+	Reactions []ReactionSummary `json:"reactions,omitempty"`
+
+	// TTLSeconds is set on disappearing messages; ExpiresAt once another
+	// member has read it.
+	TTLSeconds *int       `json:"ttl_seconds,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+
+	Poll *Poll `json:"poll,omitempty"`
+
+	// Previews are fetched after the message is sent and arrive in a
+	// "message_updated" event; history includes them once stored.
+	Previews []LinkPreview `json:"previews,omitempty"`
+
+	Attachments []Attachment `json:"attachments,omitempty"`
+
+	// ClientMsgID echoes the id the sender's client chose, in responses
+	// and events about the send only.
+	ClientMsgID string `json:"client_msg_id,omitempty"`
+}
+
+// ReplyRef is the quoted message of an inline reply, as it read when the
+// reply was sent. Deleted is set once the original was deleted for
+// everyone; MessageID is nil if the original no longer exists at all.
+type ReplyRef struct {
+	MessageID *int64 `json:"message_id,omitempty"`
+	SenderID  *int64 `json:"sender_id,omitempty"`
+	Snippet   string `json:"snippet,omitempty"`
+	Deleted   bool   `json:"deleted"`
+}
+
+// ForwardRef attributes a forwarded message to its original author.
+type ForwardRef struct {
+	UserID    *int64 `json:"user_id,omitempty"`
+	MessageID *int64 `json:"message_id,omitempty"`
+}
+
+// ReactionSummary aggregates the reactions with one emoji on a message.
+type ReactionSummary struct {
+	Emoji string `json:"emoji"`
+	Count int    `json:"count"`
+	Me    bool   `json:"reacted_by_me"`
+}
+
+// MessageEdit is a previous version of an edited message.
+type MessageEdit struct {
+	ID              int64     `json:"id"`
+	MessageID       int64     `json:"message_id"`
+	EditorID        int64     `json:"editor_id"`
+	PreviousContent string    `json:"previous_content"`
+	EditedAt        time.Time `json:"edited_at"`
+}
+
+// Mention is a message that mentions the user it was fetched for, with how
+// they were mentioned. Unread is set while the message is past the user's
+// read cursor in its room.
+type Mention struct {
+	Message
+	MentionKind string `json:"mention_kind"`
+	Unread      bool   `json:"unread"`
+}
+
+// Poll is the poll carried by a message of kind "poll"; the message content
+// is the question. Voters are only listed on polls that are not anonymous.
+type Poll struct {
+	MultiChoice bool         `json:"multi_choice"`
+	Anonymous   bool         `json:"anonymous"`
+	ClosesAt    *time.Time   `json:"closes_at,omitempty"`
+	Closed      bool         `json:"closed"`
+	Options     []PollOption `json:"options"`
+	TotalVoters int          `json:"total_voters"`
+}
+
+// PollOption is one answer of a poll with its tally.
+type PollOption struct {
+	ID     int64   `json:"id"`
+	Text   string  `json:"text"`
+	Votes  int     `json:"votes"`
+	Voters []int64 `json:"voters,omitempty"`
+	Me     bool    `json:"voted_by_me"`
+}
+
+// LinkPreview is the metadata of a page linked from a message.
+type LinkPreview struct {
+	URL         string `json:"url"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	ImageURL    string `json:"image_url,omitempty"`
+	SiteName    string `json:"site_name,omitempty"`
 }
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// MessageMeta is one user's state for one message.
+type MessageMeta struct {
+	MessageID   int64           `json:"message_id"`
+	UserID      int64           `json:"user_id"`
+	Status      string          `json:"status"` // sent, delivered or read
+	Forwarded   bool            `json:"forwarded"`
+	Starred     bool            `json:"starred"`
+	Reactions   json.RawMessage `json:"reactions,omitempty"`
+	Extra       json.RawMessage `json:"extra,omitempty"`
+	DeliveredAt *time.Time      `json:"delivered_at,omitempty"`
+	ReadAt      *time.Time      `json:"read_at,omitempty"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// RoomBan keeps a user out of a room until ExpiresAt (nil means permanent).
+type RoomBan struct {
+	RoomID    int64      `json:"room_id"`
+	UserID    int64      `json:"user_id"`
+	Reason    string     `json:"reason"`
+	BannedBy  int64      `json:"banned_by"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// RoomTimeout prevents a member from sending messages until ExpiresAt.
+type RoomTimeout struct {
+	RoomID    int64     `json:"room_id"`
+	UserID    int64     `json:"user_id"`
+	Reason    string    `json:"reason"`
+	IssuedBy  int64     `json:"issued_by"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -3,7 +3,18 @@ package models
 import "time"
 
 type RoomMember struct {
-	RoomID   int64     `json:"room_id"`
-	UserID   int64     `json:"user_id"`
-	JoinedAt time.Time `json:"joined_at"`
+	RoomID            int64      `json:"room_id"`
+	UserID            int64      `json:"user_id"`
+	JoinedAt          time.Time  `json:"joined_at"`
+	Role              string     `json:"role"` // owner, moderator or member
+	MutedUntil        *time.Time `json:"muted_until,omitempty"`
+	NotifyLevel       string     `json:"notify_level"` // all, mentions or none
+	Pinned            bool       `json:"pinned"`
+	LastReadMessageID int64      `json:"last_read_message_id"`
+	LastDeliveredID   int64      `json:"last_delivered_message_id"`
+}
+
+// Muted reports whether the member has silenced the room at time t.
+func (m *RoomMember) Muted(t time.Time) bool {
+	return m.MutedUntil != nil && m.MutedUntil.After(t)
 }
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// ScheduledMessage is a message its sender has asked to post at SendAt.
+// Once sent, MessageID is the posted message; Error explains a failure.
+type ScheduledMessage struct {
+	ID        int64     `json:"id"`
+	RoomID    int64     `json:"room_id"`
+	SenderID  int64     `json:"sender_id"`
+	Content   string    `json:"content"`
+	Format    string    `json:"format"`
+	ThreadID  *int64    `json:"thread_id,omitempty"`
+	ReplyTo   *int64    `json:"reply_to,omitempty"`
+	SendAt    time.Time `json:"send_at"`
+	Status    string    `json:"status"`
+	MessageID *int64    `json:"message_id,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// Upload is a resumable upload in progress. Offset is how many of Length
+// bytes have arrived; once all have, Attachment is the assembled file.
+type Upload struct {
+	ID         string      `json:"id"`
+	RoomID     int64       `json:"room_id"`
+	UserID     int64       `json:"user_id"`
+	Filename   string      `json:"filename"`
+	Length     int64       `json:"length"`
+	Offset     int64       `json:"offset"`
+	Attachment *Attachment `json:"attachment,omitempty"`
+	CreatedAt  time.Time   `json:"created_at"`
+	ExpiresAt  time.Time   `json:"expires_at"`
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+type Workspace struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	CreatedBy int64     `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+	Role      string    `json:"role,omitempty"` // caller's role, when listing
+}
+
+type WorkspaceMember struct {
+	WorkspaceID int64     `json:"workspace_id"`
+	UserID      int64     `json:"user_id"`
+	Name        string    `json:"name"`
+	Email       string    `json:"email"`
+	Role        string    `json:"role"` // owner, admin or member
+	JoinedAt    time.Time `json:"joined_at"`
+}
+
+type WorkspaceInvite struct {
+	ID          int64      `json:"id"`
+	WorkspaceID int64      `json:"workspace_id"`
+	Email       string     `json:"email"`
+	Role        string     `json:"role"`
+	Token       string     `json:"token,omitempty"`
+	InvitedBy   int64      `json:"invited_by"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	AcceptedAt  *time.Time `json:"accepted_at,omitempty"`
+}
@@ -0,0 +1,155 @@
+package search
+
+import (
+	"database/sql"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MemoryIndex is an in-process inverted index. It matches like MySQLIndex
+// (every term must prefix a word) and ranks by tf-idf. It only knows what
+// it was given, so it has to be filled with Backfill on startup.
+type MemoryIndex struct {
+	mu       sync.RWMutex
+	docs     map[int64]Document
+	terms    map[int64]map[string]int // message id -> term -> count
+	postings map[string]map[int64]bool
+}
+
+// NewMemoryIndex returns an empty index.
+func NewMemoryIndex() *MemoryIndex {
+	return &MemoryIndex{
+		docs:     make(map[int64]Document),
+		terms:    make(map[int64]map[string]int),
+		postings: make(map[string]map[int64]bool),
+	}
+}
+
+func (x *MemoryIndex) Index(doc Document) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.remove(doc.MessageID)
+
+	counts := make(map[string]int)
+	for _, t := range Terms(doc.Content) {
+		counts[t]++
+	}
+	x.docs[doc.MessageID] = doc
+	x.terms[doc.MessageID] = counts
+	for t := range counts {
+		if x.postings[t] == nil {
+			x.postings[t] = make(map[int64]bool)
+		}
+		x.postings[t][doc.MessageID] = true
+	}
+	return nil
+}
+
+func (x *MemoryIndex) Remove(messageID int64) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.remove(messageID)
+	return nil
+}
+
+func (x *MemoryIndex) remove(messageID int64) {
+	for t := range x.terms[messageID] {
+		delete(x.postings[t], messageID)
+		if len(x.postings[t]) == 0 {
+			delete(x.postings, t)
+		}
+	}
+	delete(x.terms, messageID)
+	delete(x.docs, messageID)
+}
+
+func (x *MemoryIndex) Search(q Query) ([]Hit, error) {
+	terms := Terms(q.Text)
+	if len(terms) == 0 {
+		return nil, ErrEmptyQuery
+	}
+	rooms := make(map[int64]bool, len(q.RoomIDs))
+	for _, id := range q.RoomIDs {
+		rooms[id] = true
+	}
+
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	n := float64(len(x.docs))
+	scores := make(map[int64]float64)
+	for i, qt := range terms {
+		// every indexed term the query term is a prefix of
+		matched := make(map[int64]float64)
+		for t, ids := range x.postings {
+			if !strings.HasPrefix(t, qt) {
+				continue
+			}
+			idf := math.Log(1 + n/float64(len(ids)))
+			for id := range ids {
+				matched[id] += float64(x.terms[id][t]) * idf
+			}
+		}
+		if i == 0 {
+			scores = matched
+			continue
+		}
+		for id := range scores {
+			if s, ok := matched[id]; ok {
+				scores[id] += s
+			} else {
+				delete(scores, id)
+			}
+		}
+	}
+
+	hits := []Hit{}
+	for id, score := range scores {
+		d := x.docs[id]
+		if !rooms[d.RoomID] || q.Exclude[id] ||
+			(q.SenderID != 0 && d.SenderID != q.SenderID) ||
+			(!q.From.IsZero() && d.SentAt.Before(q.From)) ||
+			(!q.To.IsZero() && !d.SentAt.Before(q.To)) ||
+			(q.HasAttachment != nil && d.HasAttachment != *q.HasAttachment) {
+			continue
+		}
+		hits = append(hits, Hit{MessageID: id, RoomID: d.RoomID, SenderID: d.SenderID, SentAt: d.SentAt, Score: score, Snippet: Highlight(d.Content, terms)})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if q.Sort != SortRecent && hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].MessageID > hits[j].MessageID
+	})
+
+	if q.Offset >= len(hits) {
+		return []Hit{}, nil
+	}
+	hits = hits[q.Offset:]
+	if q.Limit > 0 && len(hits) > q.Limit {
+		hits = hits[:q.Limit]
+	}
+	return hits, nil
+}
+
+// Backfill indexes every searchable message stored in db.
+func Backfill(db *sql.DB, idx Index) error {
+	rows, err := db.Query(`SELECT id, room_id, sender_id, content, sent_at, has_attachment FROM messages
+		WHERE deleted_at IS NULL AND kind <> 'system'`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var d Document
+		if err := rows.Scan(&d.MessageID, &d.RoomID, &d.SenderID, &d.Content, &d.SentAt, &d.HasAttachment); err != nil {
+			return err
+		}
+		if err := idx.Index(d); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
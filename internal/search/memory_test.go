@@ -0,0 +1,140 @@
+package search
+
+import (
+	"testing"
+	"time"
+)
+
+var base = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// fixture indexes a small history: rooms 1 and 2 are the viewer's, room 3
+// belongs to somebody else.
+func fixture(t *testing.T) *MemoryIndex {
+	t.Helper()
+	x := NewMemoryIndex()
+	docs := []Document{
+		{MessageID: 1, RoomID: 1, SenderID: 10, Content: "deploy the release tonight"},
+		{MessageID: 2, RoomID: 1, SenderID: 11, Content: "release notes are up"},
+		{MessageID: 3, RoomID: 2, SenderID: 10, Content: "who owns the release?", HasAttachment: true},
+		{MessageID: 4, RoomID: 3, SenderID: 12, Content: "secret release plans"},
+		{MessageID: 5, RoomID: 2, SenderID: 11, Content: "released it, release release"},
+		{MessageID: 6, RoomID: 1, SenderID: 10, Content: "lunch?"},
+	}
+	for i, d := range docs {
+		d.SentAt = base.Add(time.Duration(i) * time.Hour)
+		if err := x.Index(d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return x
+}
+
+func ids(hits []Hit) []int64 {
+	out := make([]int64, len(hits))
+	for i, h := range hits {
+		out[i] = h.MessageID
+	}
+	return out
+}
+
+func equal(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestMemoryIndexSearch(t *testing.T) {
+	yes, no := true, false
+	tests := []struct {
+		name string
+		q    Query
+		want []int64
+	}{
+		{"only member rooms", Query{Text: "release", RoomIDs: []int64{1, 2}, Sort: SortRecent}, []int64{5, 3, 2, 1}},
+		{"single room", Query{Text: "release", RoomIDs: []int64{2}, Sort: SortRecent}, []int64{5, 3}},
+		{"no rooms matches nothing", Query{Text: "release"}, []int64{}},
+		{"foreign room", Query{Text: "secret", RoomIDs: []int64{1, 2}}, []int64{}},
+		{"every term must match", Query{Text: "release notes", RoomIDs: []int64{1, 2}}, []int64{2}},
+		{"prefix match", Query{Text: "rel", RoomIDs: []int64{1}, Sort: SortRecent}, []int64{2, 1}},
+		{"hidden", Query{Text: "release", RoomIDs: []int64{1, 2}, Exclude: map[int64]bool{2: true, 5: true}, Sort: SortRecent}, []int64{3, 1}},
+		{"sender", Query{Text: "release", RoomIDs: []int64{1, 2}, SenderID: 10, Sort: SortRecent}, []int64{3, 1}},
+		{"time range", Query{Text: "release", RoomIDs: []int64{1, 2}, From: base.Add(time.Hour), To: base.Add(4 * time.Hour), Sort: SortRecent}, []int64{3, 2}},
+		{"with attachment", Query{Text: "release", RoomIDs: []int64{1, 2}, HasAttachment: &yes}, []int64{3}},
+		{"without attachment", Query{Text: "release", RoomIDs: []int64{1, 2}, HasAttachment: &no, Sort: SortRecent}, []int64{5, 2, 1}},
+		{"relevance first", Query{Text: "release", RoomIDs: []int64{2}}, []int64{5, 3}},
+		{"first page", Query{Text: "release", RoomIDs: []int64{1, 2}, Sort: SortRecent, Limit: 2}, []int64{5, 3}},
+		{"second page", Query{Text: "release", RoomIDs: []int64{1, 2}, Sort: SortRecent, Limit: 2, Offset: 2}, []int64{2, 1}},
+		{"past the end", Query{Text: "release", RoomIDs: []int64{1, 2}, Sort: SortRecent, Limit: 2, Offset: 4}, []int64{}},
+	}
+	x := fixture(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hits, err := x.Search(tt.q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ids(hits); !equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryIndexEmptyQuery(t *testing.T) {
+	if _, err := fixture(t).Search(Query{Text: " ?! ", RoomIDs: []int64{1}}); err != ErrEmptyQuery {
+		t.Fatalf("err = %v, want ErrEmptyQuery", err)
+	}
+}
+
+// Deleted messages and messages purged by retention or expiry are removed
+// from the index; edits replace the indexed text.
+func TestMemoryIndexRemoveAndEdit(t *testing.T) {
+	x := fixture(t)
+	all := Query{Text: "release", RoomIDs: []int64{1, 2}, Sort: SortRecent}
+
+	if err := x.Remove(2); err != nil {
+		t.Fatal(err)
+	}
+	if err := x.Remove(5); err != nil {
+		t.Fatal(err)
+	}
+	hits, err := x.Search(all)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(hits); !equal(got, []int64{3, 1}) {
+		t.Fatalf("after remove got %v, want [3 1]", got)
+	}
+
+	if err := x.Index(Document{MessageID: 1, RoomID: 1, SenderID: 10, Content: "postponed", SentAt: base}); err != nil {
+		t.Fatal(err)
+	}
+	hits, err = x.Search(all)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(hits); !equal(got, []int64{3}) {
+		t.Fatalf("after edit got %v, want [3]", got)
+	}
+	hits, err = x.Search(Query{Text: "postponed", RoomIDs: []int64{1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(hits); !equal(got, []int64{1}) {
+		t.Fatalf("edited text got %v, want [1]", got)
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	got := Highlight("Ship <it> on Release day", []string{"release"})
+	want := "Ship &lt;it&gt; on <mark>Release</mark> day"
+	if got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
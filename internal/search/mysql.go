@@ -0,0 +1,101 @@
+package search
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// MySQLIndex searches the FULLTEXT index on messages.content. MySQL keeps
+// that index up to date itself, so Index and Remove do nothing.
+type MySQLIndex struct {
+	DB *sql.DB
+}
+
+func (x *MySQLIndex) Index(doc Document) error     { return nil }
+func (x *MySQLIndex) Remove(messageID int64) error { return nil }
+
+// Search requires every term to prefix-match a word of the message and
+// ranks by natural language relevance. Terms below the server's
+// innodb_ft_min_token_size, and stopwords, cannot match.
+func (x *MySQLIndex) Search(q Query) ([]Hit, error) {
+	terms := Terms(q.Text)
+	if len(terms) == 0 {
+		return nil, ErrEmptyQuery
+	}
+	if len(q.RoomIDs) == 0 {
+		return []Hit{}, nil
+	}
+	boolean := make([]string, len(terms))
+	for i, t := range terms {
+		boolean[i] = "+" + t + "*"
+	}
+	natural := strings.Join(terms, " ")
+
+	query := `SELECT m.id, m.room_id, m.sender_id, m.sent_at, m.content,
+		MATCH(m.content) AGAINST(? IN NATURAL LANGUAGE MODE) AS score
+		FROM messages m
+		WHERE MATCH(m.content) AGAINST(? IN BOOLEAN MODE)
+			AND m.deleted_at IS NULL AND m.kind <> 'system'
+			AND (m.expires_at IS NULL OR m.expires_at > NOW())
+			AND m.room_id IN (` + placeholders(len(q.RoomIDs)) + `)`
+	args := []interface{}{natural, strings.Join(boolean, " ")}
+	for _, id := range q.RoomIDs {
+		args = append(args, id)
+	}
+	if q.SenderID != 0 {
+		query += ` AND m.sender_id = ?`
+		args = append(args, q.SenderID)
+	}
+	if !q.From.IsZero() {
+		query += ` AND m.sent_at >= ?`
+		args = append(args, q.From)
+	}
+	if !q.To.IsZero() {
+		query += ` AND m.sent_at < ?`
+		args = append(args, q.To)
+	}
+	if q.HasAttachment != nil {
+		query += ` AND m.has_attachment = ?`
+		args = append(args, *q.HasAttachment)
+	}
+	if q.HiddenBy != 0 {
+		query += ` AND NOT EXISTS (SELECT 1 FROM message_hidden h WHERE h.message_id = m.id AND h.user_id = ?)`
+		args = append(args, q.HiddenBy)
+	}
+	if len(q.Exclude) > 0 {
+		query += ` AND m.id NOT IN (` + placeholders(len(q.Exclude)) + `)`
+		for id := range q.Exclude {
+			args = append(args, id)
+		}
+	}
+	if q.Sort == SortRecent {
+		query += ` ORDER BY m.id DESC`
+	} else {
+		query += ` ORDER BY score DESC, m.id DESC`
+	}
+	query += ` LIMIT ? OFFSET ?`
+	args = append(args, q.Limit, q.Offset)
+
+	rows, err := x.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hits := []Hit{}
+	for rows.Next() {
+		var h Hit
+		var content string
+		if err := rows.Scan(&h.MessageID, &h.RoomID, &h.SenderID, &h.SentAt, &content, &h.Score); err != nil {
+			return nil, err
+		}
+		h.Snippet = Highlight(content, terms)
+		hits = append(hits, h)
+	}
+	return hits, rows.Err()
+}
+
+// placeholders returns n comma-separated "?".
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
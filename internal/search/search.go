@@ -0,0 +1,146 @@
+// Package search finds messages by their text. The Index interface is
+// implemented by MySQLIndex, which queries the FULLTEXT index on messages,
+// and MemoryIndex, an in-process index for tests and development.
+package search
+
+import (
+	"errors"
+	"html"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Sort orders for Query.Sort.
+const (
+	SortRelevance = "relevance"
+	SortRecent    = "recent"
+)
+
+// ErrEmptyQuery is returned when a query has no searchable terms.
+var ErrEmptyQuery = errors.New("query has no searchable terms")
+
+// Document is the searchable part of a message.
+type Document struct {
+	MessageID     int64
+	RoomID        int64
+	SenderID      int64
+	Content       string
+	SentAt        time.Time
+	HasAttachment bool
+}
+
+// Query is a search over the rooms in RoomIDs; an empty RoomIDs matches
+// nothing. Zero values of the other filters leave them out.
+type Query struct {
+	Text     string
+	RoomIDs  []int64
+	SenderID int64
+	From, To time.Time
+	// HasAttachment filters on whether messages carry an attachment.
+	HasAttachment *bool
+	// HiddenBy leaves out the messages this user deleted for themselves.
+	// MySQLIndex checks message_hidden in its query; indexes that cannot
+	// see it are given the ids in Exclude instead.
+	HiddenBy int64
+	// Exclude holds message ids that must not be returned.
+	Exclude map[int64]bool
+	Sort    string
+	Limit   int
+	Offset  int
+}
+
+// Hit is a matching message. Snippet is an HTML-escaped excerpt of the
+// content with the matched terms wrapped in <mark></mark>.
+type Hit struct {
+	MessageID int64     `json:"message_id"`
+	RoomID    int64     `json:"room_id"`
+	SenderID  int64     `json:"sender_id"`
+	SentAt    time.Time `json:"sent_at"`
+	Score     float64   `json:"score"`
+	Snippet   string    `json:"snippet"`
+}
+
+// Index stores and searches messages. Index replaces any earlier version of
+// the same message.
+type Index interface {
+	Index(doc Document) error
+	Remove(messageID int64) error
+	Search(q Query) ([]Hit, error)
+}
+
+// Terms splits text into lower-cased search terms.
+func Terms(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// snippetRadius is how many runes of context Highlight keeps around the
+// first match.
+const snippetRadius = 80
+
+// Highlight cuts an excerpt of content around the first word that starts
+// with one of terms and marks every such word in it.
+func Highlight(content string, terms []string) string {
+	runes := []rune(content)
+	type span struct{ start, end int }
+	var marks []span
+	for i := 0; i < len(runes); {
+		if !isTermRune(runes[i]) {
+			i++
+			continue
+		}
+		j := i
+		for j < len(runes) && isTermRune(runes[j]) {
+			j++
+		}
+		word := strings.ToLower(string(runes[i:j]))
+		for _, t := range terms {
+			if strings.HasPrefix(word, t) {
+				marks = append(marks, span{i, j})
+				break
+			}
+		}
+		i = j
+	}
+
+	start, end := 0, len(runes)
+	if len(marks) > 0 {
+		start = marks[0].start - snippetRadius
+		end = marks[0].end + snippetRadius
+	} else {
+		end = 2 * snippetRadius
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end > len(runes) {
+		end = len(runes)
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	pos := start
+	for _, m := range marks {
+		if m.start < start || m.end > end {
+			continue
+		}
+		b.WriteString(html.EscapeString(string(runes[pos:m.start])))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(string(runes[m.start:m.end])))
+		b.WriteString("</mark>")
+		pos = m.end
+	}
+	b.WriteString(html.EscapeString(string(runes[pos:end])))
+	if end < len(runes) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
+
+func isTermRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
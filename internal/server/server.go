@@ -12,8 +12,11 @@ import (
 	"convo/internal/handlers"
 	"convo/internal/handlers/auth"
 	"convo/internal/handlers/user"
+	"convo/internal/handlers/attachment"
 	"convo/internal/handlers/preprocess"
 	"convo/internal/handlers/room"
+	"convo/internal/handlers/search"
+	"convo/internal/handlers/workspace"
 
 )
 
@@ -46,9 +49,11 @@ func (s *Server) Run() error {
 	r.Use(middleware.Logger)
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"*"}, // allow all, restrict in prod
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
+		AllowedMethods:   []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token",
+			"Tus-Resumable", "Upload-Length", "Upload-Metadata", "Upload-Offset", "Upload-Checksum"},
+		ExposedHeaders:   []string{"Link", "Location", "Tus-Resumable", "Tus-Version", "Tus-Extension", "Tus-Max-Size",
+			"Tus-Checksum-Algorithm", "Upload-Offset", "Upload-Length", "Upload-Expires", "Upload-Attachment-Id"},
 		AllowCredentials: true,
 		MaxAge:           300, // seconds
 	}))
@@ -75,8 +80,31 @@ func (s *Server) Run() error {
 	r.Route("/user", func(r chi.Router) {
 		r.Use(middleware.AuthJWT(s.JWTSecret))
 		r.Get("/me", HandlerFunc(&user.MeHandler{DB: s.DB}))
+		r.Get("/search", HandlerFunc(&user.SearchHandler{DB: s.DB}))
+		r.Get("/starred", HandlerFunc(&user.StarredHandler{DB: s.DB}))
+		r.Get("/mentions", HandlerFunc(&user.MentionsHandler{DB: s.DB}))
 	})
 
+	r.Route("/search", func(r chi.Router) {
+		r.Use(middleware.AuthJWT(s.JWTSecret))
+		r.Get("/messages", HandlerFunc(&search.MessagesHandler{DB: s.DB}))
+	})
+
+	r.Route("/workspaces", func(r chi.Router) {
+		r.Use(middleware.AuthJWT(s.JWTSecret))
+		r.Get("/", HandlerFunc(&workspace.WorkspaceListHandler{DB: s.DB}))
+		r.Post("/", HandlerFunc(&workspace.CreateWorkspaceHandler{DB: s.DB}))
+		r.Post("/invites/{token}/accept", HandlerFunc(&workspace.AcceptInviteHandler{DB: s.DB}))
+		r.Get("/{id}/members", HandlerFunc(&workspace.MembersHandler{DB: s.DB}))
+		r.Put("/{id}/members/{userId}/role", HandlerFunc(&workspace.MemberRoleHandler{DB: s.DB}))
+		r.Delete("/{id}/members/{userId}", HandlerFunc(&workspace.RemoveMemberHandler{DB: s.DB}))
+		r.Get("/{id}/invites", HandlerFunc(&workspace.InviteListHandler{DB: s.DB}))
+		r.Post("/{id}/invites", HandlerFunc(&workspace.CreateInviteHandler{DB: s.DB}))
+	})
+
+	// signed download links, see chat.OpenAttachment
+	r.Get("/attachments/{attId}", HandlerFunc(&attachment.DownloadHandler{DB: s.DB}))
+
 	r.Route("/metadata", func(r chi.Router) {
 		r.Use(middleware.AuthJWT(s.JWTSecret))
 		r.Post("/", HandlerFunc(&preprocess.MetadataHandler{}))
@@ -86,10 +114,56 @@ func (s *Server) Run() error {
 		r.Use(middleware.AuthJWT(s.JWTSecret))
 		r.Get("/", HandlerFunc(&room.RoomListHandler{DB: s.DB}))
 		r.Get("/{id}/messages", HandlerFunc(&room.RoomMessagesHandler{DB: s.DB}))
+		r.Patch("/{id}/messages/{msgId}", HandlerFunc(&room.EditMessageHandler{DB: s.DB}))
+		r.Delete("/{id}/messages/{msgId}", HandlerFunc(&room.DeleteMessageHandler{DB: s.DB}))
+		r.Get("/{id}/messages/{msgId}/edits", HandlerFunc(&room.MessageEditsHandler{DB: s.DB}))
+		r.Get("/{id}/messages/{msgId}/state", HandlerFunc(&room.MessageStateHandler{DB: s.DB}))
+		r.Put("/{id}/messages/{msgId}/state", HandlerFunc(&room.MessageStateHandler{DB: s.DB}))
+		r.Get("/{id}/messages/{msgId}/receipts", HandlerFunc(&room.ReceiptsHandler{DB: s.DB}))
+		r.Post("/{id}/messages/{msgId}/reactions", HandlerFunc(&room.ReactionHandler{DB: s.DB}))
+		r.Delete("/{id}/messages/{msgId}/reactions", HandlerFunc(&room.ReactionHandler{DB: s.DB}))
+		r.Get("/{id}/messages/{msgId}/thread", HandlerFunc(&room.ThreadHandler{DB: s.DB}))
+		r.Post("/{id}/messages/{msgId}/follow", HandlerFunc(&room.FollowThreadHandler{DB: s.DB}))
+		r.Delete("/{id}/messages/{msgId}/follow", HandlerFunc(&room.FollowThreadHandler{DB: s.DB}))
+		r.Post("/{id}/messages/{msgId}/forward", HandlerFunc(&room.ForwardMessageHandler{DB: s.DB}))
+		r.Post("/{id}/messages/{msgId}/pin", HandlerFunc(&room.PinHandler{DB: s.DB}))
+		r.Delete("/{id}/messages/{msgId}/pin", HandlerFunc(&room.PinHandler{DB: s.DB}))
+		r.Get("/{id}/pins", HandlerFunc(&room.PinsHandler{DB: s.DB}))
+		r.Put("/{id}/retention", HandlerFunc(&room.RetentionHandler{DB: s.DB}))
+		r.Put("/{id}/link-previews", HandlerFunc(&room.LinkPreviewsHandler{DB: s.DB}))
+		r.Post("/{id}/attachments", HandlerFunc(&room.UploadAttachmentHandler{DB: s.DB}))
+		r.Get("/{id}/attachments/{attId}", HandlerFunc(&room.AttachmentHandler{DB: s.DB}))
+		r.Options("/{id}/uploads", HandlerFunc(&room.UploadsHandler{DB: s.DB}))
+		r.Post("/{id}/uploads", HandlerFunc(&room.UploadsHandler{DB: s.DB}))
+		r.Head("/{id}/uploads/{uploadId}", HandlerFunc(&room.UploadHandler{DB: s.DB}))
+		r.Get("/{id}/uploads/{uploadId}", HandlerFunc(&room.UploadHandler{DB: s.DB}))
+		r.Patch("/{id}/uploads/{uploadId}", HandlerFunc(&room.UploadHandler{DB: s.DB}))
+		r.Delete("/{id}/uploads/{uploadId}", HandlerFunc(&room.UploadHandler{DB: s.DB}))
+		r.Get("/{id}/draft", HandlerFunc(&room.DraftHandler{DB: s.DB}))
+		r.Put("/{id}/draft", HandlerFunc(&room.DraftHandler{DB: s.DB}))
+		r.Delete("/{id}/draft", HandlerFunc(&room.DraftHandler{DB: s.DB}))
+		r.Post("/{id}/polls", HandlerFunc(&room.CreatePollHandler{DB: s.DB}))
+		r.Post("/{id}/polls/{msgId}/votes", HandlerFunc(&room.PollVoteHandler{DB: s.DB}))
+		r.Post("/{id}/polls/{msgId}/close", HandlerFunc(&room.ClosePollHandler{DB: s.DB}))
+		r.Get("/{id}/scheduled-messages", HandlerFunc(&room.ScheduledMessagesHandler{DB: s.DB}))
+		r.Post("/{id}/scheduled-messages", HandlerFunc(&room.ScheduledMessagesHandler{DB: s.DB}))
+		r.Patch("/{id}/scheduled-messages/{schedId}", HandlerFunc(&room.ScheduledMessageHandler{DB: s.DB}))
+		r.Delete("/{id}/scheduled-messages/{schedId}", HandlerFunc(&room.ScheduledMessageHandler{DB: s.DB}))
+		r.Post("/{id}/ack", HandlerFunc(&room.AckHandler{DB: s.DB}))
 		r.Post("/add", HandlerFunc(&room.CreateRoomHandler{DB: s.DB}))
 		r.Post("/{id}/members", HandlerFunc(&room.AddMembersHandler{DB: s.DB}))
 		r.Post("/{id}/send-message", HandlerFunc(&room.SendMessageHandler{DB: s.DB}))
 		r.Get("/{id}/check", HandlerFunc(&room.RoomCheckHandler{DB: s.DB}))
+		r.Get("/{id}/settings", HandlerFunc(&room.RoomSettingsHandler{DB: s.DB}))
+		r.Put("/{id}/settings", HandlerFunc(&room.RoomSettingsHandler{DB: s.DB}))
+		r.Delete("/{id}/members/{userId}", HandlerFunc(&room.RemoveMemberHandler{DB: s.DB}))
+		r.Put("/{id}/members/{userId}/role", HandlerFunc(&room.MemberRoleHandler{DB: s.DB}))
+		r.Get("/{id}/bans", HandlerFunc(&room.BanListHandler{DB: s.DB}))
+		r.Post("/{id}/bans", HandlerFunc(&room.BanHandler{DB: s.DB}))
+		r.Delete("/{id}/bans/{userId}", HandlerFunc(&room.UnbanHandler{DB: s.DB}))
+		r.Post("/{id}/timeouts", HandlerFunc(&room.TimeoutHandler{DB: s.DB}))
+		r.Delete("/{id}/timeouts/{userId}", HandlerFunc(&room.ClearTimeoutHandler{DB: s.DB}))
+		r.Put("/{id}/slow-mode", HandlerFunc(&room.SlowModeHandler{DB: s.DB}))
 		// future: r.Get("/", list rooms), r.Post("/{id}/join", join handler), etc.
 		// future: r.Get("/", list rooms), r.Post("/{id}/join", join handler), etc.
 	})
@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidKey(t *testing.T) {
+	tests := map[string]bool{
+		"attachments/12/abc.png": true,
+		"a":                      true,
+		"a.b-c_d/E.F":            true,
+		"":                       false,
+		"/abs":                   false,
+		"trailing/":              false,
+		"a//b":                   false,
+		".":                      false,
+		"..":                     false,
+		"../escape":              false,
+		"a/../../escape":         false,
+		"a/./b":                  false,
+		`a\..\b`:                 false,
+		"a b":                    false,
+		"a%2f..":                 false,
+		"ünicode":                false,
+		strings.Repeat("a", 513): false,
+	}
+	for key, want := range tests {
+		if got := ValidKey(key); got != want {
+			t.Errorf("ValidKey(%q) = %v, want %v", key, got, want)
+		}
+	}
+}
+
+func TestLocalStore(t *testing.T) {
+	ctx := context.Background()
+	s := &LocalStore{Root: t.TempDir()}
+
+	if err := s.Put(ctx, "rooms/1/a.txt", strings.NewReader("hello"), 5, "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+	// replacing keeps only the new content
+	if err := s.Put(ctx, "rooms/1/a.txt", strings.NewReader("bye"), -1, ""); err != nil {
+		t.Fatal(err)
+	}
+	rc, err := s.Get(ctx, "rooms/1/a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(b) != "bye" {
+		t.Fatalf("got %q, want %q", b, "bye")
+	}
+
+	if err := s.Delete(ctx, "rooms/1/a.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(ctx, "rooms/1/a.txt"); err != ErrNotFound {
+		t.Fatalf("get after delete: err = %v, want ErrNotFound", err)
+	}
+	if err := s.Delete(ctx, "rooms/1/a.txt"); err != nil {
+		t.Fatalf("deleting a missing blob: %v", err)
+	}
+}
+
+func TestLocalStoreSizeMismatch(t *testing.T) {
+	ctx := context.Background()
+	s := &LocalStore{Root: t.TempDir()}
+	if err := s.Put(ctx, "short", strings.NewReader("abc"), 10, ""); err == nil {
+		t.Fatal("short write succeeded")
+	}
+	if _, err := s.Get(ctx, "short"); err != ErrNotFound {
+		t.Fatalf("partial blob left behind: err = %v", err)
+	}
+	entries, _ := os.ReadDir(s.Root)
+	if len(entries) != 0 {
+		t.Fatalf("temporary files left behind: %v", entries)
+	}
+}
+
+func TestLocalStoreRejectsTraversal(t *testing.T) {
+	ctx := context.Background()
+	parent := t.TempDir()
+	s := &LocalStore{Root: filepath.Join(parent, "blobs")}
+	secret := filepath.Join(parent, "secret")
+	if err := os.WriteFile(secret, []byte("secret"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"../secret", "a/../../secret", "/etc/passwd", "..", `..\secret`, "a//../secret"} {
+		if err := s.Put(ctx, key, strings.NewReader("x"), 1, ""); err != ErrInvalidKey {
+			t.Errorf("Put(%q) err = %v, want ErrInvalidKey", key, err)
+		}
+		if rc, err := s.Get(ctx, key); err != ErrInvalidKey {
+			if rc != nil {
+				rc.Close()
+			}
+			t.Errorf("Get(%q) err = %v, want ErrInvalidKey", key, err)
+		}
+		if err := s.Delete(ctx, key); err != ErrInvalidKey {
+			t.Errorf("Delete(%q) err = %v, want ErrInvalidKey", key, err)
+		}
+	}
+	if b, err := os.ReadFile(secret); err != nil || string(b) != "secret" {
+		t.Fatalf("file outside the root was touched: %q, %v", b, err)
+	}
+}
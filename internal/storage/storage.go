@@ -0,0 +1,51 @@
+// Package storage keeps attachment contents in a blob store. Blobs are
+// addressed by keys the caller chooses; metadata such as names and owners
+// lives in the database, not here.
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+)
+
+var (
+	// ErrNotFound is returned when no blob has the key.
+	ErrNotFound = errors.New("storage: blob not found")
+	// ErrInvalidKey is returned for keys ValidKey rejects.
+	ErrInvalidKey = errors.New("storage: invalid key")
+)
+
+// Store is a blob store.
+type Store interface {
+	// Put stores size bytes read from r under key, replacing any blob
+	// already there. A negative size means unknown, which not every
+	// store accepts.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get opens the blob stored under key.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the blob under key; deleting a missing blob is not
+	// an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// ValidKey reports whether key is usable with every store: slash-separated
+// segments of letters, digits, '-', '_' and '.', none of them empty, "." or
+// "..", at most 512 bytes in all.
+func ValidKey(key string) bool {
+	if key == "" || len(key) > 512 {
+		return false
+	}
+	for _, seg := range strings.Split(key, "/") {
+		if seg == "" || seg == "." || seg == ".." {
+			return false
+		}
+		for _, c := range seg {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+				return false
+			}
+		}
+	}
+	return true
+}
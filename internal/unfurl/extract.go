@@ -0,0 +1,42 @@
+package unfurl
+
+import (
+	"net/url"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ExtractURLs returns up to max distinct http and https URLs in text, in
+// the order they appear. A URL must start a word and ends at whitespace;
+// trailing punctuation and closing brackets are not part of it.
+func ExtractURLs(text string, max int) []string {
+	var urls []string
+	seen := make(map[string]bool)
+	for i := 0; i < len(text) && len(urls) < max; {
+		rest := text[i:]
+		if !strings.HasPrefix(rest, "http://") && !strings.HasPrefix(rest, "https://") {
+			_, size := utf8.DecodeRuneInString(rest)
+			i += size
+			continue
+		}
+		end := strings.IndexFunc(rest, func(r rune) bool { return unicode.IsSpace(r) || r == '<' || r == '>' || r == '"' })
+		if end < 0 {
+			end = len(rest)
+		}
+		i += end
+		if prev, _ := utf8.DecodeLastRuneInString(text[:i-end]); prev != utf8.RuneError && (unicode.IsLetter(prev) || unicode.IsDigit(prev)) {
+			continue
+		}
+		raw := strings.TrimRight(rest[:end], ".,;:!?)]}'*_~`")
+		u, err := url.Parse(raw)
+		if err != nil || !httpURL(u) {
+			continue
+		}
+		if s := u.String(); !seen[s] {
+			seen[s] = true
+			urls = append(urls, s)
+		}
+	}
+	return urls
+}
@@ -0,0 +1,281 @@
+// Package unfurl fetches the OpenGraph and oEmbed metadata of web pages for
+// link previews. Fetching is guarded against server-side request forgery:
+// only http and https URLs are followed and connections to loopback,
+// private, link-local and other non-public addresses are refused, including
+// after redirects and DNS changes, since the check runs on the address
+// actually dialled.
+package unfurl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	// ErrBlockedAddress is returned when a URL resolves to an address
+	// previews may not be fetched from.
+	ErrBlockedAddress = errors.New("unfurl: address not allowed")
+	// ErrUnsupported is returned for URLs that are not http or https and
+	// for responses that are not HTML.
+	ErrUnsupported = errors.New("unfurl: unsupported url or content")
+	// ErrNoMetadata is returned when a page has nothing worth previewing.
+	ErrNoMetadata = errors.New("unfurl: no metadata")
+)
+
+// Limits on what a preview carries.
+const (
+	maxTitle       = 300
+	maxDescription = 1000
+	maxSiteName    = 200
+	maxRedirects   = 5
+)
+
+// Preview is the metadata of one page.
+type Preview struct {
+	URL         string `json:"url"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	ImageURL    string `json:"image_url,omitempty"`
+	SiteName    string `json:"site_name,omitempty"`
+}
+
+// Fetcher fetches previews. Its zero value is not usable; use NewFetcher.
+type Fetcher struct {
+	// MaxBytes is how much of a page (or oEmbed response) is read.
+	MaxBytes int64
+	// AllowPrivate lets the fetcher reach non-public addresses. It is for
+	// tests against a local server and must stay off in production.
+	AllowPrivate bool
+	UserAgent    string
+
+	client *http.Client
+	// allowAddr replaces PublicAddr when set, so tests can let one local
+	// server through and not another.
+	allowAddr func(netip.AddrPort) bool
+}
+
+// NewFetcher returns a Fetcher that gives up on a page after timeout and
+// reads at most maxBytes of it.
+func NewFetcher(timeout time.Duration, maxBytes int64) *Fetcher {
+	f := &Fetcher{MaxBytes: maxBytes, UserAgent: "convo-unfurl/1.0"}
+	dialer := &net.Dialer{Timeout: timeout, Control: f.checkAddress}
+	f.client = &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			// no proxy: it would be the one dialling the real address
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   timeout,
+			ResponseHeaderTimeout: timeout,
+			MaxIdleConns:          16,
+			IdleConnTimeout:       30 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return errors.New("unfurl: too many redirects")
+			}
+			if !httpURL(req.URL) {
+				return ErrUnsupported
+			}
+			return nil
+		},
+	}
+	return f
+}
+
+// checkAddress runs for every connection after name resolution.
+func (f *Fetcher) checkAddress(network, address string, _ syscall.RawConn) error {
+	if f.AllowPrivate {
+		return nil
+	}
+	ap, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return ErrBlockedAddress
+	}
+	allowed := PublicAddr(ap.Addr())
+	if f.allowAddr != nil {
+		allowed = f.allowAddr(ap)
+	}
+	if !allowed {
+		return ErrBlockedAddress
+	}
+	return nil
+}
+
+// blockedPrefixes are ranges that are not covered by the netip predicates
+// but must not be reachable either.
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"), // NAT64 can reach IPv4 private space
+	netip.MustParsePrefix("2001:db8::/32"),
+}
+
+// PublicAddr reports whether a is a globally routable unicast address.
+func PublicAddr(a netip.Addr) bool {
+	a = a.Unmap()
+	if !a.IsValid() || a.IsLoopback() || a.IsPrivate() || a.IsUnspecified() || a.IsMulticast() ||
+		a.IsLinkLocalUnicast() || a.IsLinkLocalMulticast() || a.IsInterfaceLocalMulticast() {
+		return false
+	}
+	for _, p := range blockedPrefixes {
+		if p.Contains(a) {
+			return false
+		}
+	}
+	return true
+}
+
+func httpURL(u *url.URL) bool {
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" && u.User == nil
+}
+
+// Fetch loads rawURL and returns its preview, filled from OpenGraph tags,
+// the page's oEmbed endpoint if it advertises one, and plain HTML title
+// and description as a fallback.
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (*Preview, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || !httpURL(u) {
+		return nil, ErrUnsupported
+	}
+	body, final, err := f.get(ctx, u.String(), "text/html", "application/xhtml+xml")
+	if err != nil {
+		return nil, err
+	}
+	page := parseHead(body)
+
+	p := &Preview{URL: u.String()}
+	p.Title = first(page.meta["og:title"], page.meta["twitter:title"])
+	p.Description = first(page.meta["og:description"], page.meta["twitter:description"], page.meta["description"])
+	p.SiteName = page.meta["og:site_name"]
+	p.ImageURL = resolve(final, first(page.meta["og:image"], page.meta["og:image:url"], page.meta["twitter:image"]))
+
+	if page.oembed != "" && (p.Title == "" || p.ImageURL == "") {
+		if o, err := f.oembed(ctx, resolve(final, page.oembed)); err == nil {
+			if p.Title == "" {
+				p.Title = o.Title
+			}
+			if p.SiteName == "" {
+				p.SiteName = o.ProviderName
+			}
+			if p.ImageURL == "" {
+				p.ImageURL = resolve(final, o.ThumbnailURL)
+			}
+		}
+	}
+	if p.Title == "" {
+		p.Title = page.title
+	}
+	if p.Title == "" && p.Description == "" {
+		return nil, ErrNoMetadata
+	}
+	p.Title = truncate(p.Title, maxTitle)
+	p.Description = truncate(p.Description, maxDescription)
+	p.SiteName = truncate(p.SiteName, maxSiteName)
+	return p, nil
+}
+
+// oembedResponse holds the fields of an oEmbed response previews use.
+type oembedResponse struct {
+	Title        string `json:"title"`
+	ProviderName string `json:"provider_name"`
+	ThumbnailURL string `json:"thumbnail_url"`
+}
+
+func (f *Fetcher) oembed(ctx context.Context, endpoint string) (*oembedResponse, error) {
+	if endpoint == "" {
+		return nil, ErrUnsupported
+	}
+	body, _, err := f.get(ctx, endpoint, "application/json", "text/javascript")
+	if err != nil {
+		return nil, err
+	}
+	var o oembedResponse
+	if err := json.Unmarshal(body, &o); err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+// get fetches rawURL, requiring one of the given media types, and returns
+// at most MaxBytes of the body with the URL it was finally served from.
+func (f *Fetcher) get(ctx context.Context, rawURL string, types ...string) ([]byte, *url.URL, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, nil, ErrUnsupported
+	}
+	req.Header.Set("User-Agent", f.UserAgent)
+	req.Header.Set("Accept", strings.Join(types, ", "))
+	resp, err := f.client.Do(req)
+	if err != nil {
+		if errors.Is(err, ErrBlockedAddress) {
+			return nil, nil, ErrBlockedAddress
+		}
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("unfurl: %s returned %d", rawURL, resp.StatusCode)
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	supported := false
+	for _, t := range types {
+		supported = supported || mediaType == t
+	}
+	if !supported {
+		return nil, nil, ErrUnsupported
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, f.MaxBytes))
+	if err != nil {
+		return nil, nil, err
+	}
+	return body, resp.Request.URL, nil
+}
+
+// resolve makes ref absolute against base and drops it unless it is an
+// http or https URL.
+func resolve(base *url.URL, ref string) string {
+	if ref == "" {
+		return ""
+	}
+	u, err := base.Parse(strings.TrimSpace(ref))
+	if err != nil || !httpURL(u) {
+		return ""
+	}
+	return u.String()
+}
+
+func first(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func truncate(s string, max int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	return string([]rune(s)[:max-1]) + "…"
+}
@@ -0,0 +1,244 @@
+package unfurl
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// serve starts a test server answering every request with handler.
+func serve(t *testing.T, handler http.HandlerFunc) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// addrOf returns the address srv listens on.
+func addrOf(t *testing.T, srv *httptest.Server) netip.AddrPort {
+	t.Helper()
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return netip.MustParseAddrPort(u.Host)
+}
+
+// localFetcher returns a fetcher that may only reach the given servers.
+func localFetcher(t *testing.T, timeout time.Duration, allowed ...*httptest.Server) *Fetcher {
+	t.Helper()
+	f := NewFetcher(timeout, 64<<10)
+	ok := make(map[netip.AddrPort]bool)
+	for _, srv := range allowed {
+		ok[addrOf(t, srv)] = true
+	}
+	f.allowAddr = func(ap netip.AddrPort) bool { return ok[ap] }
+	return f
+}
+
+// htmlPage serves body as an HTML page.
+func htmlPage(body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(body))
+	}
+}
+
+func TestFetchRefusesLoopback(t *testing.T) {
+	var hits atomic.Int32
+	srv := serve(t, func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		htmlPage("<title>internal</title>")(w, r)
+	})
+
+	f := NewFetcher(time.Second, 64<<10)
+	for _, u := range []string{srv.URL, strings.Replace(srv.URL, "127.0.0.1", "localhost", 1)} {
+		if _, err := f.Fetch(context.Background(), u); !errors.Is(err, ErrBlockedAddress) {
+			t.Errorf("Fetch(%s) err = %v, want ErrBlockedAddress", u, err)
+		}
+	}
+	if n := hits.Load(); n != 0 {
+		t.Fatalf("blocked server got %d requests", n)
+	}
+}
+
+func TestFetchRefusesRedirectToBlockedAddress(t *testing.T) {
+	var hits atomic.Int32
+	internal := serve(t, func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		htmlPage("<title>internal</title>")(w, r)
+	})
+	public := serve(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, internal.URL+"/admin", http.StatusFound)
+	})
+
+	f := localFetcher(t, time.Second, public)
+	if _, err := f.Fetch(context.Background(), public.URL); !errors.Is(err, ErrBlockedAddress) {
+		t.Fatalf("err = %v, want ErrBlockedAddress", err)
+	}
+	if n := hits.Load(); n != 0 {
+		t.Fatalf("redirect target got %d requests", n)
+	}
+}
+
+func TestFetchFollowsAllowedRedirect(t *testing.T) {
+	target := serve(t, htmlPage(`<meta property="og:title" content="Moved"><meta property="og:image" content="/img.png">`))
+	start := serve(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target.URL+"/page", http.StatusMovedPermanently)
+	})
+
+	p, err := localFetcher(t, time.Second, start, target).Fetch(context.Background(), start.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.URL != start.URL || p.Title != "Moved" || p.ImageURL != target.URL+"/img.png" {
+		t.Fatalf("got %+v", p)
+	}
+}
+
+func TestFetchRejectsBadURLs(t *testing.T) {
+	f := NewFetcher(time.Second, 64<<10)
+	for _, u := range []string{"ftp://example.com/", "javascript:alert(1)", "http://user:pw@example.com/", "/relative", "http://"} {
+		if _, err := f.Fetch(context.Background(), u); err != ErrUnsupported {
+			t.Errorf("Fetch(%q) err = %v, want ErrUnsupported", u, err)
+		}
+	}
+}
+
+func TestFetchTimeout(t *testing.T) {
+	srv := serve(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	})
+
+	start := time.Now()
+	_, err := localFetcher(t, 200*time.Millisecond, srv).Fetch(context.Background(), srv.URL)
+	if err == nil {
+		t.Fatal("slow page did not fail")
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Fatalf("gave up after %v", d)
+	}
+}
+
+func TestFetchReadsAtMostMaxBytes(t *testing.T) {
+	padding := strings.Repeat("<meta name=x content=y>", 4096)
+	srv := serve(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/early":
+			htmlPage("<title>Early</title>"+padding)(w, r)
+		case "/late":
+			htmlPage(padding+"<title>Late</title>")(w, r)
+		}
+	})
+
+	f := localFetcher(t, time.Second, srv)
+	f.MaxBytes = 4 << 10
+	if p, err := f.Fetch(context.Background(), srv.URL+"/early"); err != nil || p.Title != "Early" {
+		t.Fatalf("early: %+v, %v", p, err)
+	}
+	if _, err := f.Fetch(context.Background(), srv.URL+"/late"); err != ErrNoMetadata {
+		t.Fatalf("late: err = %v, want ErrNoMetadata", err)
+	}
+}
+
+func TestFetchRequiresHTML(t *testing.T) {
+	srv := serve(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write([]byte("<title>binary</title>"))
+	})
+	if _, err := localFetcher(t, time.Second, srv).Fetch(context.Background(), srv.URL); err != ErrUnsupported {
+		t.Fatalf("err = %v, want ErrUnsupported", err)
+	}
+}
+
+func TestFetchMetadata(t *testing.T) {
+	var srv *httptest.Server
+	srv = serve(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/og":
+			htmlPage(`<!doctype html><html><head>
+				<title>Fallback</title>
+				<!-- <meta property="og:title" content="Commented out"> -->
+				<META PROPERTY="og:title" CONTENT="Tom &amp; Jerry">
+				<meta property="og:title" content="Second title">
+				<meta name="description" content="  A   cat
+					and a mouse ">
+				<meta property="og:site_name" content=Cartoons>
+				<meta property="og:image" content="javascript:alert(1)">
+				<script>var s = "<meta property='og:image' content='/evil.png'>";</script>
+				</head><body><meta property="og:description" content="in body"></body></html>`)(w, r)
+		case "/title":
+			htmlPage(`<html><head><title> Just a &lt;title&gt; </title></head></html>`)(w, r)
+		case "/empty":
+			htmlPage(`<html><body><h1>Nothing here</h1></body></html>`)(w, r)
+		case "/oembed-page":
+			htmlPage(`<title>Video page</title>
+				<link rel="alternate" type="application/json+oembed" href="/oembed.json">`)(w, r)
+		case "/oembed.json":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"title":"A video","provider_name":"Tube","thumbnail_url":"/thumb.jpg"}`))
+		}
+	})
+	f := localFetcher(t, time.Second, srv)
+
+	tests := []struct {
+		path string
+		want Preview
+	}{
+		{"/og", Preview{Title: "Tom & Jerry", Description: "A cat and a mouse", SiteName: "Cartoons"}},
+		{"/title", Preview{Title: "Just a <title>"}},
+		{"/oembed-page", Preview{Title: "A video", SiteName: "Tube", ImageURL: srv.URL + "/thumb.jpg"}},
+	}
+	for _, tt := range tests {
+		p, err := f.Fetch(context.Background(), srv.URL+tt.path)
+		if err != nil {
+			t.Errorf("%s: %v", tt.path, err)
+			continue
+		}
+		tt.want.URL = srv.URL + tt.path
+		if *p != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.path, *p, tt.want)
+		}
+	}
+	if _, err := f.Fetch(context.Background(), srv.URL+"/empty"); err != ErrNoMetadata {
+		t.Errorf("/empty: err = %v, want ErrNoMetadata", err)
+	}
+}
+
+func TestPublicAddr(t *testing.T) {
+	tests := map[string]bool{
+		"93.184.216.34":        true,
+		"2606:4700::1111":      true,
+		"127.0.0.1":            false,
+		"::1":                  false,
+		"10.1.2.3":             false,
+		"172.16.0.1":           false,
+		"192.168.1.1":          false,
+		"169.254.169.254":      false,
+		"100.64.0.1":           false,
+		"0.0.0.0":              false,
+		"::":                   false,
+		"fc00::1":              false,
+		"fe80::1":              false,
+		"::ffff:127.0.0.1":     false,
+		"::ffff:93.184.216.34": true,
+		"64:ff9b::a00:1":       false,
+		"224.0.0.1":            false,
+		"255.255.255.255":      false,
+	}
+	for s, want := range tests {
+		if got := PublicAddr(netip.MustParseAddr(s)); got != want {
+			t.Errorf("PublicAddr(%s) = %v, want %v", s, got, want)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package unfurl
+
+import (
+	"bytes"
+	"html"
+	"strings"
+)
+
+// head is what previews need from a page's <head>.
+type head struct {
+	title  string
+	meta   map[string]string // property or name, lower-cased, to content
+	oembed string            // href of the JSON oEmbed discovery link
+}
+
+// parseHead scans the start of an HTML document for <title>, <meta> and
+// <link> tags, stopping at <body>. It is deliberately forgiving: pages are
+// often malformed and anything it cannot make sense of is skipped.
+func parseHead(doc []byte) head {
+	h := head{meta: make(map[string]string)}
+	lower := asciiLower(doc)
+	for i := 0; i < len(doc); {
+		lt := bytes.IndexByte(lower[i:], '<')
+		if lt < 0 {
+			break
+		}
+		i += lt
+		rest := lower[i:]
+		switch {
+		case bytes.HasPrefix(rest, []byte("<!--")):
+			end := bytes.Index(rest, []byte("-->"))
+			if end < 0 {
+				return h
+			}
+			i += end + 3
+			continue
+		case hasTag(rest, "script"), hasTag(rest, "style"):
+			name := "</script"
+			if hasTag(rest, "style") {
+				name = "</style"
+			}
+			end := bytes.Index(rest, []byte(name))
+			if end < 0 {
+				return h
+			}
+			i += end + len(name)
+			continue
+		case hasTag(rest, "body"), bytes.HasPrefix(rest, []byte("</head")):
+			return h
+		case hasTag(rest, "title"):
+			start := bytes.IndexByte(rest, '>')
+			end := bytes.Index(rest, []byte("</title"))
+			if start < 0 || end < start {
+				return h
+			}
+			if h.title == "" {
+				h.title = strings.TrimSpace(html.UnescapeString(string(doc[i+start+1 : i+end])))
+			}
+			i += end
+			continue
+		}
+
+		end := bytes.IndexByte(rest, '>')
+		if end < 0 {
+			break
+		}
+		tag := doc[i+1 : i+end]
+		i += end + 1
+		name, attrs := parseTag(tag)
+		switch name {
+		case "meta":
+			key := strings.ToLower(first(attrs["property"], attrs["name"]))
+			if _, seen := h.meta[key]; key != "" && !seen {
+				h.meta[key] = strings.TrimSpace(attrs["content"])
+			}
+		case "link":
+			if strings.EqualFold(attrs["type"], "application/json+oembed") && h.oembed == "" {
+				h.oembed = attrs["href"]
+			}
+		}
+	}
+	return h
+}
+
+// asciiLower lower-cases the ASCII letters of b and leaves every other byte
+// alone. Unlike bytes.ToLower it never changes the length, so offsets found
+// in the result index the same bytes of b, whatever its encoding.
+func asciiLower(b []byte) []byte {
+	out := make([]byte, len(b))
+	for i, c := range b {
+		if 'A' <= c && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		out[i] = c
+	}
+	return out
+}
+
+// hasTag reports whether s starts with the opening tag name.
+func hasTag(s []byte, name string) bool {
+	if len(s) < len(name)+2 || s[0] != '<' || !bytes.HasPrefix(s[1:], []byte(name)) {
+		return false
+	}
+	c := s[len(name)+1]
+	return c == '>' || c == '/' || c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// parseTag splits the inside of a tag into its lower-cased name and its
+// attributes, with entity-decoded values.
+func parseTag(tag []byte) (string, map[string]string) {
+	s := string(tag)
+	n := strings.IndexAny(s, " \t\n\r/")
+	if n < 0 {
+		return strings.ToLower(s), nil
+	}
+	name := strings.ToLower(s[:n])
+	attrs := make(map[string]string)
+	for s = s[n:]; ; {
+		s = strings.TrimLeft(s, " \t\n\r/")
+		if s == "" {
+			break
+		}
+		k := strings.IndexAny(s, "= \t\n\r/")
+		if k < 0 {
+			attrs[strings.ToLower(s)] = ""
+			break
+		}
+		key := strings.ToLower(s[:k])
+		s = strings.TrimLeft(s[k:], " \t\n\r")
+		if !strings.HasPrefix(s, "=") {
+			attrs[key] = ""
+			continue
+		}
+		s = strings.TrimLeft(s[1:], " \t\n\r")
+		var value string
+		if s != "" && (s[0] == '"' || s[0] == '\'') {
+			end := strings.IndexByte(s[1:], s[0])
+			if end < 0 {
+				value, s = s[1:], ""
+			} else {
+				value, s = s[1:1+end], s[2+end:]
+			}
+		} else {
+			end := strings.IndexAny(s, " \t\n\r")
+			if end < 0 {
+				end = len(s)
+			}
+			value, s = s[:end], s[end:]
+		}
+		if _, seen := attrs[key]; !seen {
+			attrs[key] = html.UnescapeString(value)
+		}
+	}
+	return name, attrs
+}
@@ -0,0 +1,42 @@
+package unfurl
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseHead(t *testing.T) {
+	tests := []struct {
+		name  string
+		doc   string
+		title string
+		desc  string
+	}{
+		{"plain", `<title>Hello</title><meta name="description" content="World">`, "Hello", "World"},
+		{"upper case tags", `<TITLE>Hello</TITLE><META NAME="Description" CONTENT="World">`, "Hello", "World"},
+		{"latin-1", "<title>Caf\xe9 \xe9t\xe9</title><meta name=description content=\"\xe9\">", "Caf\xe9 \xe9t\xe9", "\xe9"},
+		{"invalid utf-8 before the title", strings.Repeat("\xff\xe9", 10) + "<title>After</title>", "After", ""},
+		{"length-changing runes", "<title>İstanbul Ⱥ</title><meta name=description content=\"İ\">", "İstanbul Ⱥ", "İ"},
+		{"unterminated title", "<title>\xe9\xe9\xe9", "", ""},
+	}
+	for _, tt := range tests {
+		h := parseHead([]byte(tt.doc))
+		if h.title != tt.title || h.meta["description"] != tt.desc {
+			t.Errorf("%s: got title %q, description %q; want %q, %q", tt.name, h.title, h.meta["description"], tt.title, tt.desc)
+		}
+	}
+}
+
+func FuzzParseHead(f *testing.F) {
+	for _, seed := range []string{
+		`<!doctype html><head><title>T</title><!-- c --><script><meta name=x></script><link rel=alternate type="application/json+oembed" href="/o"></head>`,
+		"<title>" + strings.Repeat("\xe9", 10) + "</title>",
+		"<TITLE>İİ</TITLE><META PROPERTY=og:title CONTENT='Ⱥ'>",
+		"<title>\xff</title\xe9><meta name=\xc4 content=\xe9>",
+	} {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, doc []byte) {
+		parseHead(doc)
+	})
+}
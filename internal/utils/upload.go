@@ -0,0 +1,13 @@
+package utils
+
+import "net/http"
+
+// MultipartOverhead is how much a single-file multipart upload may exceed
+// its file size limit, to leave room for the framing around the file.
+const MultipartOverhead = 1 << 20
+
+// LimitUpload caps r's body for a multipart upload of one file of at most
+// maxFile bytes. Reading past the cap fails with an *http.MaxBytesError.
+func LimitUpload(w http.ResponseWriter, r *http.Request, maxFile int64) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxFile+MultipartOverhead)
+}
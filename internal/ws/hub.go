@@ -127,3 +127,86 @@ func (c *Connection) StartWrite() {
         }
     }
 }
+
+// SendToUser delivers msg to every live connection of userID, skipping
+// connections that belong to exceptRoomID (pass 0 to skip none). It is used
+// for events that concern a user rather than a room, such as notifications.
+func SendToUser(userID int64, exceptRoomID int64, msg []byte) {
+    hubsMu.Lock()
+    all := make([]*RoomHub, 0, len(hubs))
+    for id, h := range hubs {
+        if id != exceptRoomID {
+            all = append(all, h)
+        }
+    }
+    hubsMu.Unlock()
+
+    for _, h := range all {
+        h.mu.Lock()
+        for c := range h.Conns {
+            if c.UserID != userID {
+                continue
+            }
+            select {
+            case c.Send <- msg:
+            default:
+                // slow client, drop the event rather than block other users
+            }
+        }
+        h.mu.Unlock()
+    }
+}
+
+// Disconnect sends msg to every connection userID has open in roomID and
+// then closes them; the read loop of each connection takes care of
+// unregistering it from the hub.
+func Disconnect(roomID, userID int64, msg []byte) {
+    hubsMu.Lock()
+    h, ok := hubs[roomID]
+    hubsMu.Unlock()
+    if !ok {
+        return
+    }
+
+    h.mu.Lock()
+    defer h.mu.Unlock()
+    for c := range h.Conns {
+        if c.UserID != userID {
+            continue
+        }
+        select {
+        case c.Send <- msg:
+        default:
+        }
+        // give the writer a moment to flush msg before the socket goes away
+        conn := c.Conn
+        time.AfterFunc(time.Second, func() { conn.Close() })
+    }
+}
+
+// Online reports which of userIDs have at least one live connection in any
+// room.
+func Online(userIDs []int64) map[int64]bool {
+    want := make(map[int64]bool, len(userIDs))
+    for _, id := range userIDs {
+        want[id] = true
+    }
+    hubsMu.Lock()
+    all := make([]*RoomHub, 0, len(hubs))
+    for _, h := range hubs {
+        all = append(all, h)
+    }
+    hubsMu.Unlock()
+
+    online := make(map[int64]bool)
+    for _, h := range all {
+        h.mu.Lock()
+        for c := range h.Conns {
+            if want[c.UserID] {
+                online[c.UserID] = true
+            }
+        }
+        h.mu.Unlock()
+    }
+    return online
+}
@@ -0,0 +1,7 @@
+-- Migration: per-member room settings (muting, notification level, pinning)
+-- and the read cursor used for unread counts in the room list.
+ALTER TABLE room_members
+    ADD COLUMN muted_until DATETIME NULL,
+    ADD COLUMN notify_level ENUM('all','mentions','none') NOT NULL DEFAULT 'all',
+    ADD COLUMN pinned TINYINT(1) NOT NULL DEFAULT 0,
+    ADD COLUMN last_read_message_id BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,32 @@
+-- Migration: member roles, bans and timeouts for room moderation
+ALTER TABLE room_members
+    ADD COLUMN role ENUM('owner','moderator','member') NOT NULL DEFAULT 'member';
+
+UPDATE room_members rm JOIN rooms r ON r.id = rm.room_id
+    SET rm.role = 'owner' WHERE rm.user_id = r.created_by;
+
+CREATE TABLE IF NOT EXISTS room_bans (
+    room_id BIGINT NOT NULL,
+    user_id BIGINT NOT NULL,
+    reason VARCHAR(500) NOT NULL DEFAULT '',
+    banned_by BIGINT NOT NULL,
+    expires_at DATETIME NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (room_id, user_id),
+    FOREIGN KEY (room_id) REFERENCES rooms(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (banned_by) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS room_timeouts (
+    room_id BIGINT NOT NULL,
+    user_id BIGINT NOT NULL,
+    reason VARCHAR(500) NOT NULL DEFAULT '',
+    issued_by BIGINT NOT NULL,
+    expires_at DATETIME NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (room_id, user_id),
+    FOREIGN KEY (room_id) REFERENCES rooms(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (issued_by) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,6 @@
+-- Migration: per-room slow mode and an index for "last message by sender"
+ALTER TABLE rooms
+    ADD COLUMN slow_mode_seconds INT NOT NULL DEFAULT 0;
+
+ALTER TABLE messages
+    ADD INDEX idx_messages_room_sender (room_id, sender_id, sent_at);
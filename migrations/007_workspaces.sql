@@ -0,0 +1,39 @@
+-- Migration: workspaces own rooms and have their own members, roles and
+-- invitations. Rooms without a workspace keep the old global behaviour.
+CREATE TABLE IF NOT EXISTS workspaces (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    created_by BIGINT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (created_by) REFERENCES users(id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS workspace_members (
+    workspace_id BIGINT NOT NULL,
+    user_id BIGINT NOT NULL,
+    role ENUM('owner','admin','member') NOT NULL DEFAULT 'member',
+    joined_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (workspace_id, user_id),
+    INDEX idx_workspace_members_user (user_id),
+    FOREIGN KEY (workspace_id) REFERENCES workspaces(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS workspace_invites (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    workspace_id BIGINT NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    role ENUM('admin','member') NOT NULL DEFAULT 'member',
+    token CHAR(64) NOT NULL UNIQUE,
+    invited_by BIGINT NOT NULL,
+    expires_at DATETIME NOT NULL,
+    accepted_by BIGINT NULL,
+    accepted_at DATETIME NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (workspace_id) REFERENCES workspaces(id) ON DELETE CASCADE,
+    FOREIGN KEY (invited_by) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+ALTER TABLE rooms
+    ADD COLUMN workspace_id BIGINT NULL,
+    ADD CONSTRAINT fk_rooms_workspace FOREIGN KEY (workspace_id) REFERENCES workspaces(id) ON DELETE CASCADE;
@@ -0,0 +1,14 @@
+-- Migration: message editing keeps every previous version
+ALTER TABLE messages
+    ADD COLUMN edited_at DATETIME NULL;
+
+CREATE TABLE IF NOT EXISTS message_edits (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    message_id BIGINT NOT NULL,
+    editor_id BIGINT NOT NULL,
+    previous_content TEXT NOT NULL,
+    edited_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_message_edits_message (message_id),
+    FOREIGN KEY (message_id) REFERENCES messages(id) ON DELETE CASCADE,
+    FOREIGN KEY (editor_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
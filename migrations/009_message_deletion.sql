@@ -0,0 +1,13 @@
+-- Migration: delete-for-everyone tombstones and per-user hidden messages
+ALTER TABLE messages
+    ADD COLUMN deleted_at DATETIME NULL,
+    ADD COLUMN deleted_by BIGINT NULL;
+
+CREATE TABLE IF NOT EXISTS message_hidden (
+    message_id BIGINT NOT NULL,
+    user_id BIGINT NOT NULL,
+    hidden_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (message_id, user_id),
+    FOREIGN KEY (message_id) REFERENCES messages(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
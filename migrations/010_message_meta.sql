@@ -0,0 +1,18 @@
+-- Migration: per-user message state. SendMessageHandler has been writing
+-- into message_meta all along; this finally creates it.
+CREATE TABLE IF NOT EXISTS message_meta (
+    message_id BIGINT NOT NULL,
+    user_id BIGINT NOT NULL,
+    status ENUM('sent','delivered','read') NOT NULL DEFAULT 'sent',
+    forwarded TINYINT(1) NOT NULL DEFAULT 0,
+    starred TINYINT(1) NOT NULL DEFAULT 0,
+    reactions JSON NULL,
+    extra JSON NULL,
+    delivered_at DATETIME NULL,
+    read_at DATETIME NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (message_id, user_id),
+    INDEX idx_message_meta_user_starred (user_id, starred),
+    FOREIGN KEY (message_id) REFERENCES messages(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
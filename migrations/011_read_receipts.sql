@@ -0,0 +1,3 @@
+-- Migration: delivery cursor next to the read cursor added in 004
+ALTER TABLE room_members
+    ADD COLUMN last_delivered_message_id BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,17 @@
+-- Migration: threaded replies. thread_id points at the thread's root
+-- message; roots keep a denormalised reply count and last reply time.
+ALTER TABLE messages
+    ADD COLUMN thread_id BIGINT NULL,
+    ADD COLUMN reply_count INT NOT NULL DEFAULT 0,
+    ADD COLUMN last_reply_at DATETIME NULL,
+    ADD INDEX idx_messages_thread (thread_id, id),
+    ADD CONSTRAINT fk_messages_thread FOREIGN KEY (thread_id) REFERENCES messages(id) ON DELETE CASCADE;
+
+CREATE TABLE IF NOT EXISTS thread_followers (
+    message_id BIGINT NOT NULL,
+    user_id BIGINT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (message_id, user_id),
+    FOREIGN KEY (message_id) REFERENCES messages(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,13 @@
+-- Migration: quoted replies and forwarding. Replies keep a snapshot of the
+-- quoted text so they stay readable after the original is edited or gone;
+-- forwards keep the original author.
+ALTER TABLE messages
+    ADD COLUMN reply_to_id BIGINT NULL,
+    ADD COLUMN reply_sender_id BIGINT NULL,
+    ADD COLUMN reply_snippet VARCHAR(300) NULL,
+    ADD COLUMN forwarded_from_message_id BIGINT NULL,
+    ADD COLUMN forwarded_from_user_id BIGINT NULL,
+    ADD CONSTRAINT fk_messages_reply_to FOREIGN KEY (reply_to_id) REFERENCES messages(id) ON DELETE SET NULL,
+    ADD CONSTRAINT fk_messages_reply_sender FOREIGN KEY (reply_sender_id) REFERENCES users(id) ON DELETE SET NULL,
+    ADD CONSTRAINT fk_messages_fwd_message FOREIGN KEY (forwarded_from_message_id) REFERENCES messages(id) ON DELETE SET NULL,
+    ADD CONSTRAINT fk_messages_fwd_user FOREIGN KEY (forwarded_from_user_id) REFERENCES users(id) ON DELETE SET NULL;
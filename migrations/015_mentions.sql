@@ -0,0 +1,19 @@
+-- Migration: @mentions. One row per mentioned member per message; kind
+-- records how they were mentioned. unread_mentions is a per-member badge
+-- count of mentions past the read cursor.
+CREATE TABLE IF NOT EXISTS message_mentions (
+    message_id BIGINT NOT NULL,
+    user_id BIGINT NOT NULL,
+    room_id BIGINT NOT NULL,
+    kind ENUM('user', 'here', 'room') NOT NULL DEFAULT 'user',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (message_id, user_id),
+    INDEX idx_mentions_user (user_id, message_id),
+    INDEX idx_mentions_room_user (room_id, user_id, message_id),
+    FOREIGN KEY (message_id) REFERENCES messages(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (room_id) REFERENCES rooms(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+ALTER TABLE room_members
+    ADD COLUMN unread_mentions INT NOT NULL DEFAULT 0;
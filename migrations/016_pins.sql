@@ -0,0 +1,16 @@
+-- Migration: pinned messages, and a kind column so the server can post
+-- system notices (such as pin changes) into room history.
+ALTER TABLE messages
+    ADD COLUMN kind ENUM('text', 'system') NOT NULL DEFAULT 'text';
+
+CREATE TABLE IF NOT EXISTS room_pins (
+    room_id BIGINT NOT NULL,
+    message_id BIGINT NOT NULL,
+    pinned_by BIGINT NOT NULL,
+    pinned_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (room_id, message_id),
+    INDEX idx_room_pins_order (room_id, pinned_at),
+    FOREIGN KEY (room_id) REFERENCES rooms(id) ON DELETE CASCADE,
+    FOREIGN KEY (message_id) REFERENCES messages(id) ON DELETE CASCADE,
+    FOREIGN KEY (pinned_by) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,7 @@
+-- Migration: full-text message search. has_attachment is kept on the
+-- message row so search can filter on it without a join.
+ALTER TABLE messages
+    ADD COLUMN has_attachment BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE messages
+    ADD FULLTEXT INDEX ft_messages_content (content);
@@ -0,0 +1,21 @@
+-- Migration: messages composed now and sent later by the dispatcher.
+-- message_id points at the posted message once status is 'sent'.
+CREATE TABLE IF NOT EXISTS scheduled_messages (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    room_id BIGINT NOT NULL,
+    sender_id BIGINT NOT NULL,
+    content TEXT NOT NULL,
+    thread_id BIGINT NULL,
+    reply_to_id BIGINT NULL,
+    send_at DATETIME NOT NULL,
+    status ENUM('pending', 'sent', 'cancelled', 'failed') NOT NULL DEFAULT 'pending',
+    message_id BIGINT NULL,
+    error VARCHAR(255) NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX idx_scheduled_due (status, send_at),
+    INDEX idx_scheduled_sender (sender_id, room_id, status),
+    FOREIGN KEY (room_id) REFERENCES rooms(id) ON DELETE CASCADE,
+    FOREIGN KEY (sender_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (message_id) REFERENCES messages(id) ON DELETE SET NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
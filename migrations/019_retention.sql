@@ -0,0 +1,13 @@
+-- Migration: room retention and disappearing messages. retention_seconds
+-- purges messages that old (0 keeps them forever); disappearing_seconds is
+-- the default ttl_seconds of new messages. A message's expires_at is set
+-- once another member reads it.
+ALTER TABLE rooms
+    ADD COLUMN retention_seconds INT NOT NULL DEFAULT 0,
+    ADD COLUMN disappearing_seconds INT NOT NULL DEFAULT 0;
+
+ALTER TABLE messages
+    ADD COLUMN ttl_seconds INT NULL,
+    ADD COLUMN expires_at DATETIME NULL,
+    ADD INDEX idx_messages_expires (expires_at),
+    ADD INDEX idx_messages_room_sent (room_id, sent_at);
@@ -0,0 +1,34 @@
+-- Migration: polls. A poll is a message of kind 'poll' whose content is
+-- the question; options and votes hang off the message.
+ALTER TABLE messages
+    MODIFY COLUMN kind ENUM('text', 'system', 'poll') NOT NULL DEFAULT 'text';
+
+CREATE TABLE IF NOT EXISTS polls (
+    message_id BIGINT PRIMARY KEY,
+    multi_choice BOOLEAN NOT NULL DEFAULT FALSE,
+    anonymous BOOLEAN NOT NULL DEFAULT FALSE,
+    closes_at DATETIME NULL,
+    closed_at DATETIME NULL,
+    FOREIGN KEY (message_id) REFERENCES messages(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS poll_options (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    message_id BIGINT NOT NULL,
+    position INT NOT NULL,
+    text VARCHAR(400) NOT NULL,
+    UNIQUE KEY uq_poll_option_position (message_id, position),
+    FOREIGN KEY (message_id) REFERENCES polls(message_id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS poll_votes (
+    option_id BIGINT NOT NULL,
+    user_id BIGINT NOT NULL,
+    message_id BIGINT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (option_id, user_id),
+    INDEX idx_poll_votes_user (message_id, user_id),
+    FOREIGN KEY (option_id) REFERENCES poll_options(id) ON DELETE CASCADE,
+    FOREIGN KEY (message_id) REFERENCES polls(message_id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,12 @@
+-- Migration: client-generated message ids, so retried sends can be
+-- recognised. Rows older than the dedupe window are pruned by the reaper.
+CREATE TABLE IF NOT EXISTS message_client_ids (
+    sender_id BIGINT NOT NULL,
+    client_msg_id VARCHAR(64) NOT NULL,
+    message_id BIGINT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (sender_id, client_msg_id),
+    INDEX idx_client_ids_created (created_at),
+    FOREIGN KEY (sender_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (message_id) REFERENCES messages(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
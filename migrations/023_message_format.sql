@@ -0,0 +1,9 @@
+-- Migration: message formats. Markdown messages keep their raw content and
+-- the parsed, sanitised tree in rich, computed when the message is sent or
+-- edited.
+ALTER TABLE messages
+    ADD COLUMN format ENUM('plain','markdown') NOT NULL DEFAULT 'plain' AFTER content,
+    ADD COLUMN rich JSON NULL AFTER format;
+
+ALTER TABLE scheduled_messages
+    ADD COLUMN format ENUM('plain','markdown') NOT NULL DEFAULT 'plain' AFTER content;
@@ -0,0 +1,24 @@
+-- Migration: link previews. Fetched page metadata is cached per URL,
+-- failures included so a dead link is not refetched for every message.
+CREATE TABLE IF NOT EXISTS link_previews (
+    url_hash CHAR(64) NOT NULL PRIMARY KEY, -- hex sha256 of url
+    url VARCHAR(2048) NOT NULL,
+    ok BOOLEAN NOT NULL,
+    title VARCHAR(300) NULL,
+    description TEXT NULL,
+    image_url VARCHAR(2048) NULL,
+    site_name VARCHAR(200) NULL,
+    fetched_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS message_link_previews (
+    message_id BIGINT NOT NULL,
+    position TINYINT NOT NULL,
+    url_hash CHAR(64) NOT NULL,
+    PRIMARY KEY (message_id, position),
+    FOREIGN KEY (message_id) REFERENCES messages(id) ON DELETE CASCADE,
+    FOREIGN KEY (url_hash) REFERENCES link_previews(url_hash) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- moderators can turn previews off per room
+ALTER TABLE rooms ADD COLUMN link_previews BOOLEAN NOT NULL DEFAULT TRUE;
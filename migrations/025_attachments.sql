@@ -0,0 +1,20 @@
+-- Migration: message attachments. Contents live in the blob store under
+-- storage_key; a row without message_id is an upload not sent yet.
+CREATE TABLE IF NOT EXISTS attachments (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    room_id BIGINT NOT NULL,
+    uploader_id BIGINT NOT NULL,
+    message_id BIGINT NULL,
+    storage_key VARCHAR(255) NOT NULL,
+    filename VARCHAR(255) NOT NULL,
+    content_type VARCHAR(127) NOT NULL,
+    size BIGINT NOT NULL,
+    sha256 CHAR(64) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_attachments_key (storage_key),
+    INDEX idx_attachments_message (message_id),
+    INDEX idx_attachments_unsent (message_id, created_at),
+    FOREIGN KEY (room_id) REFERENCES rooms(id) ON DELETE CASCADE,
+    FOREIGN KEY (uploader_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (message_id) REFERENCES messages(id) ON DELETE SET NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
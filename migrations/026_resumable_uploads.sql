@@ -0,0 +1,26 @@
+-- Migration: resumable uploads. Each received chunk is its own blob until
+-- the upload is complete and assembled into an attachment.
+CREATE TABLE IF NOT EXISTS uploads (
+    id CHAR(32) NOT NULL PRIMARY KEY,
+    room_id BIGINT NOT NULL,
+    user_id BIGINT NOT NULL,
+    filename VARCHAR(255) NOT NULL,
+    length BIGINT NOT NULL,
+    upload_offset BIGINT NOT NULL DEFAULT 0,
+    attachment_id BIGINT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    INDEX idx_uploads_expires (expires_at),
+    FOREIGN KEY (room_id) REFERENCES rooms(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (attachment_id) REFERENCES attachments(id) ON DELETE SET NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS upload_chunks (
+    upload_id CHAR(32) NOT NULL,
+    chunk_offset BIGINT NOT NULL,
+    size BIGINT NOT NULL,
+    storage_key VARCHAR(255) NOT NULL,
+    PRIMARY KEY (upload_id, chunk_offset),
+    FOREIGN KEY (upload_id) REFERENCES uploads(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,4 @@
+-- Migration: slow mode claims a member's send slot with a conditional update
+-- on last_sent_at, so two concurrent sends cannot both pass the check.
+ALTER TABLE room_members
+    ADD COLUMN last_sent_at DATETIME NULL;
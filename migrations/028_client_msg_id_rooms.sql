@@ -0,0 +1,13 @@
+-- Migration: client_msg_ids are scoped to the room they were sent to, so an
+-- id reused in another room is a new message rather than a retry.
+ALTER TABLE message_client_ids
+    ADD COLUMN room_id BIGINT NOT NULL DEFAULT 0 AFTER sender_id;
+
+UPDATE message_client_ids c JOIN messages m ON m.id = c.message_id
+    SET c.room_id = m.room_id;
+
+ALTER TABLE message_client_ids
+    ALTER COLUMN room_id DROP DEFAULT,
+    DROP PRIMARY KEY,
+    ADD PRIMARY KEY (sender_id, room_id, client_msg_id),
+    ADD CONSTRAINT fk_client_ids_room FOREIGN KEY (room_id) REFERENCES rooms(id) ON DELETE CASCADE;